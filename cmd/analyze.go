@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
+)
+
+// traceMetric extends the recomputed network metric with trace-only statistics
+type traceMetric struct {
+	Message    p2p.MessageID `json:"message"`
+	Origin     p2p.NodeID    `json:"origin"`
+	Sends      int           `json:"sends"`
+	Duplicates int           `json:"duplicates"`
+	Drops      int           `json:"drops"`
+	MaxHop     int           `json:"max_hop"`
+	p2p.NetworkMetric
+}
+
+// summary aggregates result rows of one broadcast type and delay
+type summary struct {
+	Broadcast        string  `json:"broadcast"`
+	Delay            int     `json:"delay"`
+	Runs             int     `json:"runs"`
+	AvgDuplicateRate float64 `json:"avg_duplicate_rate"`
+	AvgReceivingRate float64 `json:"avg_receiving_rate"`
+}
+
+// runAnalyze summarizes results files or recomputes metrics from trace files
+func runAnalyze(args []string) error {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	output := fs.String("o", "-", "file to write the analysis to (- for stdout)")
+	asJSON := fs.Bool("json", false, "write JSONL instead of a table")
+	aggregate := fs.Bool("aggregate", false, "summarize repetitions of each configuration with confidence intervals")
+	pareto := fs.Bool("pareto", false, "list configurations on the duplicates/coverage/latency Pareto frontier")
+	compare := fs.String("compare", "", "test two broadcast types for significant differences (e.g. BasicPublish,WavePublish-40)")
+	level := fs.Float64("ci", 0.95, "confidence level of the bootstrap intervals")
+	resamples := fs.Int("bootstrap", 1000, "number of bootstrap resamples")
+	alpha := fs.Float64("alpha", 0.05, "significance level of comparisons")
+	seed := fs.Int64("seed", 1, "seed of the bootstrap resampling")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: analyze [flags] FILE [FILE ...]\n\nFILE is a results JSONL or CSV file or a trace file.\n"+
+			"With -aggregate, -pareto or -compare, the rows of all result files are pooled.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no input files")
+	}
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if *aggregate || *pareto || *compare != "" {
+		var rows []experiment.Result
+		for _, path := range fs.Args() {
+			r, err := experiment.ReadResults(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			rows = append(rows, r...)
+		}
+
+		if *compare != "" {
+			return compareResults(out, rows, *compare, *alpha, *asJSON)
+		}
+
+		opts := experiment.AggregateOptions{Level: *level, Resamples: *resamples, Seed: *seed}
+		if *pareto {
+			return paretoResults(out, rows, opts, *asJSON)
+		}
+		return aggregateResults(out, rows, opts, *asJSON)
+	}
+
+	for _, path := range fs.Args() {
+		isTrace, err := isTraceFile(path)
+		if err != nil {
+			return err
+		}
+
+		if isTrace {
+			err = analyzeTrace(out, path, *asJSON)
+		} else {
+			err = analyzeResults(out, path, *asJSON)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// isTraceFile reports whether the first line of a file is a trace header
+func isTraceFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	var header trace.Header
+	if err := json.Unmarshal(line, &header); err != nil {
+		return false, nil
+	}
+
+	return header.Version > 0 && header.Start != "", nil
+}
+
+// analyzeTrace recomputes the metrics of every message in a trace file
+func analyzeTrace(out io.Writer, path string, asJSON bool) error {
+	replay, err := trace.ReplayFile(path)
+	if err != nil {
+		return err
+	}
+
+	var rows []traceMetric
+
+	for _, mid := range replay.MessageIDs() {
+		m := replay.Messages[mid]
+
+		maxHop := 0
+		for _, hop := range m.Hops {
+			maxHop = max(maxHop, hop)
+		}
+
+		rows = append(rows, traceMetric{
+			Message:       mid,
+			Origin:        m.Origin,
+			Sends:         m.Sends,
+			Duplicates:    m.Duplicates,
+			Drops:         m.Drops,
+			MaxHop:        maxHop,
+			NetworkMetric: replay.Metric(mid),
+		})
+	}
+
+	if asJSON {
+		return encodeRows(out, rows)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\n", path)
+	fmt.Fprintln(tw, "MESSAGE\tBROADCAST\tNODES\tSENDS\tDUPLICATE RATE\tRECEIVING RATE\tP50 MS\tP99 MS\tMAX HOP")
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%.4f\t%.4f\t%.2f\t%.2f\t%d\n",
+			row.Message, row.Broadcast, row.NodeCount, row.Sends, row.DuplicateRate, row.ReceivingRate, row.LatencyP50, row.LatencyP99, row.MaxHop)
+	}
+
+	return tw.Flush()
+}
+
+// analyzeResults averages result rows per broadcast type and delay
+func analyzeResults(out io.Writer, path string, asJSON bool) error {
+	results, err := experiment.ReadResults(path)
+	if err != nil {
+		return err
+	}
+
+	groups := make(map[string]*summary)
+
+	for _, metric := range results {
+		key := fmt.Sprintf("%s|%d", metric.Broadcast, metric.Delay)
+		s, ok := groups[key]
+		if !ok {
+			s = &summary{Broadcast: metric.Broadcast, Delay: metric.Delay}
+			groups[key] = s
+		}
+
+		s.Runs++
+		s.AvgDuplicateRate += metric.DuplicateRate
+		s.AvgReceivingRate += metric.ReceivingRate
+	}
+
+	rows := make([]*summary, 0, len(groups))
+	for _, s := range groups {
+		s.AvgDuplicateRate /= float64(s.Runs)
+		s.AvgReceivingRate /= float64(s.Runs)
+		rows = append(rows, s)
+	}
+
+	sort.Slice(rows, func(a, b int) bool {
+		if rows[a].Delay != rows[b].Delay {
+			return rows[a].Delay < rows[b].Delay
+		}
+		return p2p.SortKey(rows[a].Broadcast) < p2p.SortKey(rows[b].Broadcast)
+	})
+
+	if asJSON {
+		return encodeRows(out, rows)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\n", path)
+	fmt.Fprintln(tw, "BROADCAST\tDELAY\tRUNS\tAVG DUPLICATE RATE\tAVG RECEIVING RATE")
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.4f\t%.4f%%\n", row.Broadcast, row.Delay, row.Runs, row.AvgDuplicateRate, row.AvgReceivingRate*100)
+	}
+
+	return tw.Flush()
+}
+
+// aggregateResults prints the per-configuration summaries of result rows
+func aggregateResults(out io.Writer, rows []experiment.Result, opts experiment.AggregateOptions, asJSON bool) error {
+	aggregates := experiment.AggregateResults(rows, opts)
+
+	sort.SliceStable(aggregates, func(a, b int) bool {
+		return p2p.SortKey(aggregates[a].Broadcast) < p2p.SortKey(aggregates[b].Broadcast)
+	})
+
+	if asJSON {
+		return encodeRows(out, aggregates)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONFIG\tTRIALS\tMETRIC\tMEAN\tSTDDEV\tCI LOW\tCI HIGH\tP05\tP50\tP95")
+	for _, agg := range aggregates {
+		for _, name := range experiment.AllMetrics() {
+			s, ok := agg.Metrics[name]
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\n",
+				agg.Config, agg.Trials, name, s.Mean, s.StdDev, s.CILow, s.CIHigh, s.P05, s.P50, s.P95)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// paretoResults prints the configurations no other configuration beats on every metric
+func paretoResults(out io.Writer, rows []experiment.Result, opts experiment.AggregateOptions, asJSON bool) error {
+	frontier := experiment.ParetoFrontier(experiment.AggregateResults(rows, opts))
+
+	if asJSON {
+		return encodeRows(out, frontier)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	writePareto(tw, frontier)
+
+	return tw.Flush()
+}
+
+// compareResults prints whether two broadcast types differ on each metric
+func compareResults(out io.Writer, rows []experiment.Result, pair string, alpha float64, asJSON bool) error {
+	names := strings.Split(pair, ",")
+	if len(names) != 2 {
+		return fmt.Errorf("-compare needs two broadcast types separated by a comma")
+	}
+
+	a, err := p2p.ParseBroadcastType(strings.TrimSpace(names[0]))
+	if err != nil {
+		return err
+	}
+	b, err := p2p.ParseBroadcastType(strings.TrimSpace(names[1]))
+	if err != nil {
+		return err
+	}
+
+	comparisons := experiment.Compare(rows, a, b, alpha)
+	if len(comparisons) == 0 {
+		return fmt.Errorf("no configurations shared by %s and %s", a, b)
+	}
+
+	if asJSON {
+		return encodeRows(out, comparisons)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s vs %s (Mann-Whitney U, alpha %.3g)\n", a, b, alpha)
+	fmt.Fprintln(tw, "SHARED\tMETRIC\tN A\tN B\tMEAN A\tMEAN B\tU\tP\tSIGNIFICANT")
+	for _, c := range comparisons {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.4f\t%.4f\t%.1f\t%.4g\t%t\n",
+			c.Shared, c.Metric, c.TrialsA, c.TrialsB, c.MeanA, c.MeanB, c.U, c.P, c.Significant)
+	}
+
+	return tw.Flush()
+}
+
+// encodeRows writes each row as a JSON line
+func encodeRows[T any](out io.Writer, rows []T) error {
+	enc := json.NewEncoder(out)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/deanon"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// runDeanon measures how well spies can guess the origin of broadcasts under each protocol
+func runDeanon(args []string) error {
+	fs := flag.NewFlagSet("deanon", flag.ExitOnError)
+	topo := &topologyFlags{}
+	topo.register(fs)
+	protocols := fs.String("protocols", "BasicPublish,WavePublish-10,WavePublish-50,Dandelion-10", "comma-separated broadcast types to compare")
+	spies := fs.Float64("spies", 0.05, "fraction of nodes that are spies")
+	runs := fs.Int("runs", 100, "broadcasts per protocol, each from a random origin")
+	observers := fs.Int("observers", 20, "earliest spies used by the timing estimator")
+	output := fs.String("o", "-", "file to write the scores to (- for stdout)")
+	asJSON := fs.Bool("json", false, "write JSON instead of a table")
+	fs.Parse(args)
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	var types []p2p.BroadcastType
+	for _, name := range splitList(*protocols) {
+		bt, err := p2p.ParseBroadcastType(name)
+		if err != nil {
+			return err
+		}
+		types = append(types, bt)
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("no protocols given")
+	}
+	if *spies <= 0 || *spies >= 1 {
+		return fmt.Errorf("spy fraction %v must be between 0 and 1", *spies)
+	}
+	if *runs <= 0 {
+		return fmt.Errorf("runs %d must be positive", *runs)
+	}
+	if *observers <= 0 {
+		return fmt.Errorf("observers %d must be positive", *observers)
+	}
+
+	exp, run, err := topo.singleRun(p2p.BroadcastType{Type: p2p.BasicPublish}, 0)
+	if err != nil {
+		return err
+	}
+
+	g, err := exp.BuildGraph(run)
+	if err != nil {
+		return err
+	}
+
+	scores := deanon.Evaluate(g, types, deanon.Config{
+		Spies:     *spies,
+		Runs:      *runs,
+		Observers: *observers,
+		Seed:      g.Config.Seed,
+	})
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if *asJSON {
+		return encodeRows(out, scores)
+	}
+
+	fmt.Fprintf(out, "%d nodes, %.1f%% spies, %d runs per protocol, seed %d\n", g.NodeCount(), *spies*100, *runs, g.Config.Seed)
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROTOCOL\tESTIMATOR\tGUESSES\tHITS\tPRECISION\tRECALL\tMEAN ERROR HOPS")
+	for _, s := range scores {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.4f\t%.4f\t%.2f\n",
+			s.Protocol, s.Estimator, s.Guesses, s.Hits, s.Precision, s.Recall, s.MeanError)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/network"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// runExport writes a topology, or the propagation graph of a broadcast on it, to DOT, GraphML or JSON
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	topo := &topologyFlags{}
+	topo.register(fs)
+	format := fs.String("format", "dot", "output format: dot, graphml or json")
+	propagation := fs.Bool("propagation", false, "run a broadcast and export its propagation graph instead of the topology")
+	duplicates := fs.Bool("duplicates", false, "include duplicate edges in the propagation graph")
+	protocol := fs.String("protocol", p2p.BasicPublish, "broadcast type for -propagation")
+	origin := fs.Int("origin", 0, "index of the node that starts the broadcast for -propagation")
+	output := fs.String("o", "-", "file to write (- for stdout)")
+	fs.Parse(args)
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	// Checked before the output is created, which truncates it
+	switch *format {
+	case "dot", "graphml", "json":
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	bt, err := p2p.ParseBroadcastType(*protocol)
+	if err != nil {
+		return err
+	}
+
+	exp, run, err := topo.singleRun(bt, *origin)
+	if err != nil {
+		return err
+	}
+
+	n, err := exp.BuildNetwork(run)
+	if err != nil {
+		return err
+	}
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if !*propagation {
+		switch *format {
+		case "dot":
+			return n.WriteDOT(out)
+		case "graphml":
+			return n.WriteGraphML(out)
+		case "json":
+			return n.WriteNodeLinkJSON(out)
+		}
+
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	if *origin < 0 || *origin >= len(n.Nodes) {
+		return fmt.Errorf("origin %d out of range", *origin)
+	}
+
+	wg := &sync.WaitGroup{}
+	n.Nodes[*origin].Broadcast(1, bt, wg)
+	wg.Wait()
+
+	p := n.Propagation(1)
+	if p == nil {
+		return fmt.Errorf("message did not propagate")
+	}
+
+	return writePropagation(p, *format, *duplicates, out)
+}
+
+// writePropagation writes a propagation graph in the requested format
+func writePropagation(p *network.Propagation, format string, duplicates bool, out io.Writer) error {
+	switch format {
+	case "dot":
+		return p.WritePropagationDOT(out, duplicates)
+	case "graphml":
+		return p.WritePropagationGraphML(out, duplicates)
+	case "json":
+		return p.WritePropagationNodeLinkJSON(out, duplicates)
+	}
+
+	return fmt.Errorf("unknown format %q", format)
+}
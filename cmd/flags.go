@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// topologyFlags holds the flags describing how a topology is generated or loaded
+type topologyFlags struct {
+	input        string
+	format       string
+	generator    string
+	nodes        int
+	degree       int
+	edges        int
+	minNodeDelay int
+	maxNodeDelay int
+	minLinkDelay int
+	maxLinkDelay int
+	seed         int64
+}
+
+// register adds the topology flags to a flag set
+func (t *topologyFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&t.input, "input", "", "topology file to load instead of generating one")
+	fs.StringVar(&t.format, "input-format", "snapshot", "format of -input: snapshot, edgelist, graphml, gexf or dot")
+	fs.StringVar(&t.generator, "generator", experiment.GeneratorLimitDegree, "topology generator: limit-degree or random")
+	fs.IntVar(&t.nodes, "nodes", 1000, "number of nodes")
+	fs.IntVar(&t.degree, "degree", 40, "mean degree")
+	fs.IntVar(&t.edges, "edges", 0, "number of edges for the random generator (0 derives it from -degree)")
+	fs.IntVar(&t.minNodeDelay, "min-node-delay", 0, "minimum node processing delay in ms")
+	fs.IntVar(&t.maxNodeDelay, "max-node-delay", 100, "maximum node processing delay in ms")
+	fs.IntVar(&t.minLinkDelay, "min-link-delay", 0, "minimum link delay in ms")
+	fs.IntVar(&t.maxLinkDelay, "max-link-delay", 1, "maximum link delay in ms")
+	fs.Int64Var(&t.seed, "seed", 0, "random seed (0 picks a time-based seed)")
+}
+
+// experiment builds a single-run experiment from the topology flags
+func (t *topologyFlags) experiment(protocol p2p.BroadcastType, origin int) *experiment.Experiment {
+	grid := map[string]experiment.Values{
+		"min_node_delay": {float64(t.minNodeDelay)},
+		"max_node_delay": {float64(t.maxNodeDelay)},
+		"min_link_delay": {float64(t.minLinkDelay)},
+		"max_link_delay": {float64(t.maxLinkDelay)},
+		"origin":         {float64(origin)},
+	}
+
+	exp := &experiment.Experiment{
+		Name: "cli",
+		Seed: t.seed,
+		Topology: experiment.Topology{
+			Generator: t.generator,
+			Grid:      grid,
+		},
+		Protocols: []experiment.Protocol{{Type: protocol.Type}},
+	}
+
+	if t.input != "" {
+		exp.Topology.Generator = experiment.GeneratorFile
+		exp.Topology.Path = t.input
+		exp.Topology.Format = t.format
+	} else {
+		grid["node_count"] = experiment.Values{float64(t.nodes)}
+		grid["mean_degree"] = experiment.Values{float64(t.degree)}
+		if t.edges > 0 {
+			grid["edge_count"] = experiment.Values{float64(t.edges)}
+		}
+	}
+
+	switch protocol.Type {
+	case p2p.WavePublish:
+		exp.Protocols[0].Grid = map[string]experiment.Values{"level": {float64(protocol.Level)}}
+	case p2p.Dandelion:
+		exp.Protocols[0].Grid = map[string]experiment.Values{
+			"q":       {float64(protocol.Level)},
+			"timeout": {float64(protocol.FailSafe())},
+		}
+	}
+
+	return exp
+}
+
+// singleRun builds and validates a single-run experiment and returns it with its only run
+// An explicit seed is used for the topology as is
+func (t *topologyFlags) singleRun(protocol p2p.BroadcastType, origin int) (*experiment.Experiment, experiment.Run, error) {
+	exp := t.experiment(protocol, origin)
+
+	if err := exp.Validate(); err != nil {
+		return nil, experiment.Run{}, err
+	}
+
+	run := exp.Expand()[0]
+	run.Seed = exp.Seed
+
+	return exp, run, nil
+}
+
+// experimentPath returns the experiment file given as the only argument, or the -experiment flag's value
+func experimentPath(fs *flag.FlagSet, path string) (string, error) {
+	switch {
+	case fs.NArg() == 0:
+		return path, nil
+	case fs.NArg() > 1:
+		return "", fmt.Errorf("unexpected arguments %q after the experiment file (flags go first)", fs.Args()[1:])
+	}
+
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == "experiment"
+	})
+	if set {
+		return "", fmt.Errorf("experiment file given both as -experiment and as %q", fs.Arg(0))
+	}
+
+	return fs.Arg(0), nil
+}
+
+// noArgs rejects arguments left after the flags, which the command would otherwise ignore
+func noArgs(fs *flag.FlagSet) error {
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q (flags go first)", fs.Args())
+	}
+
+	return nil
+}
+
+// createOutput opens the output path for writing, or returns stdout for "" and "-"
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+// nopCloser wraps a writer that must not be closed
+type nopCloser struct {
+	io.Writer
+}
+
+// Close does nothing
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// runGenerate generates a topology and saves it as a snapshot
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	topo := &topologyFlags{}
+	topo.register(fs)
+	output := fs.String("o", "network.snap", "snapshot file to write")
+	fs.Parse(args)
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	exp, run, err := topo.singleRun(p2p.BroadcastType{Type: p2p.BasicPublish}, 0)
+	if err != nil {
+		return err
+	}
+
+	n, err := exp.BuildNetwork(run)
+	if err != nil {
+		return err
+	}
+
+	if err := n.SaveSnapshot(*output); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s: %d nodes, average degree %.2f, seed %d\n", *output, len(n.Nodes), n.AvgDegree(), n.Config.Seed)
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+)
+
+// runMigrate rewrites result files in the current schema, optionally converting between JSONL and CSV
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	output := fs.String("o", "-", "result file to write (- for stdout)")
+	format := fs.String("format", "", "jsonl or csv (default from the output's extension)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: migrate [flags] FILE [FILE ...]\n\nRewrites result rows of any schema version in the current one; see docs/results.md.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no input files")
+	}
+
+	if *format == "" {
+		*format = experiment.FormatOf(*output)
+	}
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer, err := experiment.NewResultWriter(out, *format, true)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range fs.Args() {
+		rows, err := experiment.ReadResults(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		for _, row := range rows {
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
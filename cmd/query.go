@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/store"
+)
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, " ") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// runQuery imports result files into a results store and filters or groups its rows
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	db := fs.String("db", "results/results.db", "results store file")
+	var where stringList
+	fs.Var(&where, "where", "filter such as node_count>=10000 or broadcast=WavePublish-40 (repeatable)")
+	group := fs.String("group", "", "comma-separated columns or parameters to group by")
+	metrics := fs.String("metrics", "duplicate_rate,receiving_rate", "comma-separated metrics averaged per group")
+	name := fs.String("experiment", "", "experiment name for imported rows that have none (default the file's directory name)")
+	output := fs.String("o", "-", "file to write the rows or groups to (- for stdout)")
+	format := fs.String("format", "", "jsonl or csv for row output (default a table on stdout)")
+	asJSON := fs.Bool("json", false, "write groups as JSONL instead of a table")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: query [flags] [FILE ...]\n\n"+
+			"Result files given as arguments are imported into the store first; rows already stored are skipped.\n"+
+			"Columns are those of docs/results.md; parameters are addressed by their key (e.g. mean_degree).\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	open := store.OpenReadOnly
+	if fs.NArg() > 0 {
+		open = store.Open
+	}
+
+	s, err := open(*db)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for _, path := range fs.Args() {
+		added, err := importResults(s, path, *name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintf(fs.Output(), "Imported %d new rows from %s\n", added, path)
+	}
+
+	var conditions []store.Condition
+	for _, w := range where {
+		c, err := store.ParseCondition(w)
+		if err != nil {
+			return err
+		}
+		conditions = append(conditions, c)
+	}
+
+	rows := s.Select(conditions)
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if *group != "" {
+		return writeGroups(out, store.GroupBy(rows, splitList(*group), splitList(*metrics)), splitList(*group), splitList(*metrics), *asJSON)
+	}
+
+	if *format == "" && *output != "-" {
+		*format = experiment.FormatOf(*output)
+	}
+	if *format != "" {
+		writer, err := experiment.NewResultWriter(out, *format, true)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "EXPERIMENT\tRUN\tBROADCAST\tNODES\tDUPLICATE RATE\tRECEIVING RATE")
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.4f\t%.4f%%\n", row.Experiment, row.RunID, row.Broadcast, row.NodeCount, row.DuplicateRate, row.ReceivingRate*100)
+	}
+	fmt.Fprintf(tw, "%d rows\n", len(rows))
+
+	return tw.Flush()
+}
+
+// importResults inserts the rows of a result file into the store
+// Rows without a run ID get one from their file and line so that importing a file twice adds nothing
+func importResults(s *store.Store, path, name string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+
+	rows, err := experiment.ReadResults(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if name == "" {
+		name = filepath.Base(filepath.Dir(path))
+	}
+
+	for i := range rows {
+		if rows[i].Experiment == "" {
+			rows[i].Experiment = name
+		}
+		if rows[i].RunID == "" {
+			rows[i].RunID = fmt.Sprintf("%s#%d", filepath.ToSlash(path), i+1)
+		}
+	}
+
+	return s.InsertAll(rows)
+}
+
+// writeGroups prints grouped means as a table or JSONL
+func writeGroups(out io.Writer, groups []store.Group, columns, metrics []string, asJSON bool) error {
+	if asJSON {
+		return encodeRows(out, groups)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	header := append(append([]string{}, columns...), "count")
+	for _, m := range metrics {
+		header = append(header, "avg "+m)
+	}
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+
+	for _, g := range groups {
+		fmt.Fprintf(tw, "%s\t%d", strings.Join(g.Key, "\t"), g.Count)
+		for _, m := range metrics {
+			fmt.Fprintf(tw, "\t%.4f", g.Means[m])
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
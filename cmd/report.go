@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/report"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
+)
+
+// runReport renders results and trace files into a self-contained HTML report
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	output := fs.String("o", "results/report.html", "HTML file to write (- for stdout)")
+	title := fs.String("title", "", "report title")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: report [flags] FILE [FILE ...]\n\nFILE is a results JSONL or CSV file or a trace file.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no input files")
+	}
+
+	in := report.Input{Title: *title, Sources: fs.Args()}
+
+	for _, path := range fs.Args() {
+		isTrace, err := isTraceFile(path)
+		if err != nil {
+			return err
+		}
+
+		if isTrace {
+			err = readReportTrace(&in, path)
+		} else {
+			var rows []experiment.Result
+			rows, err = experiment.ReadResults(path)
+			in.Results = append(in.Results, rows...)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return report.Write(out, in)
+}
+
+// readReportTrace adds the receipt latencies of every message in a trace file
+func readReportTrace(in *report.Input, path string) error {
+	replay, err := trace.ReplayFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, mid := range replay.MessageIDs() {
+		latencies := replay.Messages[mid].Latencies()
+
+		ms := make([]float64, len(latencies))
+		for i, l := range latencies {
+			ms[i] = float64(l) / float64(time.Millisecond)
+		}
+
+		in.Traces = append(in.Traces, report.Trace{
+			Name:      fmt.Sprintf("%s #%d (%s)", replay.Header.Broadcast, mid, filepath.Base(path)),
+			Latencies: ms,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/emulate"
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// runRun runs a single broadcast and writes its result row as JSON or CSV
+func runRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	topo := &topologyFlags{}
+	topo.register(fs)
+	protocol := fs.String("protocol", p2p.BasicPublish, "broadcast type, e.g. BasicPublish, WavePublish-30 or Dandelion-10")
+	origin := fs.Int("origin", 0, "index of the node that starts the broadcast")
+	engine := fs.String("engine", experiment.EngineRealtime, "execution engine: realtime, pool, sim, parallel or socket")
+	workers := fs.Int("workers", 0, "pool workers or parallel partitions (0 uses the engine default)")
+	maxInFlight := fs.Int("max-inflight", 0, "maximum in-flight transmissions for the pool engine (0 is unlimited)")
+	transport := fs.String("transport", emulate.UDP, "socket engine transport: tcp or udp")
+	payload := fs.Int("payload", 0, "payload bytes carried by the message, counted in the bytes metric")
+	traceDir := fs.String("trace", "", "directory to write the broadcast event log to")
+	output := fs.String("o", "-", "result file to write (- for stdout)")
+	format := fs.String("format", "", "jsonl or csv (default from the output's extension)")
+	fs.Parse(args)
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	bt, err := p2p.ParseBroadcastType(*protocol)
+	if err != nil {
+		return err
+	}
+
+	exp, run, err := topo.singleRun(bt, *origin)
+	if err != nil {
+		return err
+	}
+
+	exp.Engine = experiment.Engine{Type: *engine, Workers: *workers, MaxInFlight: *maxInFlight, Transport: *transport}
+	if *payload != 0 {
+		exp.Topology.Grid["payload_bytes"] = experiment.Values{float64(*payload)}
+		run.Params["payload_bytes"] = float64(*payload)
+	}
+	if err := exp.Validate(); err != nil {
+		return err
+	}
+
+	result, err := exp.Execute(run, *traceDir, nil)
+	if err != nil {
+		return err
+	}
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if *format == "" {
+		*format = experiment.FormatOf(*output)
+	}
+
+	writer, err := experiment.NewResultWriter(out, *format, true)
+	if err != nil {
+		return err
+	}
+
+	return writer.Write(result)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/server"
+)
+
+// runServe serves the HTTP/JSON control API until the process is stopped
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	jobs := fs.Int("j", 1, "number of concurrent runs")
+	fs.Parse(args)
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Serving the control API on http://%s\n", listener.Addr())
+
+	return http.Serve(listener, server.New(server.NewRegistry(*jobs)))
+}
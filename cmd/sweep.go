@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/monitor"
+	"github.com/elecbug/p2p-broadcast-tester/internal/store"
+)
+
+// runSweep runs every configuration of an experiment file
+func runSweep(args []string) error {
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	path := fs.String("experiment", "experiments/default.json", "experiment file to run")
+	output := fs.String("o", "", "result file (overrides the experiment's output)")
+	seed := fs.Int64("seed", 0, "base seed (overrides the experiment's seed)")
+	reps := fs.Int("reps", 0, "repetitions per configuration (overrides the experiment's repetitions)")
+	traceDir := fs.String("trace", "", "directory to write broadcast event logs to")
+	jobs := fs.Int("j", 1, "number of concurrent runs")
+	storePath := fs.String("store", "", "results store to insert every row into as well")
+	memoryMB := fs.Int64("mem", 0, "estimated memory budget in MiB shared by concurrent runs (0 is unlimited)")
+	resume := fs.Bool("resume", true, "skip runs already recorded in the output's manifest")
+	metricsAddr := fs.String("metrics", "", "address to serve Prometheus metrics on at /metrics while sweeping (empty disables)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: sweep [flags] [FILE]\n\nFILE is the experiment file, as an alternative to -experiment.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	file, err := experimentPath(fs, *path)
+	if err != nil {
+		return err
+	}
+
+	exp, err := experiment.Load(file)
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		exp.Output = *output
+	}
+	if *seed != 0 {
+		exp.Seed = *seed
+	}
+	if *reps > 0 {
+		exp.Repetitions = *reps
+	}
+
+	opts := experiment.RunOptions{
+		TraceDir:    *traceDir,
+		Log:         os.Stdout,
+		Concurrency: *jobs,
+		MemoryLimit: *memoryMB << 20,
+		Resume:      *resume,
+	}
+
+	if *storePath != "" {
+		s, err := store.Open(*storePath)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		opts.Mirror = s
+	}
+
+	if *metricsAddr != "" {
+		opts.Monitor = monitor.New()
+
+		listener, err := net.Listen("tcp", *metricsAddr)
+		if err != nil {
+			return fmt.Errorf("serving metrics: %w", err)
+		}
+		defer listener.Close()
+
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", opts.Monitor)
+		go http.Serve(listener, mux)
+
+		fmt.Printf("Serving metrics on http://%s/metrics\n", listener.Addr())
+	}
+
+	if err := exp.RunAll(opts); err != nil {
+		return fmt.Errorf("running experiment: %w", err)
+	}
+
+	return nil
+}
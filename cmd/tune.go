@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/store"
+)
+
+// runTune searches a protocol parameter as described by the tune section of an experiment file
+func runTune(args []string) error {
+	fs := flag.NewFlagSet("tune", flag.ExitOnError)
+	path := fs.String("experiment", "experiments/tune-wave.json", "experiment file with a tune section")
+	output := fs.String("o", "", "result file for the trials (overrides the experiment's output)")
+	report := fs.String("report", "-", "file to write the tuning report to (- for stdout)")
+	seed := fs.Int64("seed", 0, "base seed (overrides the experiment's seed)")
+	jobs := fs.Int("j", 1, "number of concurrent runs")
+	storePath := fs.String("store", "", "results store to insert every row into as well")
+	memoryMB := fs.Int64("mem", 0, "estimated memory budget in MiB shared by concurrent runs (0 is unlimited)")
+	asJSON := fs.Bool("json", false, "write the report as JSON instead of tables")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tune [flags] [FILE]\n\nFILE is the experiment file, as an alternative to -experiment.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	file, err := experimentPath(fs, *path)
+	if err != nil {
+		return err
+	}
+
+	exp, err := experiment.Load(file)
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		exp.Output = *output
+	}
+	if *seed != 0 {
+		exp.Seed = *seed
+	}
+
+	opts := experiment.RunOptions{
+		Log:         os.Stderr,
+		Concurrency: *jobs,
+		MemoryLimit: *memoryMB << 20,
+	}
+
+	if *storePath != "" {
+		s, err := store.Open(*storePath)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		opts.Mirror = s
+	}
+
+	result, err := exp.Tune(opts)
+	if err != nil {
+		return fmt.Errorf("tuning: %w", err)
+	}
+
+	out, err := createOutput(*report)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if *asJSON {
+		return encodeRows(out, []*experiment.TuneResult{result})
+	}
+
+	return writeTuneReport(out, exp.Tuning, result)
+}
+
+// writeTuneReport prints the evaluated values, the best value and the Pareto frontier
+func writeTuneReport(out io.Writer, t *experiment.Tuning, result *experiment.TuneResult) error {
+	c := t.Constraint
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s %s, minimize %s subject to %s %s %v in %v%% of trials\n",
+		t.Protocol, t.Param, t.Objective, c.Metric, c.Op, c.Value, c.Percentile)
+	fmt.Fprintf(tw, "%s\tTRIALS\tFEASIBLE\tBOUND\tOBJECTIVE\n", t.Param)
+	for _, ev := range result.Evaluations {
+		fmt.Fprintf(tw, "%v\t%d\t%t\t%.4f\t%.4f\n", ev.Value, ev.Aggregate.Trials, ev.Feasible, ev.Bound, ev.Objective)
+	}
+
+	if result.Best != nil {
+		fmt.Fprintf(tw, "\nBest: %s=%v (%s %.4f)\n", t.Param, result.Best.Value, t.Objective, result.Best.Objective)
+	} else {
+		fmt.Fprintf(tw, "\nNo value in [%v, %v] meets the constraint\n", t.Min, t.Max)
+	}
+
+	fmt.Fprintln(tw, "\nPareto frontier")
+	writePareto(tw, result.Pareto)
+
+	return tw.Flush()
+}
+
+// writePareto prints the mean metrics of Pareto-optimal configurations
+func writePareto(tw *tabwriter.Writer, frontier []experiment.Aggregate) {
+	fmt.Fprintln(tw, "CONFIG\tTRIALS\tDUPLICATE RATE\tRECEIVING RATE\tP99 MS")
+	for _, agg := range frontier {
+		fmt.Fprintf(tw, "%s\t%d\t%.4f\t%.6f\t%.2f\n", agg.Config, agg.Trials,
+			agg.Metrics["duplicate_rate"].Mean, agg.Metrics["receiving_rate"].Mean, agg.Metrics["latency_p99_ms"].Mean)
+	}
+}
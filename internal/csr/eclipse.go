@@ -0,0 +1,149 @@
+package csr
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// EclipseConfig describes a Sybil attack on the connection slots of a victim or a region
+type EclipseConfig struct {
+	Sybils      int     // Attacker nodes appended after the existing nodes
+	Victims     int     // Number of victims, taken in breadth-first order around Center
+	Center      int32   // First victim (negative picks a random node)
+	Occupancy   float64 // Share of each victim's links to non-victims that Sybils take over (0 to 1)
+	Anchors     int     // Links to non-victims per victim that Sybils cannot evict, a peer-selection defense
+	SybilDegree int     // Links from each Sybil to random non-victims, so the attacker sees broadcasts
+	Protected   []int32 // Nodes that never become victims, such as the broadcast origin
+	Seed        int64   // Random seed for victim, eviction and Sybil link choices
+}
+
+// Eclipse returns a copy of the graph in which Sybil nodes occupy the connection slots of the victims
+// The Sybils get the indices following the existing nodes. Each victim keeps its degree: every evicted
+// link to an honest peer is replaced by a link to a distinct Sybil with the same delay, so a victim
+// cannot lose more links than there are Sybils. Links between victims are never evicted, which lets
+// a region be cut off from the rest of the network as a whole. It also returns the victims, and an
+// error if the center is not a node of the graph
+func (g *Graph) Eclipse(config EclipseConfig) (*Graph, []int32, error) {
+	rng := rand.New(rand.NewSource(config.Seed))
+	count := g.NodeCount()
+
+	victims, err := g.region(config, rng)
+	if err != nil {
+		return nil, nil, err
+	}
+	isVictim := make(map[int32]bool, len(victims))
+	for _, v := range victims {
+		isVictim[v] = true
+	}
+
+	rows := append(g.rows(), make([][]arc, config.Sybils)...)
+	nodeDelays := append(append([]uint32{}, g.NodeDelays...), make([]uint32, config.Sybils)...)
+
+	for _, v := range victims {
+		var outside []arc
+		for _, a := range rows[v] {
+			if !isVictim[a.to] {
+				outside = append(outside, a)
+			}
+		}
+
+		// The first Anchors peers of the shuffled list survive the attack
+		rng.Shuffle(len(outside), func(i, j int) {
+			outside[i], outside[j] = outside[j], outside[i]
+		})
+		anchors := min(config.Anchors, len(outside))
+		evict := int(math.Round(config.Occupancy * float64(len(outside))))
+		evict = min(evict, len(outside)-anchors, config.Sybils)
+
+		first := 0
+		if config.Sybils > 0 {
+			first = rng.Intn(config.Sybils)
+		}
+
+		for k := 0; k < evict; k++ {
+			peer := outside[anchors+k]
+			sybil := int32(count + (first+k)%config.Sybils)
+
+			removeLink(rows, v, peer.to)
+			addLink(rows, v, sybil, peer.delay)
+		}
+	}
+
+	// Sybils also join the honest part of the network to observe broadcasts
+	for s := count; s < len(rows); s++ {
+		for links, attempts := 0, 0; links < config.SybilDegree && attempts < 10*config.SybilDegree; attempts++ {
+			target := int32(rng.Intn(count))
+			d := uint32(randomDelay(rng, uint64(g.Config.MinLinkDelay), uint64(g.Config.MaxLinkDelay)))
+
+			if !isVictim[target] && addLink(rows, int32(s), target, d) {
+				links++
+			}
+		}
+	}
+
+	eclipsed := compact(rows, nodeDelays)
+	eclipsed.Config = g.Config
+
+	return eclipsed, victims, nil
+}
+
+// region returns up to config.Victims unprotected nodes in breadth-first order from the center
+func (g *Graph) region(config EclipseConfig, rng *rand.Rand) ([]int32, error) {
+	count := g.NodeCount()
+	if config.Victims <= 0 || count == 0 {
+		return nil, nil
+	}
+	if int(config.Center) >= count {
+		return nil, fmt.Errorf("victim %d out of range for %d nodes", config.Center, count)
+	}
+
+	protected := make(map[int32]bool, len(config.Protected))
+	for _, p := range config.Protected {
+		protected[p] = true
+	}
+
+	center := config.Center
+	for attempts := 0; (center < 0 || protected[center]) && attempts < 10*count; attempts++ {
+		center = int32(rng.Intn(count))
+	}
+	if center < 0 || protected[center] {
+		return nil, fmt.Errorf("no unprotected node to eclipse")
+	}
+
+	victims := make([]int32, 0, config.Victims)
+	visited := map[int32]bool{center: true}
+
+	for queue := []int32{center}; len(queue) > 0 && len(victims) < config.Victims; queue = queue[1:] {
+		i := queue[0]
+		if !protected[i] {
+			victims = append(victims, i)
+		}
+
+		neighbors, _ := g.Row(i)
+		for _, j := range neighbors {
+			if !visited[j] {
+				visited[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	return victims, nil
+}
+
+// rows unpacks the graph into adjacency rows that can be modified
+func (g *Graph) rows() [][]arc {
+	rows := make([][]arc, g.NodeCount())
+
+	for i := range rows {
+		neighbors, delays := g.Row(int32(i))
+
+		rows[i] = make([]arc, len(neighbors))
+		for k := range neighbors {
+			rows[i][k] = arc{to: neighbors[k], delay: delays[k]}
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,46 @@
+package csr
+
+import (
+	"testing"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/network"
+)
+
+func TestEclipseVictims(t *testing.T) {
+	g := GenerateLimitDegree(network.NetworkConfig{NodeCount: 100, D: 8, DLow: 6, DHigh: 10, MaxLinkDelay: 50, MaxNodeDelay: 100, Seed: 1})
+
+	tests := []struct {
+		name    string
+		center  int32
+		victims int
+		ok      bool
+	}{
+		{"center", 5, 10, true},
+		{"random center", -1, 10, true},
+		{"last node", 99, 1, true},
+		{"beyond nodes", 100, 1, false},
+		{"far beyond nodes", 500, 10, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eclipsed, victims, err := g.Eclipse(EclipseConfig{Sybils: 4, Victims: tt.victims, Center: tt.center, Occupancy: 1, Seed: 1})
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("eclipsed %d victims", len(victims))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(victims) != tt.victims {
+				t.Errorf("got %d victims, want %d", len(victims), tt.victims)
+			}
+			if eclipsed.NodeCount() != g.NodeCount()+4 {
+				t.Errorf("got %d nodes, want %d", eclipsed.NodeCount(), g.NodeCount()+4)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package csr
+
+import (
+	"container/heap"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/network"
+)
+
+// GenerateLimitDegree creates a degree-limited graph directly in compact form
+// It draws the same links as network.GenerateLimitDegreeNetwork with the same seed, without
+// allocating node structures. Each round visits only the nodes outside the degree bounds, in
+// ascending order, where the pointer generator scans every node. After
+// network.RandomRemovalRounds rounds, nodes above DHigh unlink random neighbors instead of
+// random nodes, so every removal succeeds and the rounds stay few as the node count grows
+func GenerateLimitDegree(config network.NetworkConfig) *Graph {
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(config.Seed))
+	count := config.NodeCount
+
+	nodeDelays := make([]uint32, count)
+	for i := range nodeDelays {
+		nodeDelays[i] = uint32(randomDelay(rng, uint64(config.MinNodeDelay), uint64(config.MaxNodeDelay)))
+	}
+
+	rows := make([][]arc, count)
+	outside := func(i int32) bool {
+		return len(rows[i]) < config.DLow || len(rows[i]) > config.DHigh
+	}
+
+	// Nodes to visit in the current round, in ascending order, and in the next one
+	current := &nodeHeap{}
+	var next []int32
+	queued := make([]int32, count) // Round+1 a node is queued for, 0 if none
+	for i := range int32(count) {
+		if outside(i) {
+			current.ids = append(current.ids, i)
+			queued[i] = 1
+		}
+	}
+
+	// changed queues a node whose degree changed while visiting node i, where a full scan would see it
+	changed := func(re int, i, j int32) {
+		if !outside(j) {
+			return
+		}
+		if j > i && queued[j] != int32(re)+1 {
+			heap.Push(current, j)
+			queued[j] = int32(re) + 1
+		} else if j <= i && queued[j] != int32(re)+2 {
+			next = append(next, j)
+			queued[j] = int32(re) + 2
+		}
+	}
+
+	// Iteratively adjust node degrees to meet constraints
+	for re := 0; re < count; re++ {
+		flag := false
+
+		for current.Len() > 0 {
+			i := heap.Pop(current).(int32)
+
+			// Add connections if degree is below minimum threshold
+			if len(rows[i]) < config.DLow {
+				for j := 0; j < config.D-len(rows[i]); j++ {
+					target := int32(rng.Uint64() % uint64(count))
+					d := uint32(randomDelay(rng, uint64(config.MinLinkDelay), uint64(config.MaxLinkDelay)))
+
+					if !addLink(rows, i, target, d) {
+						j-- // Retry if connection could not be made
+						flag = true
+						continue
+					}
+					changed(re, i, target)
+				}
+			}
+
+			// Remove connections if degree is above maximum threshold
+			// As in the pointer generator, early rounds draw random nodes and only unlink neighbors
+			if len(rows[i]) > config.DHigh {
+				for j := 0; j < len(rows[i])-config.D; j++ {
+					var target int32
+					if re < network.RandomRemovalRounds {
+						target = int32(rng.Uint64() % uint64(count))
+					} else {
+						target = neighbor(rows[i], rng.Uint64()%uint64(len(rows[i])))
+					}
+
+					if removeLink(rows, i, target) {
+						changed(re, i, target)
+					}
+					flag = true
+				}
+			}
+
+			changed(re, i, i)
+		}
+
+		if !flag {
+			break // No changes made, exit early
+		}
+
+		current.ids, next = next, current.ids[:0]
+		heap.Init(current)
+	}
+
+	g := compact(rows, nodeDelays)
+	g.Config = config
+
+	return g
+}
+
+// nodeHeap is a min-heap of node indices
+type nodeHeap struct {
+	ids []int32
+}
+
+func (h *nodeHeap) Len() int           { return len(h.ids) }
+func (h *nodeHeap) Less(a, b int) bool { return h.ids[a] < h.ids[b] }
+func (h *nodeHeap) Swap(a, b int)      { h.ids[a], h.ids[b] = h.ids[b], h.ids[a] }
+func (h *nodeHeap) Push(x any)         { h.ids = append(h.ids, x.(int32)) }
+
+func (h *nodeHeap) Pop() any {
+	last := h.ids[len(h.ids)-1]
+	h.ids = h.ids[:len(h.ids)-1]
+
+	return last
+}
+
+// addLink creates a bidirectional link, returning false for existing links
+// A node drawn as its own target gets a self-link, a single arc, as in the pointer generator
+func addLink(rows [][]arc, a, b int32, d uint32) bool {
+	if findArc(rows[a], b) >= 0 {
+		return false
+	}
+
+	rows[a] = append(rows[a], arc{to: b, delay: d})
+	if a != b {
+		rows[b] = append(rows[b], arc{to: a, delay: d})
+	}
+
+	return true
+}
+
+// removeLink deletes a bidirectional link and reports whether it existed
+func removeLink(rows [][]arc, a, b int32) bool {
+	k := findArc(rows[a], b)
+	if k < 0 {
+		return false
+	}
+
+	rows[a] = append(rows[a][:k], rows[a][k+1:]...)
+	if k := findArc(rows[b], a); k >= 0 {
+		rows[b] = append(rows[b][:k], rows[b][k+1:]...)
+	}
+
+	return true
+}
+
+// neighbor returns the k-th target of a row in ascending order
+func neighbor(row []arc, k uint64) int32 {
+	ids := make([]int32, len(row))
+	for n := range row {
+		ids[n] = row[n].to
+	}
+	slices.Sort(ids)
+
+	return ids[k]
+}
+
+// findArc returns the position of the arc to target in an unsorted row, or -1
+func findArc(row []arc, target int32) int {
+	for k := range row {
+		if row[k].to == target {
+			return k
+		}
+	}
+
+	return -1
+}
+
+// randomDelay generates a random delay value within the range [min, max]
+func randomDelay(rng *rand.Rand, min, max uint64) uint64 {
+	if min > max {
+		min, max = max, min
+	}
+
+	return rng.Uint64()%(max-min+1) + min
+}
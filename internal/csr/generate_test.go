@@ -0,0 +1,48 @@
+package csr
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/network"
+)
+
+func TestGenerateLimitDegreeMatchesNetwork(t *testing.T) {
+	configs := []network.NetworkConfig{
+		{NodeCount: 50, D: 10, DLow: 8, DHigh: 12, MaxLinkDelay: 50, MaxNodeDelay: 100},
+		{NodeCount: 2000, D: 8, DLow: 6, DHigh: 12, MinLinkDelay: 5, MaxLinkDelay: 50, MaxNodeDelay: 100},
+		{NodeCount: 3000, D: 40, DLow: 32, DHigh: 48, MaxLinkDelay: 1, MaxNodeDelay: 100},
+	}
+
+	for _, config := range configs {
+		for _, seed := range []int64{1, 7, 42} {
+			config.Seed = seed
+
+			want, err := FromNetwork(network.GenerateLimitDegreeNetwork(config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := GenerateLimitDegree(config)
+
+			if !slices.Equal(got.Offsets, want.Offsets) || !slices.Equal(got.Neighbors, want.Neighbors) {
+				t.Errorf("%d nodes, degree %d, seed %d: links differ from the pointer generator", config.NodeCount, config.D, seed)
+			}
+			if !slices.Equal(got.LinkDelays, want.LinkDelays) || !slices.Equal(got.NodeDelays, want.NodeDelays) {
+				t.Errorf("%d nodes, degree %d, seed %d: delays differ from the pointer generator", config.NodeCount, config.D, seed)
+			}
+		}
+	}
+}
+
+func BenchmarkGenerateLimitDegree(b *testing.B) {
+	for _, count := range []int{100_000, 1_000_000} {
+		b.Run(strconv.Itoa(count), func(b *testing.B) {
+			config := network.NetworkConfig{NodeCount: count, D: 40, DLow: 38, DHigh: 42, MaxLinkDelay: 50, MaxNodeDelay: 100, Seed: 1}
+
+			for range b.N {
+				GenerateLimitDegree(config)
+			}
+		})
+	}
+}
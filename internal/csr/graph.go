@@ -0,0 +1,175 @@
+package csr
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/network"
+	"github.com/elecbug/p2p-broadcast-tester/internal/node"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// Graph is a compressed-sparse-row adjacency representation of a network
+// The neighbors of node i are Neighbors[Offsets[i]:Offsets[i+1]], sorted ascending,
+// with the matching link delays at the same positions in LinkDelays.
+// Nodes are identified by their index; delays are stored in milliseconds
+type Graph struct {
+	Offsets    []int64               // Row start positions, length NodeCount+1
+	Neighbors  []int32               // Target node index of every arc
+	LinkDelays []uint32              // Transmission delay of every arc
+	NodeDelays []uint32              // Processing delay of every node
+	Config     network.NetworkConfig // Configuration the graph was generated with
+}
+
+// NodeCount returns the number of nodes in the graph
+func (g *Graph) NodeCount() int {
+	return len(g.NodeDelays)
+}
+
+// ArcCount returns the number of directed arcs (each bidirectional link counts twice)
+func (g *Graph) ArcCount() int {
+	return len(g.Neighbors)
+}
+
+// Degree returns the number of outgoing arcs of a node
+func (g *Graph) Degree(i int32) int {
+	return int(g.Offsets[i+1] - g.Offsets[i])
+}
+
+// Row returns the neighbor indices and link delays of a node
+func (g *Graph) Row(i int32) ([]int32, []uint32) {
+	start, end := g.Offsets[i], g.Offsets[i+1]
+
+	return g.Neighbors[start:end], g.LinkDelays[start:end]
+}
+
+// ArcIndex returns the position of the arc i -> j, or -1 if it does not exist
+func (g *Graph) ArcIndex(i, j int32) int64 {
+	start, end := g.Offsets[i], g.Offsets[i+1]
+	row := g.Neighbors[start:end]
+
+	k := sort.Search(len(row), func(k int) bool {
+		return row[k] >= j
+	})
+	if k < len(row) && row[k] == j {
+		return start + int64(k)
+	}
+
+	return -1
+}
+
+// AvgDegree calculates the average number of outgoing arcs per node
+func (g *Graph) AvgDegree() float64 {
+	if g.NodeCount() == 0 {
+		return 0
+	}
+
+	return float64(g.ArcCount()) / float64(g.NodeCount())
+}
+
+// MinLinkDelay returns the smallest link delay in the graph (0 for a graph without arcs)
+func (g *Graph) MinLinkDelay() uint32 {
+	if len(g.LinkDelays) == 0 {
+		return 0
+	}
+
+	m := g.LinkDelays[0]
+	for _, d := range g.LinkDelays {
+		m = min(m, d)
+	}
+
+	return m
+}
+
+// MemoryBytes estimates the memory held by the graph's arrays
+func (g *Graph) MemoryBytes() int64 {
+	return int64(len(g.Offsets))*8 + int64(len(g.Neighbors))*4 + int64(len(g.LinkDelays))*4 + int64(len(g.NodeDelays))*4
+}
+
+// FromNetwork converts a pointer-based network into a CSR graph
+// Node indices follow the order of Network.Nodes
+func FromNetwork(n *network.Network) (*Graph, error) {
+	index := make(map[*node.Node]int32, len(n.Nodes))
+	for i := range n.Nodes {
+		index[&n.Nodes[i]] = int32(i)
+	}
+
+	rows := make([][]arc, len(n.Nodes))
+	nodeDelays := make([]uint32, len(n.Nodes))
+
+	for i := range n.Nodes {
+		nodeDelays[i] = uint32(n.Nodes[i].Delay())
+
+		for conn, d := range n.Nodes[i].Connections() {
+			j, ok := index[conn]
+			if !ok {
+				return nil, fmt.Errorf("node %d is connected to a node outside the network", n.Nodes[i].ID())
+			}
+
+			rows[i] = append(rows[i], arc{to: j, delay: uint32(d)})
+		}
+	}
+
+	g := compact(rows, nodeDelays)
+	g.Config = n.Config
+
+	return g, nil
+}
+
+// ToNetwork converts the graph back into a pointer-based network
+func (g *Graph) ToNetwork() *network.Network {
+	n := &network.Network{
+		Nodes:  make([]node.Node, g.NodeCount()),
+		Config: g.Config,
+	}
+
+	for i := range n.Nodes {
+		n.Nodes[i] = *node.NewNode(p2p.NodeID(i), p2p.Delay(g.NodeDelays[i]))
+	}
+
+	for i := int32(0); i < int32(g.NodeCount()); i++ {
+		neighbors, delays := g.Row(i)
+		for k, j := range neighbors {
+			n.AddDirectConnection(uint64(i), uint64(j), p2p.Delay(delays[k]))
+		}
+	}
+
+	return n
+}
+
+// arc is a single adjacency entry used while building a graph
+type arc struct {
+	to    int32
+	delay uint32
+}
+
+// compact sorts adjacency rows and packs them into CSR arrays
+func compact(rows [][]arc, nodeDelays []uint32) *Graph {
+	total := 0
+	for _, row := range rows {
+		total += len(row)
+	}
+
+	g := &Graph{
+		Offsets:    make([]int64, len(rows)+1),
+		Neighbors:  make([]int32, 0, total),
+		LinkDelays: make([]uint32, 0, total),
+		NodeDelays: nodeDelays,
+	}
+
+	for i, row := range rows {
+		sort.Slice(row, func(a, b int) bool {
+			return row[a].to < row[b].to
+		})
+
+		for _, a := range row {
+			g.Neighbors = append(g.Neighbors, a.to)
+			g.LinkDelays = append(g.LinkDelays, a.delay)
+		}
+
+		g.Offsets[i+1] = int64(len(g.Neighbors))
+		rows[i] = nil // Release row memory as soon as it is packed
+	}
+
+	return g
+}
@@ -0,0 +1,142 @@
+package deanon
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/csr"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/sim"
+)
+
+// Estimator names
+const (
+	FirstSpy        = "first-spy"        // Honest peer that first delivered the message to a spy
+	RumorCentrality = "rumor-centrality" // Most likely root of the infected snapshot
+	Timing          = "timing"           // Best least-squares fit of the spies' receipt times
+)
+
+// Estimators lists every estimator in report order
+var Estimators = []string{FirstSpy, RumorCentrality, Timing}
+
+// Observation is what a spy learns from a broadcast: when and from whom it first received the message
+type Observation struct {
+	Spy  int32  // Observing node
+	Time uint64 // Virtual time of the first receipt in milliseconds
+	From int32  // Neighbor that delivered the first copy
+}
+
+// Config controls an evaluation of the estimators
+type Config struct {
+	Spies     float64 // Fraction of nodes that are spies
+	Runs      int     // Broadcasts per protocol, each from a random non-spy origin
+	Observers int     // Earliest spies the timing estimator uses (default 20)
+	Seed      int64   // Seed for spies, origins and the simulations
+}
+
+// Score summarizes how often an estimator found the origin of one protocol's broadcasts
+type Score struct {
+	Protocol  string  `json:"protocol"`
+	Estimator string  `json:"estimator"`
+	Runs      int     `json:"runs"`
+	Guesses   int     `json:"guesses"`         // Runs in which the estimator named a node
+	Hits      int     `json:"hits"`            // Guesses that were the origin
+	Precision float64 `json:"precision"`       // Hits per guess
+	Recall    float64 `json:"recall"`          // Hits per run
+	MeanError float64 `json:"mean_error_hops"` // Mean hop distance between guess and origin
+}
+
+// Observe collects the spies' observations of a simulated broadcast, in order of receipt
+func Observe(r *sim.Result, spies []int32) []Observation {
+	var obs []Observation
+	for _, s := range spies {
+		if r.Parent[s] >= 0 {
+			obs = append(obs, Observation{Spy: s, Time: r.FirstReceipt[s], From: r.Parent[s]})
+		}
+	}
+
+	sort.Slice(obs, func(a, b int) bool {
+		if obs[a].Time != obs[b].Time {
+			return obs[a].Time < obs[b].Time
+		}
+		return obs[a].Spy < obs[b].Spy
+	})
+
+	return obs
+}
+
+// Evaluate broadcasts every protocol config.Runs times on g and scores each estimator
+// The spies are fixed for the evaluation; every protocol sees the same sequence of origins,
+// so differences between protocols are not due to sampling
+func Evaluate(g *csr.Graph, protocols []p2p.BroadcastType, config Config) []Score {
+	if config.Observers <= 0 {
+		config.Observers = 20
+	}
+
+	rng := rand.New(rand.NewSource(config.Seed))
+	a := newAnalysis(g, pickSpies(g.NodeCount(), config.Spies, rng))
+
+	origins := make([]int32, config.Runs)
+	for i := range origins {
+		origins[i] = a.honest[rng.Intn(len(a.honest))]
+	}
+
+	var scores []Score
+	for _, bt := range protocols {
+		tally := make(map[string]*Score, len(Estimators))
+		distances := make(map[string]int, len(Estimators))
+		for _, name := range Estimators {
+			tally[name] = &Score{Protocol: bt.String(), Estimator: name, Runs: config.Runs}
+		}
+
+		for run, origin := range origins {
+			r := sim.Run(g, origin, bt, config.Seed+int64(run)+1)
+			obs := Observe(r, a.spies)
+			hops := a.hops(origin)
+
+			for name, guess := range map[string]int32{
+				FirstSpy:        a.firstSpy(obs),
+				RumorCentrality: a.rumorCentrality(r, obs),
+				Timing:          a.timing(obs, config.Observers),
+			} {
+				if guess < 0 {
+					continue
+				}
+
+				s := tally[name]
+				s.Guesses++
+				if guess == origin {
+					s.Hits++
+				}
+				distances[name] += int(max(hops[guess], 0))
+			}
+		}
+
+		for _, name := range Estimators {
+			s := tally[name]
+			if s.Guesses > 0 {
+				s.Precision = float64(s.Hits) / float64(s.Guesses)
+				s.MeanError = float64(distances[name]) / float64(s.Guesses)
+			}
+			if s.Runs > 0 {
+				s.Recall = float64(s.Hits) / float64(s.Runs)
+			}
+
+			scores = append(scores, *s)
+		}
+	}
+
+	return scores
+}
+
+// pickSpies draws round(fraction * count) distinct spies, leaving at least one honest node
+func pickSpies(count int, fraction float64, rng *rand.Rand) []int32 {
+	n := min(int(fraction*float64(count)+0.5), count-1)
+	spies := make([]int32, 0, max(n, 0))
+
+	for _, i := range rng.Perm(count)[:max(n, 0)] {
+		spies = append(spies, int32(i))
+	}
+
+	return spies
+}
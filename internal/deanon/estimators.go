@@ -0,0 +1,244 @@
+package deanon
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/csr"
+	"github.com/elecbug/p2p-broadcast-tester/internal/sim"
+)
+
+// analysis holds what the adversary knows besides the observations: the graph and its own spies
+type analysis struct {
+	g      *csr.Graph
+	spies  []int32
+	isSpy  []bool
+	honest []int32 // Nodes that are not spies, the possible origins
+
+	delays map[int32][]uint32 // Cached shortest delays to each spy used by the timing estimator
+}
+
+// newAnalysis prepares the estimators for a graph and a set of spies
+func newAnalysis(g *csr.Graph, spies []int32) *analysis {
+	a := &analysis{g: g, spies: spies, isSpy: make([]bool, g.NodeCount()), delays: make(map[int32][]uint32)}
+
+	for _, s := range spies {
+		a.isSpy[s] = true
+	}
+	for i := range a.isSpy {
+		if !a.isSpy[i] {
+			a.honest = append(a.honest, int32(i))
+		}
+	}
+
+	return a
+}
+
+// firstSpy guesses the non-spy neighbor that delivered the message to a spy first
+// It returns -1 if no spy received the message from a non-spy
+func (a *analysis) firstSpy(obs []Observation) int32 {
+	for _, o := range obs {
+		if !a.isSpy[o.From] {
+			return o.From
+		}
+	}
+
+	return -1
+}
+
+// rumorCentrality guesses the non-spy with the highest rumor centrality in the infected snapshot
+// It models a stronger adversary that learns which nodes hold the message at the time the first
+// spy receives it. As in the breadth-first heuristic of Shah and Zaman, the centrality of every
+// candidate is computed on a breadth-first spanning tree of the snapshot rooted at that candidate
+func (a *analysis) rumorCentrality(r *sim.Result, obs []Observation) int32 {
+	if len(obs) == 0 {
+		return -1
+	}
+
+	snapshot := obs[0].Time
+
+	var infected []int32
+	for i, t := range r.FirstReceipt {
+		if t <= snapshot {
+			infected = append(infected, int32(i))
+		}
+	}
+
+	// Bound the quadratic cost on large snapshots by considering the earliest candidates only
+	sort.Slice(infected, func(x, y int) bool {
+		return r.FirstReceipt[infected[x]] < r.FirstReceipt[infected[y]]
+	})
+
+	best, bestR := int32(-1), math.Inf(-1)
+	for k, v := range infected {
+		if k >= maxRumorCandidates {
+			break
+		}
+		if a.isSpy[v] {
+			continue
+		}
+
+		if logR := a.logRumor(v, r.FirstReceipt, snapshot); logR > bestR {
+			best, bestR = v, logR
+		}
+	}
+
+	return best
+}
+
+// maxRumorCandidates caps the number of roots rumorCentrality evaluates
+const maxRumorCandidates = 256
+
+// logRumor returns log R(v) - log n! on the breadth-first tree rooted at v over the nodes
+// received by the snapshot time, that is -sum log t_u over the subtree sizes t_u
+func (a *analysis) logRumor(v int32, firstReceipt []uint64, snapshot uint64) float64 {
+	order := []int32{v}
+	parent := map[int32]int32{v: -1}
+
+	for k := 0; k < len(order); k++ {
+		neighbors, _ := a.g.Row(order[k])
+		for _, j := range neighbors {
+			if _, ok := parent[j]; ok || firstReceipt[j] > snapshot {
+				continue
+			}
+			parent[j] = order[k]
+			order = append(order, j)
+		}
+	}
+
+	size := make(map[int32]float64, len(order))
+	logR := 0.0
+	for k := len(order) - 1; k >= 0; k-- {
+		i := order[k]
+		size[i]++
+		logR -= math.Log(size[i])
+		if p := parent[i]; p >= 0 {
+			size[p] += size[i]
+		}
+	}
+
+	return logR
+}
+
+// timing guesses the non-spy whose shortest-path delays to the earliest spies best explain their
+// receipt times. With an unknown start time t0 and independent Gaussian errors, the maximum
+// likelihood origin minimizes the sum of squared residuals (t_s - d(v, s)) - t0 with t0 fitted
+// as their mean. Path delays include the processing delay of every sending node
+func (a *analysis) timing(obs []Observation, observers int) int32 {
+	obs = obs[:min(len(obs), observers)]
+	if len(obs) == 0 {
+		return -1
+	}
+
+	dists := make([][]uint32, len(obs))
+	for k, o := range obs {
+		if a.delays[o.Spy] == nil {
+			a.delays[o.Spy] = a.delaysTo(o.Spy)
+		}
+		dists[k] = a.delays[o.Spy]
+	}
+
+	best, bestCost := int32(-1), math.Inf(1)
+	residuals := make([]float64, len(obs))
+
+	for _, v := range a.honest {
+		mean := 0.0
+		reachable := true
+		for k, o := range obs {
+			if dists[k][v] == math.MaxUint32 {
+				reachable = false
+				break
+			}
+			residuals[k] = float64(o.Time) - float64(dists[k][v])
+			mean += residuals[k]
+		}
+		if !reachable {
+			continue
+		}
+		mean /= float64(len(obs))
+
+		cost := 0.0
+		for _, res := range residuals {
+			cost += (res - mean) * (res - mean)
+		}
+
+		if cost < bestCost {
+			best, bestCost = v, cost
+		}
+	}
+
+	return best
+}
+
+// delaysTo returns the shortest propagation delay from every node to target (MaxUint32 if unreachable)
+// A path v = x0, ..., xk = target costs the processing and link delay of every hop
+func (a *analysis) delaysTo(target int32) []uint32 {
+	dist := make([]uint32, a.g.NodeCount())
+	for i := range dist {
+		dist[i] = math.MaxUint32
+	}
+	dist[target] = 0
+
+	q := &distQueue{{node: target}}
+	for q.Len() > 0 {
+		e := heap.Pop(q).(distEntry)
+		if e.dist > dist[e.node] {
+			continue // Stale entry
+		}
+
+		neighbors, delays := a.g.Row(e.node)
+		for k, j := range neighbors {
+			d := e.dist + delays[k] + a.g.NodeDelays[j]
+			if d < dist[j] {
+				dist[j] = d
+				heap.Push(q, distEntry{node: j, dist: d})
+			}
+		}
+	}
+
+	return dist
+}
+
+// hops returns the hop distance of every node from origin (-1 if unreachable)
+func (a *analysis) hops(origin int32) []int32 {
+	hops := make([]int32, a.g.NodeCount())
+	for i := range hops {
+		hops[i] = -1
+	}
+	hops[origin] = 0
+
+	for queue := []int32{origin}; len(queue) > 0; queue = queue[1:] {
+		i := queue[0]
+		neighbors, _ := a.g.Row(i)
+		for _, j := range neighbors {
+			if hops[j] < 0 {
+				hops[j] = hops[i] + 1
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	return hops
+}
+
+// distEntry is a tentative distance in the Dijkstra queue
+type distEntry struct {
+	node int32
+	dist uint32
+}
+
+// distQueue is a min-heap of tentative distances
+type distQueue []distEntry
+
+func (q distQueue) Len() int           { return len(q) }
+func (q distQueue) Less(i, j int) bool { return q[i].dist < q[j].dist }
+func (q distQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *distQueue) Push(x any)        { *q = append(*q, x.(distEntry)) }
+func (q *distQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return e
+}
@@ -0,0 +1,383 @@
+package emulate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/network"
+	"github.com/elecbug/p2p-broadcast-tester/internal/node"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/wire"
+)
+
+// Transports carrying wire envelopes between emulated nodes
+const (
+	TCP = "tcp" // One stream per directed link, envelopes prefixed with their length
+	UDP = "udp" // One datagram per envelope; the kernel may drop datagrams under load
+)
+
+// Config contains parameters for the socket emulation
+type Config struct {
+	Transport string        // TCP or UDP (default UDP)
+	Idle      time.Duration // Frames still missing after no frame arrived for this long are lost (default 5s)
+}
+
+// Stats counts the traffic of an emulator
+type Stats struct {
+	Frames int64 // Frames written to sockets
+	Bytes  int64 // Bytes written to sockets, including TCP length prefixes
+	Lost   int64 // Frames that never arrived, or could not be sent
+}
+
+// Emulator is an executor that carries every transmission over a loopback socket
+// Each node listens on its own port of 127.0.0.1. A transmission is held by the shaping
+// layer for the link delay, then encoded and written to the receiver's socket; the receiver
+// decodes it and hands it to node.Receive, so the protocols run unchanged. Processing delays
+// are slept inline as in node.GoroutineExecutor
+type Emulator struct {
+	config    Config
+	nodes     map[p2p.NodeID]*node.Node
+	endpoints map[*node.Node]*endpoint
+	flights   sync.Map // Transmissions in flight per message ID (*flight)
+	stats     Stats
+	closed    atomic.Bool
+	wg        sync.WaitGroup // Reader goroutines
+}
+
+// endpoint is the socket of a single node
+type endpoint struct {
+	node     *node.Node
+	addr     net.Addr
+	listener net.Listener // TCP
+	packet   *net.UDPConn // UDP, also used to send
+
+	mu    sync.Mutex
+	conns map[*node.Node]*stream // Outgoing TCP streams, dialed on first use
+}
+
+// stream is an outgoing TCP connection whose frames must not interleave
+type stream struct {
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+// flight tracks the transmissions of one message
+type flight struct {
+	pending atomic.Int64 // Transmissions and publications not yet handled
+	sent    atomic.Int64 // Frames written to sockets
+	arrived atomic.Int64 // Frames read from sockets
+}
+
+// Start opens a loopback socket for every node of a network
+func Start(n *network.Network, config Config) (*Emulator, error) {
+	if config.Transport == "" {
+		config.Transport = UDP
+	}
+	if config.Idle <= 0 {
+		config.Idle = 5 * time.Second
+	}
+	if config.Transport != TCP && config.Transport != UDP {
+		return nil, fmt.Errorf("unknown transport %q", config.Transport)
+	}
+
+	raiseFileLimit()
+
+	e := &Emulator{
+		config:    config,
+		nodes:     make(map[p2p.NodeID]*node.Node, len(n.Nodes)),
+		endpoints: make(map[*node.Node]*endpoint, len(n.Nodes)),
+	}
+
+	for i := range n.Nodes {
+		nd := &n.Nodes[i]
+		ep, err := e.listen(nd)
+		if err != nil {
+			e.Close()
+			return nil, fmt.Errorf("node %d: %w", nd.ID(), err)
+		}
+
+		e.nodes[nd.ID()] = nd
+		e.endpoints[nd] = ep
+	}
+
+	// Readers start once the endpoints are known, since they look up senders
+	for _, ep := range e.endpoints {
+		e.wg.Add(1)
+		if ep.listener != nil {
+			go e.accept(ep)
+		} else {
+			go e.readPackets(ep)
+		}
+	}
+
+	return e, nil
+}
+
+// listen opens the socket of a node
+func (e *Emulator) listen(nd *node.Node) (*endpoint, error) {
+	ep := &endpoint{node: nd, conns: make(map[*node.Node]*stream)}
+
+	switch e.config.Transport {
+	case TCP:
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		ep.listener, ep.addr = l, l.Addr()
+	case UDP:
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			return nil, err
+		}
+		conn.SetReadBuffer(4 << 20) // Absorb bursts of flooding
+		ep.packet, ep.addr = conn, conn.LocalAddr()
+	}
+
+	return ep, nil
+}
+
+// accept serves the incoming TCP streams of a node
+func (e *Emulator) accept(ep *endpoint) {
+	defer e.wg.Done()
+
+	for {
+		conn, err := ep.listener.Accept()
+		if err != nil {
+			return // Closed
+		}
+
+		e.wg.Add(1)
+		go e.readStream(ep, conn)
+	}
+}
+
+// readStream reads length-prefixed envelopes from a TCP stream
+func (e *Emulator) readStream(ep *endpoint, conn net.Conn) {
+	defer e.wg.Done()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		env, _, err := wire.ReadFrame(r)
+		if err != nil {
+			return // Closed, or the stream lost its framing
+		}
+
+		e.handle(ep, env)
+	}
+}
+
+// readPackets reads one envelope per UDP datagram
+func (e *Emulator) readPackets(ep *endpoint) {
+	defer e.wg.Done()
+
+	buf := make([]byte, wire.MaxFrame)
+	for {
+		n, _, err := ep.packet.ReadFromUDP(buf)
+		if err != nil {
+			if e.closed.Load() || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		env, err := wire.Decode(append([]byte(nil), buf[:n]...)) // The payload aliases the copy
+		if err != nil {
+			continue // Not ours
+		}
+
+		e.handle(ep, env)
+	}
+}
+
+// handle lets the receiving node process a decoded envelope in its own goroutine
+func (e *Emulator) handle(ep *endpoint, env wire.Envelope) {
+	from := e.nodes[env.From]
+	if from == nil {
+		return // Not ours
+	}
+
+	msg := node.MessageOf(env)
+
+	f := e.flight(msg.ID)
+	f.arrived.Add(1)
+
+	go func() {
+		defer f.pending.Add(-1)
+
+		ep.node.Receive(e, from, msg)
+	}()
+}
+
+// Process sleeps for the processing delay and runs the task in the calling goroutine
+func (e *Emulator) Process(n *node.Node, delay p2p.Delay, task func()) {
+	time.Sleep(time.Duration(delay) * time.Millisecond)
+
+	task()
+}
+
+// Transmit shapes a transmission: it is held for the link delay, then written to the receiver's socket
+func (e *Emulator) Transmit(from, to *node.Node, delay p2p.Delay, msg node.Message) {
+	f := e.flight(msg.ID)
+	f.pending.Add(1)
+
+	time.AfterFunc(time.Duration(delay)*time.Millisecond, func() {
+		f.sent.Add(1) // Before writing, so the frame cannot arrive before it counts as sent
+		if err := e.send(from, to, msg); err != nil {
+			from.Drop(to, msg)
+			atomic.AddInt64(&e.stats.Lost, 1)
+			f.sent.Add(-1)
+			f.pending.Add(-1)
+		}
+	})
+}
+
+// send encodes a message and writes it to the socket of the receiver
+func (e *Emulator) send(from, to *node.Node, msg node.Message) error {
+	src, dst := e.endpoints[from], e.endpoints[to]
+	if src == nil || dst == nil || e.closed.Load() {
+		return fmt.Errorf("node not emulated")
+	}
+
+	env := msg.Envelope(from.ID())
+
+	switch e.config.Transport {
+	case TCP:
+		s, err := src.stream(to, dst.addr)
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		size, err := wire.WriteFrame(s.w, env)
+		if err != nil {
+			return err
+		}
+		if err := s.w.Flush(); err != nil {
+			return err
+		}
+
+		atomic.AddInt64(&e.stats.Bytes, int64(size))
+	case UDP:
+		buf, err := wire.Append(nil, env)
+		if err != nil {
+			return err
+		}
+		if _, err := src.packet.WriteTo(buf, dst.addr); err != nil {
+			return err
+		}
+
+		atomic.AddInt64(&e.stats.Bytes, int64(len(buf)))
+	}
+
+	atomic.AddInt64(&e.stats.Frames, 1)
+	return nil
+}
+
+// stream returns the outgoing TCP stream of an endpoint to a peer, dialing it on first use
+func (ep *endpoint) stream(to *node.Node, addr net.Addr) (*stream, error) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	if s, ok := ep.conns[to]; ok {
+		return s, nil
+	}
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		return nil, err
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(true) // Frames are small and latency-sensitive
+	}
+
+	s := &stream{conn: conn, w: bufio.NewWriter(conn)}
+	ep.conns[to] = s
+
+	return s, nil
+}
+
+// Broadcast publishes a message from a node and waits until it settles
+// It returns the number of transmissions that were lost, since the broadcast stalled without them
+func (e *Emulator) Broadcast(origin *node.Node, msg node.Message) int64 {
+	f := e.flight(msg.ID)
+	f.pending.Add(1)
+
+	go func() {
+		defer f.pending.Add(-1)
+
+		origin.PublishWith(e, msg)
+	}()
+
+	return e.wait(f)
+}
+
+// wait blocks until every transmission of a message was handled
+// Frames that were written but have not arrived after the idle time are counted as lost
+func (e *Emulator) wait(f *flight) int64 {
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	arrived, since := f.arrived.Load(), time.Now()
+	for range ticker.C {
+		if f.pending.Load() <= 0 {
+			return 0
+		}
+
+		missing := f.sent.Load() - f.arrived.Load()
+		if a := f.arrived.Load(); a != arrived || missing <= 0 {
+			arrived, since = a, time.Now() // Still progressing, or only nodes sleeping on their delays
+		} else if time.Since(since) > e.config.Idle {
+			atomic.AddInt64(&e.stats.Lost, missing)
+			return missing
+		}
+	}
+
+	return 0
+}
+
+// flight returns the in-flight counter of a message
+func (e *Emulator) flight(mid p2p.MessageID) *flight {
+	f, _ := e.flights.LoadOrStore(mid, &flight{})
+	return f.(*flight)
+}
+
+// Stats returns the traffic counted so far
+func (e *Emulator) Stats() Stats {
+	return Stats{
+		Frames: atomic.LoadInt64(&e.stats.Frames),
+		Bytes:  atomic.LoadInt64(&e.stats.Bytes),
+		Lost:   atomic.LoadInt64(&e.stats.Lost),
+	}
+}
+
+// Close closes every socket and waits for the readers to stop
+func (e *Emulator) Close() error {
+	e.closed.Store(true)
+
+	for _, ep := range e.endpoints {
+		if ep.listener != nil {
+			ep.listener.Close()
+		}
+		if ep.packet != nil {
+			ep.packet.Close()
+		}
+
+		ep.mu.Lock()
+		for _, s := range ep.conns {
+			s.conn.Close()
+		}
+		ep.mu.Unlock()
+	}
+
+	e.wg.Wait()
+	return nil
+}
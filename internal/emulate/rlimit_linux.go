@@ -0,0 +1,15 @@
+package emulate
+
+import "syscall"
+
+// raiseFileLimit lifts the soft limit on open files to the hard limit, since every node
+// holds a socket and TCP adds two per directed link
+func raiseFileLimit() {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return
+	}
+
+	limit.Cur = limit.Max
+	syscall.Setrlimit(syscall.RLIMIT_NOFILE, &limit)
+}
@@ -0,0 +1,359 @@
+package experiment
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/stats"
+)
+
+// AggregateMetrics lists the result columns summarized across repetitions
+var AggregateMetrics = []string{"duplicate_rate", "receiving_rate", "latency_p50_ms", "latency_p99_ms"}
+
+// BandwidthMetrics are additionally summarized for results that count wire bytes
+var BandwidthMetrics = []string{"bytes_per_node"}
+
+// AdversaryMetrics are additionally summarized for experiments with adversary parameters
+var AdversaryMetrics = []string{"honest_receiving_rate", "honest_duplicate_rate", "honest_latency_p50_ms", "honest_latency_p99_ms"}
+
+// EclipseMetrics are additionally summarized for experiments with eclipse victims
+var EclipseMetrics = []string{"victim_receiving_rate", "victim_latency_p50_ms", "victim_latency_max_ms"}
+
+// RecoveryMetrics are additionally summarized for experiments broadcasting several messages per run
+var RecoveryMetrics = []string{"initial_receiving_rate", "initial_latency_p99_ms", "initial_honest_receiving_rate", "initial_honest_latency_p99_ms", "pruned_links", "pruned_honest_links"}
+
+// Summary describes the distribution of one metric across the trials of a configuration
+type Summary struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	CILow  float64 `json:"ci_low"`  // Lower bound of the bootstrap confidence interval of the mean
+	CIHigh float64 `json:"ci_high"` // Upper bound of the bootstrap confidence interval of the mean
+	Min    float64 `json:"min"`
+	P05    float64 `json:"p05"`
+	P50    float64 `json:"p50"`
+	P95    float64 `json:"p95"`
+	Max    float64 `json:"max"`
+}
+
+// Aggregate summarizes every repetition of one configuration
+type Aggregate struct {
+	Config    string             `json:"config"` // Run ID without the repetition
+	Broadcast string             `json:"broadcast"`
+	Params    map[string]float64 `json:"params,omitempty"`
+	Trials    int                `json:"trials"`
+	Metrics   map[string]Summary `json:"metrics"`
+}
+
+// AggregateOptions controls the bootstrap confidence intervals
+type AggregateOptions struct {
+	Level     float64 // Confidence level (default 0.95)
+	Resamples int     // Number of bootstrap resamples (default 1000)
+	Seed      int64   // Seed of the bootstrap resampling
+}
+
+// Comparison reports whether one metric differs between two broadcast types on the same topology parameters
+type Comparison struct {
+	Shared      string  `json:"shared"` // Parameters both broadcast types were run with
+	Metric      string  `json:"metric"`
+	A           string  `json:"a"`
+	B           string  `json:"b"`
+	TrialsA     int     `json:"trials_a"`
+	TrialsB     int     `json:"trials_b"`
+	MeanA       float64 `json:"mean_a"`
+	MeanB       float64 `json:"mean_b"`
+	U           float64 `json:"u"`
+	P           float64 `json:"p"`
+	Significant bool    `json:"significant"`
+}
+
+// AggregateResults groups rows by configuration and summarizes each metric across repetitions
+func AggregateResults(rows []Result, opts AggregateOptions) []Aggregate {
+	if opts.Level <= 0 || opts.Level >= 1 {
+		opts.Level = 0.95
+	}
+	if opts.Resamples <= 0 {
+		opts.Resamples = 1000
+	}
+
+	groups := groupRows(rows, configKey)
+	aggregates := make([]Aggregate, 0, len(groups))
+	metrics := metricsOf(rows)
+
+	for _, key := range sortedKeys(groups) {
+		group := groups[key]
+		rng := rand.New(rand.NewSource(opts.Seed)) // Same resampling for every configuration
+
+		agg := Aggregate{
+			Config:    key,
+			Broadcast: group[0].Broadcast,
+			Params:    group[0].Params,
+			Trials:    len(group),
+			Metrics:   make(map[string]Summary, len(metrics)),
+		}
+
+		for _, name := range metrics {
+			values := metricValues(group, name)
+			sorted := stats.Sorted(values)
+			low, high := stats.BootstrapCI(values, opts.Level, opts.Resamples, rng)
+
+			agg.Metrics[name] = Summary{
+				Mean:   stats.Mean(values),
+				StdDev: stats.StdDev(values),
+				CILow:  low,
+				CIHigh: high,
+				Min:    stats.Quantile(sorted, 0),
+				P05:    stats.Quantile(sorted, 0.05),
+				P50:    stats.Quantile(sorted, 0.50),
+				P95:    stats.Quantile(sorted, 0.95),
+				Max:    stats.Quantile(sorted, 1),
+			}
+		}
+
+		aggregates = append(aggregates, agg)
+	}
+
+	return aggregates
+}
+
+// Compare tests every metric for a difference between two broadcast types with a Mann-Whitney U test
+// Rows are paired by their parameters excluding the protocol's own (such as level);
+// alpha is the significance level
+func Compare(rows []Result, a, b p2p.BroadcastType, alpha float64) []Comparison {
+	var rowsA, rowsB []Result
+	for _, row := range rows {
+		switch row.Broadcast {
+		case a.String():
+			rowsA = append(rowsA, row)
+		case b.String():
+			rowsB = append(rowsB, row)
+		}
+	}
+
+	groupsA := groupRows(rowsA, sharedKey)
+	groupsB := groupRows(rowsB, sharedKey)
+
+	var comparisons []Comparison
+	metrics := metricsOf(rows)
+
+	for _, key := range sortedKeys(groupsA) {
+		groupB, ok := groupsB[key]
+		if !ok {
+			continue
+		}
+		groupA := groupsA[key]
+
+		for _, name := range metrics {
+			valuesA := metricValues(groupA, name)
+			valuesB := metricValues(groupB, name)
+			test := stats.MannWhitneyU(valuesA, valuesB)
+
+			comparisons = append(comparisons, Comparison{
+				Shared:      key,
+				Metric:      name,
+				A:           a.String(),
+				B:           b.String(),
+				TrialsA:     len(valuesA),
+				TrialsB:     len(valuesB),
+				MeanA:       stats.Mean(valuesA),
+				MeanB:       stats.Mean(valuesB),
+				U:           test.U,
+				P:           test.P,
+				Significant: test.P < alpha,
+			})
+		}
+	}
+
+	return comparisons
+}
+
+// ParetoFrontier returns the aggregates no other aggregate dominates on mean duplicate rate (lower is
+// better), receiving rate (higher is better) and p99 latency (lower is better), in their original order
+func ParetoFrontier(aggregates []Aggregate) []Aggregate {
+	var frontier []Aggregate
+
+	for i, a := range aggregates {
+		dominated := false
+
+		for j, b := range aggregates {
+			if i != j && dominates(b, a) {
+				dominated = true
+				break
+			}
+		}
+
+		if !dominated {
+			frontier = append(frontier, a)
+		}
+	}
+
+	return frontier
+}
+
+// dominates reports whether a is at least as good as b on every Pareto objective and better on one
+func dominates(a, b Aggregate) bool {
+	// Negate receiving rate so that lower is better everywhere
+	objectives := [][2]float64{
+		{a.Metrics["duplicate_rate"].Mean, b.Metrics["duplicate_rate"].Mean},
+		{-a.Metrics["receiving_rate"].Mean, -b.Metrics["receiving_rate"].Mean},
+		{a.Metrics["latency_p99_ms"].Mean, b.Metrics["latency_p99_ms"].Mean},
+	}
+
+	better := false
+	for _, o := range objectives {
+		if o[0] > o[1] {
+			return false
+		}
+		if o[0] < o[1] {
+			better = true
+		}
+	}
+
+	return better
+}
+
+// metricsOf returns the metrics summarized for rows, including AdversaryMetrics,
+// EclipseMetrics and RecoveryMetrics if any row has them
+func metricsOf(rows []Result) []string {
+	bandwidth, adversaries, victims, messages := false, false, false, false
+	for _, row := range rows {
+		bandwidth = bandwidth || row.Bytes > 0
+		adversaries = adversaries || row.HonestReceivingRate > 0
+		victims = victims || row.Victims > 0
+		messages = messages || row.Messages > 1
+	}
+
+	metrics := append([]string{}, AggregateMetrics...)
+	if bandwidth {
+		metrics = append(metrics, BandwidthMetrics...)
+	}
+	if adversaries {
+		metrics = append(metrics, AdversaryMetrics...)
+	}
+	if victims {
+		metrics = append(metrics, EclipseMetrics...)
+	}
+	if messages {
+		metrics = append(metrics, RecoveryMetrics...)
+	}
+
+	return metrics
+}
+
+// AllMetrics returns AggregateMetrics, BandwidthMetrics, AdversaryMetrics, EclipseMetrics and RecoveryMetrics in display order
+func AllMetrics() []string {
+	metrics := append(append(append([]string{}, AggregateMetrics...), BandwidthMetrics...), AdversaryMetrics...)
+	return append(append(metrics, EclipseMetrics...), RecoveryMetrics...)
+}
+
+// isMetric reports whether name is one of AllMetrics
+func isMetric(name string) bool {
+	for _, m := range AllMetrics() {
+		if m == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// groupRows groups rows by a key function, keeping their order within each group
+func groupRows(rows []Result, key func(Result) string) map[string][]Result {
+	groups := make(map[string][]Result)
+	for _, row := range rows {
+		k := key(row)
+		groups[k] = append(groups[k], row)
+	}
+
+	return groups
+}
+
+// configKey identifies the configuration of a row: its run ID without the repetition
+// Rows written before run IDs existed are keyed by broadcast type, node count and delay
+func configKey(row Result) string {
+	if row.RunID == "" {
+		return fmt.Sprintf("%s/node_count=%d,delay=%d", row.Broadcast, row.NodeCount, row.Delay)
+	}
+
+	if i := strings.LastIndex(row.RunID, "/rep="); i >= 0 {
+		return row.RunID[:i]
+	}
+
+	return row.RunID
+}
+
+// sharedKey identifies the parameters of a row that do not belong to its protocol
+func sharedKey(row Result) string {
+	if row.Params == nil {
+		return fmt.Sprintf("node_count=%d,delay=%d", row.NodeCount, row.Delay)
+	}
+
+	shared := make(map[string]float64, len(row.Params))
+	for k, v := range row.Params {
+		if !isProtocolKey(k) {
+			shared[k] = v
+		}
+	}
+
+	return formatParams(shared)
+}
+
+// isProtocolKey reports whether a parameter belongs to some protocol's grid
+func isProtocolKey(key string) bool {
+	for _, keys := range protocolKeys {
+		if keys[key] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// metricValues extracts one metric column from rows
+func metricValues(rows []Result, name string) []float64 {
+	values := make([]float64, len(rows))
+
+	for i, row := range rows {
+		switch name {
+		case "duplicate_rate":
+			values[i] = row.DuplicateRate
+		case "receiving_rate":
+			values[i] = row.ReceivingRate
+		case "latency_p50_ms":
+			values[i] = row.LatencyP50
+		case "latency_p99_ms":
+			values[i] = row.LatencyP99
+		case "bytes_per_node":
+			values[i] = row.BytesPerNode
+		case "honest_receiving_rate":
+			values[i] = row.HonestReceivingRate
+		case "honest_duplicate_rate":
+			values[i] = row.HonestDuplicateRate
+		case "honest_latency_p50_ms":
+			values[i] = row.HonestLatencyP50
+		case "honest_latency_p99_ms":
+			values[i] = row.HonestLatencyP99
+		case "victim_receiving_rate":
+			values[i] = row.VictimReceivingRate
+		case "victim_latency_p50_ms":
+			values[i] = row.VictimLatencyP50
+		case "victim_latency_max_ms":
+			values[i] = row.VictimLatencyMax
+		case "initial_receiving_rate":
+			values[i] = row.InitialReceivingRate
+		case "initial_latency_p99_ms":
+			values[i] = row.InitialLatencyP99
+		case "initial_honest_receiving_rate":
+			values[i] = row.InitialHonestReceivingRate
+		case "initial_honest_latency_p99_ms":
+			values[i] = row.InitialHonestLatencyP99
+		case "pruned_links":
+			values[i] = float64(row.PrunedLinks)
+		case "pruned_honest_links":
+			values[i] = float64(row.PrunedHonestLinks)
+		}
+	}
+
+	return values
+}
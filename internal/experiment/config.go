@@ -0,0 +1,364 @@
+package experiment
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/emulate"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/score"
+	"github.com/elecbug/p2p-broadcast-tester/internal/sim"
+	"github.com/elecbug/p2p-broadcast-tester/internal/wire"
+)
+
+// Engine types selecting how broadcasts are executed
+const (
+	EngineRealtime = "realtime" // One goroutine per transmission with real sleeps (default)
+	EnginePool     = "pool"     // Fixed worker pool with real sleeps
+	EngineSim      = "sim"      // Sequential virtual-time simulation on a CSR graph
+	EngineParallel = "parallel" // Parallel virtual-time simulation on a CSR graph
+	EngineSocket   = "socket"   // Every node on its own loopback socket, messages encoded and sent for real
+)
+
+// Topology generator types
+const (
+	GeneratorLimitDegree = "limit-degree" // network.GenerateLimitDegreeNetwork
+	GeneratorRandom      = "random"       // network.GenerateRandomNetwork
+	GeneratorFile        = "file"         // Load from a snapshot or graph file
+)
+
+// Experiment is a declarative description of a parameter sweep
+type Experiment struct {
+	Name        string        `json:"name"`
+	Output      string        `json:"output"`      // Path of the JSONL result file
+	Repetitions int           `json:"repetitions"` // Runs per parameter combination (default 1)
+	Seed        int64         `json:"seed"`        // Base seed; 0 picks a time-based seed per run
+	Engine      Engine        `json:"engine"`
+	Topology    Topology      `json:"topology"`
+	Protocols   []Protocol    `json:"protocols"`
+	Tuning      *Tuning       `json:"tune"`    // Parameter search run by the tune command
+	Scoring     *score.Params `json:"scoring"` // Peer score parameters of runs with scoring (missing ones use score.DefaultParams)
+}
+
+// Engine selects and configures the broadcast execution model
+type Engine struct {
+	Type        string `json:"type"`          // One of the Engine* constants
+	Workers     int    `json:"workers"`       // Pool workers or parallel partitions
+	MaxInFlight int    `json:"max_in_flight"` // In-flight transmission cap for the pool engine
+	Transport   string `json:"transport"`     // emulate.TCP or emulate.UDP for the socket engine (default udp)
+}
+
+// Topology describes how networks are created
+// Grid keys: node_count, mean_degree, d, d_low, d_high, edge_count, min_node_delay,
+// max_node_delay, min_link_delay, max_link_delay, origin, and the adversary keys
+// droppers, censors, delayers, spammers, forgers (fractions of nodes), hold_delay, spam_copies, censor_target,
+// the eclipse keys sybils, victims, victim, occupancy, anchors, sybil_degree,
+// the scoring keys messages (broadcasts per run) and scoring (0 or 1),
+// and payload_bytes (payload size of every message)
+type Topology struct {
+	Generator string            `json:"generator"` // One of the Generator* constants
+	Path      string            `json:"path"`      // Input file for the file generator
+	Format    string            `json:"format"`    // snapshot, edgelist, graphml, gexf or dot (default snapshot)
+	Grid      map[string]Values `json:"grid"`
+}
+
+// Protocol describes a broadcast type and its parameter grid
+// Grid keys: level (WavePublish), q and timeout (Dandelion)
+type Protocol struct {
+	Type string            `json:"type"` // p2p.BasicPublish, p2p.WavePublish or p2p.Dandelion
+	Grid map[string]Values `json:"grid"`
+}
+
+// Values is a list of parameter values, written in JSON either as an array
+// of numbers or as a range object {"from": 1, "to": 100, "step": 3}
+type Values []float64
+
+// UnmarshalJSON accepts an array, a single number or a range object
+func (v *Values) UnmarshalJSON(data []byte) error {
+	var list []float64
+	if err := json.Unmarshal(data, &list); err == nil {
+		*v = list
+		return nil
+	}
+
+	var single float64
+	if err := json.Unmarshal(data, &single); err == nil {
+		*v = Values{single}
+		return nil
+	}
+
+	var r struct {
+		From float64 `json:"from"`
+		To   float64 `json:"to"`
+		Step float64 `json:"step"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("grid values must be a number, an array or a range object: %s", data)
+	}
+
+	if r.Step == 0 {
+		r.Step = 1
+	}
+	if (r.Step > 0 && r.From > r.To) || (r.Step < 0 && r.From < r.To) {
+		return fmt.Errorf("range from %v to %v never reaches its end with step %v", r.From, r.To, r.Step)
+	}
+
+	list = nil
+	for x := r.From; (r.Step > 0 && x <= r.To) || (r.Step < 0 && x >= r.To); x += r.Step {
+		list = append(list, x)
+	}
+	*v = list
+
+	return nil
+}
+
+// topologyKeys lists the grid keys accepted by topologies
+var topologyKeys = map[string]bool{
+	"node_count":     true,
+	"mean_degree":    true,
+	"d":              true,
+	"d_low":          true,
+	"d_high":         true,
+	"edge_count":     true,
+	"min_node_delay": true,
+	"max_node_delay": true,
+	"min_link_delay": true,
+	"max_link_delay": true,
+	"origin":         true,
+	"droppers":       true,
+	"censors":        true,
+	"delayers":       true,
+	"spammers":       true,
+	"forgers":        true,
+	"hold_delay":     true,
+	"spam_copies":    true,
+	"censor_target":  true,
+	"sybils":         true,
+	"victims":        true,
+	"victim":         true,
+	"occupancy":      true,
+	"anchors":        true,
+	"sybil_degree":   true,
+	"messages":       true,
+	"scoring":        true,
+	"payload_bytes":  true,
+}
+
+// MaxPayload bounds the payload_bytes parameter, leaving room for the envelope header in a frame
+const MaxPayload = wire.MaxFrame - 64
+
+// protocolKeys lists the grid keys accepted by each protocol
+var protocolKeys = map[string]map[string]bool{
+	p2p.BasicPublish: {},
+	p2p.WavePublish:  {"level": true},
+	p2p.Dandelion:    {"q": true, "timeout": true},
+}
+
+// Load reads and validates an experiment file
+func Load(path string) (*Experiment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var exp Experiment
+	if err := json.Unmarshal(data, &exp); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := exp.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &exp, nil
+}
+
+// Validate fills in defaults and checks the experiment for unknown keys and values
+func (e *Experiment) Validate() error {
+	if e.Repetitions <= 0 {
+		e.Repetitions = 1
+	}
+	if e.Output == "" {
+		e.Output = "results/network_metric.jsonl"
+	}
+	if e.Engine.Type == "" {
+		e.Engine.Type = EngineRealtime
+	}
+	if e.Topology.Generator == "" {
+		e.Topology.Generator = GeneratorLimitDegree
+	}
+
+	switch e.Engine.Type {
+	case EngineRealtime, EnginePool, EngineSim, EngineParallel:
+	case EngineSocket:
+		switch e.Engine.Transport {
+		case "", emulate.TCP, emulate.UDP:
+		default:
+			return fmt.Errorf("unknown transport %q", e.Engine.Transport)
+		}
+	default:
+		return fmt.Errorf("unknown engine %q", e.Engine.Type)
+	}
+
+	switch e.Topology.Generator {
+	case GeneratorLimitDegree, GeneratorRandom:
+	case GeneratorFile:
+		if e.Topology.Path == "" {
+			return fmt.Errorf("file topology requires a path")
+		}
+	default:
+		return fmt.Errorf("unknown topology generator %q", e.Topology.Generator)
+	}
+
+	if err := checkGrid("topology", e.Topology.Grid, topologyKeys); err != nil {
+		return err
+	}
+	for _, v := range e.Topology.Grid["payload_bytes"] {
+		if v < 0 || v > MaxPayload {
+			return fmt.Errorf("topology: payload_bytes %g out of range [0, %d]", v, MaxPayload)
+		}
+	}
+	for _, point := range product(e.Topology.Grid) {
+		if err := CheckTopology(e.Topology.Generator, e.resolveTopology(point)); err != nil {
+			return fmt.Errorf("topology: %w", err)
+		}
+	}
+	if e.Engine.Type == EngineParallel && e.Topology.Generator != GeneratorFile {
+		delays := e.Topology.Grid["min_link_delay"]
+		if len(delays) == 0 || slices.Min(delays) <= 0 {
+			return fmt.Errorf("topology: %w", sim.ErrNoLookahead)
+		}
+	}
+
+	if e.Scoring != nil {
+		if err := e.Scoring.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if e.Tuning != nil {
+		if err := e.Tuning.validate(); err != nil {
+			return err
+		}
+
+		if len(e.Protocols) == 0 {
+			e.Protocols = []Protocol{{Type: e.Tuning.Protocol}} // Sweeping the file runs the default value
+		}
+	}
+
+	if len(e.Protocols) == 0 {
+		return fmt.Errorf("experiment has no protocols")
+	}
+
+	for _, p := range e.Protocols {
+		keys, ok := protocolKeys[p.Type]
+		if !ok {
+			return fmt.Errorf("unknown protocol %q", p.Type)
+		}
+
+		if err := checkGrid(p.Type, p.Grid, keys); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkGrid rejects unknown and empty grid entries
+func checkGrid(owner string, grid map[string]Values, allowed map[string]bool) error {
+	keys := make([]string, 0, len(grid))
+	for k := range grid {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !allowed[k] {
+			return fmt.Errorf("%s: unknown parameter %q", owner, k)
+		}
+		if len(grid[k]) == 0 {
+			return fmt.Errorf("%s: parameter %q has no values", owner, k)
+		}
+	}
+
+	return nil
+}
+
+// adversaryRoles are the grid keys of the adversary fractions
+var adversaryRoles = []string{"droppers", "censors", "delayers", "spammers", "forgers"}
+
+// CheckTopology rejects resolved topology parameters the generators and attacks cannot build,
+// so they fail before anything is generated rather than hang or panic
+func CheckTopology(generator string, params map[string]float64) error {
+	for _, k := range []string{"min_node_delay", "max_node_delay", "min_link_delay", "max_link_delay", "origin", "edge_count", "sybils", "victims", "anchors", "sybil_degree", "hold_delay", "spam_copies", "payload_bytes"} {
+		if v, ok := params[k]; ok && v < 0 {
+			return fmt.Errorf("%s %g is negative", k, v)
+		}
+	}
+	if params["min_node_delay"] > params["max_node_delay"] {
+		return fmt.Errorf("min_node_delay %g exceeds max_node_delay %g", params["min_node_delay"], params["max_node_delay"])
+	}
+	if params["min_link_delay"] > params["max_link_delay"] {
+		return fmt.Errorf("min_link_delay %g exceeds max_link_delay %g", params["min_link_delay"], params["max_link_delay"])
+	}
+
+	total := 0.0
+	for _, k := range adversaryRoles {
+		v := params[k]
+		if v < 0 || v > 1 {
+			return fmt.Errorf("%s %g is not a fraction between 0 and 1", k, v)
+		}
+		total += v
+	}
+	if total > 1 {
+		return fmt.Errorf("adversary fractions sum to %g, more than every node", total)
+	}
+	if v, ok := params["occupancy"]; ok && (v < 0 || v > 1) {
+		return fmt.Errorf("occupancy %g is not a fraction between 0 and 1", v)
+	}
+	if v, ok := params["messages"]; ok && v < 1 {
+		return fmt.Errorf("messages %g is less than 1", v)
+	}
+
+	// The node count of a file topology is only known once it is loaded
+	nodes, ok := params["node_count"]
+	if !ok {
+		return nil
+	}
+
+	if nodes < 2 {
+		return fmt.Errorf("node_count %g is less than 2", nodes)
+	}
+	if params["mean_degree"] < 1 {
+		return fmt.Errorf("mean_degree %g is less than 1", params["mean_degree"])
+	}
+
+	switch generator {
+	case GeneratorLimitDegree:
+		d, low, high := params["d"], params["d_low"], params["d_high"]
+		if d < 1 || d > nodes-1 {
+			return fmt.Errorf("d %g is not between 1 and node_count-1", d)
+		}
+		if low > d || d > high {
+			return fmt.Errorf("degree bounds must satisfy d_low %g <= d %g <= d_high %g", low, d, high)
+		}
+	case GeneratorRandom:
+		if edges := params["edge_count"]; edges > nodes*(nodes-1)/2 {
+			return fmt.Errorf("edge_count %g exceeds the %g node pairs", edges, nodes*(nodes-1)/2)
+		}
+	}
+
+	for _, k := range []string{"origin", "victim", "censor_target"} {
+		if v, ok := params[k]; ok && v >= nodes {
+			return fmt.Errorf("%s %g is not a node of the %g", k, v, nodes)
+		}
+	}
+	if params["victims"] > nodes-1 {
+		return fmt.Errorf("victims %g exceeds the nodes other than the origin", params["victims"])
+	}
+
+	return nil
+}
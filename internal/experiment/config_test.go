@@ -0,0 +1,54 @@
+package experiment
+
+import (
+	"testing"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+func TestValidateTopologyRanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator string
+		grid      map[string]Values
+		ok        bool
+	}{
+		{"defaults", GeneratorLimitDegree, nil, true},
+		{"random defaults", GeneratorRandom, nil, true},
+		{"single node", GeneratorLimitDegree, map[string]Values{"node_count": {1}}, false},
+		{"negative nodes", GeneratorLimitDegree, map[string]Values{"node_count": {-5}}, false},
+		{"degree 0", GeneratorLimitDegree, map[string]Values{"mean_degree": {0}}, false},
+		{"degree beyond nodes", GeneratorLimitDegree, map[string]Values{"node_count": {10}, "mean_degree": {40}}, false},
+		{"inverted degree bounds", GeneratorLimitDegree, map[string]Values{"d_low": {12}, "d_high": {8}, "d": {10}}, false},
+		{"too many edges", GeneratorRandom, map[string]Values{"node_count": {5}, "edge_count": {11}}, false},
+		{"all edges", GeneratorRandom, map[string]Values{"node_count": {5}, "edge_count": {10}}, true},
+		{"node delays inverted", GeneratorLimitDegree, map[string]Values{"min_node_delay": {10}, "max_node_delay": {5}}, false},
+		{"link delays inverted", GeneratorLimitDegree, map[string]Values{"min_link_delay": {10}, "max_link_delay": {5}}, false},
+		{"one bad grid value", GeneratorLimitDegree, map[string]Values{"node_count": {100, 1}}, false},
+		{"fraction above 1", GeneratorLimitDegree, map[string]Values{"droppers": {1.5}}, false},
+		{"negative fraction", GeneratorLimitDegree, map[string]Values{"spammers": {-0.1}}, false},
+		{"fractions above 1 together", GeneratorLimitDegree, map[string]Values{"droppers": {0.7}, "censors": {0.7}}, false},
+		{"fractions of every node", GeneratorLimitDegree, map[string]Values{"droppers": {0.5}, "censors": {0.5}}, true},
+		{"victim beyond nodes", GeneratorLimitDegree, map[string]Values{"node_count": {20}, "mean_degree": {4}, "victims": {1}, "victim": {500}}, false},
+		{"censor target beyond nodes", GeneratorLimitDegree, map[string]Values{"censors": {0.1}, "censor_target": {1000}}, false},
+		{"origin beyond nodes", GeneratorLimitDegree, map[string]Values{"origin": {1000}}, false},
+		{"file topology", GeneratorFile, map[string]Values{"victim": {500}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Experiment{
+				Topology:  Topology{Generator: tt.generator, Path: "graph.snapshot", Grid: tt.grid},
+				Protocols: []Protocol{{Type: p2p.BasicPublish}},
+			}
+
+			err := e.Validate()
+			if tt.ok && err != nil {
+				t.Errorf("rejected: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package experiment
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/csr"
+	"github.com/elecbug/p2p-broadcast-tester/internal/network"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// topologyDefaults are used for topology parameters missing from the grid
+var topologyDefaults = map[string]float64{
+	"node_count":     1000,
+	"mean_degree":    40,
+	"min_node_delay": 0,
+	"max_node_delay": 100,
+	"min_link_delay": 0,
+	"max_link_delay": 1,
+	"origin":         0,
+}
+
+// adversaryDefaults are used for adversary parameters missing from a grid that has adversaries
+// A negative censor target stands for the run's origin, a negative victim for a random node
+var adversaryDefaults = map[string]float64{
+	"droppers":      0,
+	"censors":       0,
+	"delayers":      0,
+	"spammers":      0,
+	"forgers":       0,
+	"hold_delay":    1000,
+	"spam_copies":   2,
+	"censor_target": -1,
+	"sybils":        0,
+	"victims":       0,
+	"victim":        -1,
+	"occupancy":     1,
+	"anchors":       0,
+	"sybil_degree":  8,
+}
+
+// scoringDefaults are used for scoring parameters missing from a grid that has some, or from the
+// runs of an experiment that configures score parameters, in which case scoring is on by default
+var scoringDefaults = map[string]float64{
+	"messages": 1,
+	"scoring":  0,
+}
+
+// Run is a single point of the expanded parameter space
+type Run struct {
+	ID         string             // Stable identifier derived from protocol, parameters and repetition
+	Protocol   p2p.BroadcastType  // Broadcast algorithm under test
+	Params     map[string]float64 // Full resolved parameter set, including defaults
+	Repetition int                // Repetition index, starting at 0
+	Seed       int64              // Topology seed (0 picks a time-based seed)
+}
+
+// Expand returns the Cartesian product of the topology grid, each protocol's grid and the repetitions
+// Runs sharing topology parameters and repetition index share the same seed, so with a fixed
+// experiment seed every protocol is evaluated on an identical graph
+func (e *Experiment) Expand() []Run {
+	var runs []Run
+
+	topologies := product(e.Topology.Grid)
+
+	for _, p := range e.Protocols {
+		for _, protoParams := range product(p.Grid) {
+			for _, topoParams := range topologies {
+				params := e.resolveTopology(topoParams)
+
+				bt := p2p.BroadcastType{Type: p.Type}
+				switch p.Type {
+				case p2p.WavePublish:
+					params["level"] = valueOr(protoParams, "level", 50)
+					bt.Level = int(params["level"])
+				case p2p.Dandelion:
+					params["q"] = valueOr(protoParams, "q", 10)
+					params["timeout"] = valueOr(protoParams, "timeout", float64(p2p.DefaultDandelionTimeout))
+					bt.Level = int(params["q"])
+					bt.Timeout = p2p.Delay(params["timeout"])
+				}
+
+				for rep := 0; rep < e.Repetitions; rep++ {
+					runs = append(runs, Run{
+						ID:         runID(bt, params, rep),
+						Protocol:   bt,
+						Params:     params,
+						Repetition: rep,
+						Seed:       e.topologySeed(topoParams, rep),
+					})
+				}
+			}
+		}
+	}
+
+	return runs
+}
+
+// resolveTopology fills in defaults and derived degree bounds for one grid point
+func (e *Experiment) resolveTopology(grid map[string]float64) map[string]float64 {
+	params := make(map[string]float64)
+
+	for k, v := range topologyDefaults {
+		params[k] = valueOr(grid, k, v)
+	}
+
+	switch e.Topology.Generator {
+	case GeneratorLimitDegree:
+		// Same bounds as the original hard-coded experiment: D-2 <= degree <= D+2
+		params["d"] = valueOr(grid, "d", params["mean_degree"])
+		params["d_low"] = valueOr(grid, "d_low", params["d"]-2)
+		params["d_high"] = valueOr(grid, "d_high", params["d"]+2)
+	case GeneratorRandom:
+		params["edge_count"] = valueOr(grid, "edge_count", params["node_count"]*params["mean_degree"]/2)
+	case GeneratorFile:
+		delete(params, "node_count")
+		delete(params, "mean_degree")
+	}
+
+	// Adversary parameters only appear in runs that have some, keeping other run IDs unchanged
+	for k := range adversaryDefaults {
+		if _, ok := grid[k]; ok {
+			for k, v := range adversaryDefaults {
+				params[k] = valueOr(grid, k, v)
+			}
+			break
+		}
+	}
+
+	// Scoring parameters likewise only appear in runs that have some
+	scored := e.Scoring != nil
+	for k := range scoringDefaults {
+		_, ok := grid[k]
+		scored = scored || ok
+	}
+	if scored {
+		for k, v := range scoringDefaults {
+			params[k] = valueOr(grid, k, v)
+		}
+		if _, ok := grid["scoring"]; !ok && e.Scoring != nil {
+			params["scoring"] = 1
+		}
+	}
+
+	// So does the payload size, which is 0 otherwise
+	if v, ok := grid["payload_bytes"]; ok {
+		params["payload_bytes"] = v
+	}
+
+	return params
+}
+
+// Adversary assigns the malicious roles of a run to nodeCount nodes, or returns nil if the
+// experiment has no adversary parameters. Runs of such experiments with all fractions at 0 get
+// an all-honest adversary, so they report the honest-node baseline the others are compared to.
+// Roles are drawn from the topology seed, so a run with a larger fraction of some role
+// keeps the adversaries of a smaller one on the same graph
+func (r Run) Adversary(nodeCount int, seed int64) *p2p.Adversary {
+	if _, ok := r.Params["droppers"]; !ok {
+		return nil
+	}
+
+	config := p2p.AdversaryConfig{
+		Droppers: r.Params["droppers"],
+		Censors:  r.Params["censors"],
+		Delayers: r.Params["delayers"],
+		Spammers: r.Params["spammers"],
+		Forgers:  r.Params["forgers"],
+		Hold:     p2p.Delay(r.Params["hold_delay"]),
+		Copies:   int(r.Params["spam_copies"]),
+	}
+	origin := p2p.NodeID(r.Params["origin"])
+	config.Censored = []p2p.NodeID{origin}
+	if target := r.Params["censor_target"]; target >= 0 {
+		config.Censored = []p2p.NodeID{p2p.NodeID(target)}
+	}
+
+	return p2p.NewAdversary(nodeCount, config, []p2p.NodeID{origin}, seed^0x5deece66d)
+}
+
+// Eclipse returns the Sybil attack of a run, or nil if it has no Sybils and no victims
+func (r Run) Eclipse(seed int64) *csr.EclipseConfig {
+	if r.Params["sybils"] <= 0 && r.Params["victims"] <= 0 {
+		return nil
+	}
+
+	return &csr.EclipseConfig{
+		Sybils:      int(r.Params["sybils"]),
+		Victims:     int(r.Params["victims"]),
+		Center:      int32(r.Params["victim"]),
+		Occupancy:   r.Params["occupancy"],
+		Anchors:     int(r.Params["anchors"]),
+		SybilDegree: int(r.Params["sybil_degree"]),
+		Protected:   []int32{int32(r.Params["origin"])},
+		Seed:        seed ^ 0x2545f4914f6cdd1d,
+	}
+}
+
+// NetworkConfig builds the generator configuration of a run
+func (r Run) NetworkConfig() network.NetworkConfig {
+	return network.NetworkConfig{
+		NodeCount:    int(r.Params["node_count"]),
+		MinNodeDelay: p2p.Delay(r.Params["min_node_delay"]),
+		MaxNodeDelay: p2p.Delay(r.Params["max_node_delay"]),
+		MinLinkDelay: p2p.Delay(r.Params["min_link_delay"]),
+		MaxLinkDelay: p2p.Delay(r.Params["max_link_delay"]),
+		EdgeCount:    int(r.Params["edge_count"]),
+		D:            int(r.Params["d"]),
+		DLow:         int(r.Params["d_low"]),
+		DHigh:        int(r.Params["d_high"]),
+		Seed:         r.Seed,
+	}
+}
+
+// topologySeed derives a per-topology seed from the experiment seed
+func (e *Experiment) topologySeed(grid map[string]float64, rep int) int64 {
+	if e.Seed == 0 {
+		return 0
+	}
+
+	// Adversary, scoring and payload parameters do not change the graph
+	topology := make(map[string]float64, len(grid))
+	for k, v := range grid {
+		_, adversary := adversaryDefaults[k]
+		_, scoring := scoringDefaults[k]
+		if !adversary && !scoring && k != "payload_bytes" {
+			topology[k] = v
+		}
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%s|%d", e.Seed, formatParams(topology), rep)
+
+	seed := int64(h.Sum64() >> 1)
+	if seed == 0 {
+		seed = 1 // 0 would request a time-based seed
+	}
+
+	return seed
+}
+
+// runID builds a stable, human-readable identifier for a run
+func runID(bt p2p.BroadcastType, params map[string]float64, rep int) string {
+	return fmt.Sprintf("%s/%s/rep=%d", bt.String(), formatParams(params), rep)
+}
+
+// formatParams renders parameters as sorted key=value pairs
+func formatParams(params map[string]float64) string {
+	keys := sortedKeys(params)
+	parts := make([]string, len(keys))
+
+	for i, k := range keys {
+		parts[i] = k + "=" + strconv.FormatFloat(params[k], 'g', -1, 64)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// product expands a grid into every combination of its values
+// An empty grid yields a single empty combination
+func product(grid map[string]Values) []map[string]float64 {
+	combos := []map[string]float64{{}}
+
+	for _, k := range sortedKeys(grid) {
+		var next []map[string]float64
+
+		for _, combo := range combos {
+			for _, v := range grid[k] {
+				c := make(map[string]float64, len(combo)+1)
+				for ck, cv := range combo {
+					c[ck] = cv
+				}
+				c[k] = v
+
+				next = append(next, c)
+			}
+		}
+
+		combos = next
+	}
+
+	return combos
+}
+
+// sortedKeys returns the keys of a map in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// valueOr returns m[key] if present, otherwise the fallback
+func valueOr(m map[string]float64, key string, fallback float64) float64 {
+	if v, ok := m[key]; ok {
+		return v
+	}
+
+	return fallback
+}
@@ -0,0 +1,123 @@
+package experiment
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// manifestPath returns the path of the completed-run manifest for a result file
+func manifestPath(output string) string {
+	return output + ".manifest"
+}
+
+// repairResults truncates a trailing partial line left behind by an interrupted write
+func repairResults(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	// Scan backwards for the last newline
+	const chunk = 64 * 1024
+	buf := make([]byte, chunk)
+	end := info.Size()
+
+	for pos := end; pos > 0; {
+		start := max(pos-chunk, 0)
+		n, err := file.ReadAt(buf[:pos-start], start)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			if last := start + int64(i) + 1; last != end {
+				return file.Truncate(last)
+			}
+			return nil
+		}
+
+		pos = start
+	}
+
+	// No complete line at all
+	return file.Truncate(0)
+}
+
+// loadCompleted returns the IDs of runs recorded in the manifest or present in the result file
+// Runs whose rows were written but not yet recorded in the manifest are appended to it
+func loadCompleted(output string) (map[string]bool, error) {
+	done := make(map[string]bool)
+
+	if err := scanLines(manifestPath(output), func(line string) error {
+		done[line] = true
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+
+	if err := scanResults(output, func(row Result) error {
+		if row.RunID != "" && !done[row.RunID] {
+			done[row.RunID] = true
+			missing = append(missing, row.RunID)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(missing) > 0 {
+		file, err := os.OpenFile(manifestPath(output), os.O_CREATE|os.O_WRONLY|os.O_APPEND, fs.ModePerm)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		if _, err := file.WriteString(strings.Join(missing, "\n") + "\n"); err != nil {
+			return nil, err
+		}
+	}
+
+	return done, nil
+}
+
+// scanLines calls fn for every non-empty line of a file; a missing file has no lines
+func scanLines(path string, fn func(line string) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
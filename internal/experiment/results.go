@@ -0,0 +1,141 @@
+package experiment
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReadResults reads every row of a JSONL or CSV result file, upgrading rows of older schemas
+func ReadResults(path string) ([]Result, error) {
+	var rows []Result
+
+	err := scanResults(path, func(row Result) error {
+		rows = append(rows, row)
+		return nil
+	})
+
+	return rows, err
+}
+
+// scanResults calls fn for every row of a result file; a missing file has no rows
+func scanResults(path string, fn func(row Result) error) error {
+	if FormatOf(path) == FormatCSV {
+		return scanCSV(path, fn)
+	}
+
+	line := 0
+	return scanLines(path, func(text string) error {
+		line++
+
+		var row Result
+		if err := json.Unmarshal([]byte(text), &row); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := Upgrade(&row); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		return fn(row)
+	})
+}
+
+// scanCSV reads a CSV result file by its header, ignoring unknown columns
+func scanCSV(path string, fn func(row Result) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	columns := make([]*csvColumn, len(header))
+	for i, name := range header {
+		for c := range csvColumns {
+			if csvColumns[c].name == name {
+				columns[i] = &csvColumns[c]
+			}
+		}
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var row Result
+		for i, value := range record {
+			if columns[i] == nil || value == "" {
+				continue
+			}
+
+			if err := columns[i].set(&row, value); err != nil {
+				line, _ := r.FieldPos(i)
+				return fmt.Errorf("line %d: %s: %w", line, columns[i].name, err)
+			}
+		}
+
+		if err := Upgrade(&row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// Upgrade converts a row read from an older file to the current schema in place
+// Rows without a schema field are version 1: they carry the rates but not the counts
+// they were computed from, which are recovered by inverting p2p.NewNetworkMetric
+func Upgrade(row *Result) error {
+	if row.Schema > SchemaVersion {
+		return fmt.Errorf("row has schema %d, but this build only understands up to %d", row.Schema, SchemaVersion)
+	}
+	if row.Schema == SchemaVersion {
+		return nil
+	}
+
+	// Version 1 -> 2
+	reached := row.ReceivingRate * float64(row.NodeCount-1)
+	row.Reached = int(math.Round(reached))
+	row.Receptions = int(math.Round((row.DuplicateRate + 1) * reached))
+	row.Schema = SchemaVersion
+
+	return nil
+}
+
+// Field returns a column of the row by its CSV/JSON name, or a parameter by its key
+// Parameters may also be addressed as params.<key>
+func (r Result) Field(name string) (string, bool) {
+	for _, col := range csvColumns {
+		if col.name == name {
+			return col.get(&r), true
+		}
+	}
+
+	if v, ok := r.Params[strings.TrimPrefix(name, "params.")]; ok {
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,51 @@
+package experiment
+
+import (
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+var (
+	revisionOnce sync.Once
+	revision     string
+)
+
+// GitRevision returns the revision of the source tree the binary was built from
+// It prefers the VCS stamp of `go build`, falls back to asking git (as under `go run`)
+// and is empty when neither is available. A "-dirty" suffix marks uncommitted changes
+func GitRevision() string {
+	revisionOnce.Do(func() {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			var modified bool
+			for _, s := range info.Settings {
+				switch s.Key {
+				case "vcs.revision":
+					revision = s.Value
+				case "vcs.modified":
+					modified = s.Value == "true"
+				}
+			}
+
+			if revision != "" {
+				if modified {
+					revision += "-dirty"
+				}
+				return
+			}
+		}
+
+		out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+		if err != nil {
+			return
+		}
+		revision = strings.TrimSpace(string(out))
+
+		if status, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output(); err == nil && len(status) > 0 {
+			revision += "-dirty"
+		}
+	})
+
+	return revision
+}
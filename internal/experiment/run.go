@@ -0,0 +1,370 @@
+package experiment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/csr"
+	"github.com/elecbug/p2p-broadcast-tester/internal/emulate"
+	"github.com/elecbug/p2p-broadcast-tester/internal/network"
+	"github.com/elecbug/p2p-broadcast-tester/internal/node"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/score"
+	"github.com/elecbug/p2p-broadcast-tester/internal/sim"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
+)
+
+// messageID is the first message broadcast in every run, later ones count up from it
+const messageID p2p.MessageID = 1
+
+// Result is a single result row: the broadcast metric plus the parameters it came from
+// See docs/results.md for the meaning of every column and how older files are migrated
+type Result struct {
+	Schema      int                `json:"schema"` // SchemaVersion the row was written with
+	RunID       string             `json:"run_id"`
+	Experiment  string             `json:"experiment"`
+	GitRevision string             `json:"git_revision,omitempty"` // Revision of the binary that produced the row
+	StartedAt   string             `json:"started_at,omitempty"`   // RFC 3339 start time of the run
+	WallMs      float64            `json:"wall_ms"`                // Wall-clock duration of generation and broadcast
+	VirtualMs   float64            `json:"virtual_ms,omitempty"`   // Virtual time until the broadcast settled (sim engines)
+	Params      map[string]float64 `json:"params"`
+	Repetition  int                `json:"repetition"`
+	Seed        int64              `json:"seed"` // Seed the topology was actually generated with
+	p2p.NetworkMetric
+}
+
+// Execute performs a single run and returns its result row
+// If traceDir is not empty, the broadcast events of real-time engines are written there;
+// rec, if not nil, receives their events of every message as they happen
+func (e *Experiment) Execute(run Run, traceDir string, rec trace.Recorder) (Result, error) {
+	var metric p2p.NetworkMetric
+	var seed int64
+	var virtual uint64
+	var err error
+
+	start := time.Now()
+
+	switch e.Engine.Type {
+	case EngineSim, EngineParallel:
+		metric, seed, virtual, err = e.executeSim(run)
+	default:
+		metric, seed, err = e.executeRealtime(run, traceDir, rec)
+	}
+
+	if err != nil {
+		return Result{}, fmt.Errorf("%s: %w", run.ID, err)
+	}
+
+	return Result{
+		Schema:        SchemaVersion,
+		RunID:         run.ID,
+		Experiment:    e.Name,
+		GitRevision:   GitRevision(),
+		StartedAt:     start.UTC().Format(time.RFC3339),
+		WallMs:        float64(time.Since(start)) / float64(time.Millisecond),
+		VirtualMs:     float64(virtual),
+		Params:        run.Params,
+		Repetition:    run.Repetition,
+		Seed:          seed,
+		NetworkMetric: metric,
+	}, nil
+}
+
+// executeRealtime broadcasts on a pointer-based network with real sleeps, or over loopback sockets
+func (e *Experiment) executeRealtime(run Run, traceDir string, rec trace.Recorder) (p2p.NetworkMetric, int64, error) {
+	n, err := e.BuildNetwork(run)
+	if err != nil {
+		return p2p.NetworkMetric{}, 0, err
+	}
+
+	origin := int(run.Params["origin"])
+	if origin < 0 || origin >= len(n.Nodes) {
+		return p2p.NetworkMetric{}, 0, fmt.Errorf("origin %d out of range", origin)
+	}
+
+	delay := int(run.Params["max_node_delay"])
+
+	n, adversary, err := run.AttackNetwork(n)
+	if err != nil {
+		return p2p.NetworkMetric{}, 0, err
+	}
+	if adversary != nil {
+		n.SetAdversary(adversary)
+	}
+
+	// Stream broadcast events of the last message to a trace file if requested
+	var tw *trace.Writer
+	if traceDir != "" {
+		path := filepath.Join(traceDir, fmt.Sprintf("trace_%s_%d_%d.jsonl", run.Protocol.String(), len(n.Nodes), run.Repetition))
+
+		tw, err = trace.Create(path, trace.Header{
+			NodeCount: len(n.Nodes),
+			AvgDegree: n.AvgDegree(),
+			Broadcast: run.Protocol.String(),
+			Delay:     delay,
+			Seed:      n.Config.Seed,
+		})
+		if err != nil {
+			return p2p.NetworkMetric{}, 0, err
+		}
+		defer tw.Close()
+	}
+
+	var scores *score.Table
+	if params := e.scoreParams(run); params != nil {
+		g, err := csr.FromNetwork(n)
+		if err != nil {
+			return p2p.NetworkMetric{}, 0, err
+		}
+
+		scores = score.NewTable(g.Offsets, g.Neighbors, *params)
+		n.SetScores(scores)
+	}
+
+	var emu *emulate.Emulator
+	if e.Engine.Type == EngineSocket {
+		emu, err = emulate.Start(n, emulate.Config{Transport: e.Engine.Transport})
+		if err != nil {
+			return p2p.NetworkMetric{}, 0, err
+		}
+		defer emu.Close()
+	}
+
+	// Later messages are broadcast with the peer scores learned from the earlier ones
+	origins := run.origins(len(n.Nodes), adversary, n.Config.Seed)
+	last := messageID + p2p.MessageID(len(origins)-1)
+	lost := int64(0)
+	if rec != nil {
+		n.SetRecorder(rec)
+	}
+
+	for m, o := range origins {
+		mid := messageID + p2p.MessageID(m)
+		if m > 0 && scores != nil {
+			n.ObserveScores(mid-1, run.Protocol, scores)
+		}
+		if mid == last && tw != nil {
+			n.SetRecorder(trace.Tee(rec, tw))
+		}
+
+		lost += e.broadcast(emu, &n.Nodes[o], run.message(mid))
+	}
+
+	metric := n.Metric(last, run.Protocol, delay)
+	metric.Lost = int(lost)
+	if len(origins) > 1 {
+		metric.SetRecovery(n.Metric(messageID, run.Protocol, delay), len(origins))
+		setPruned(&metric, scores, adversary)
+	}
+
+	return metric, n.Config.Seed, nil
+}
+
+// broadcast publishes a message from a node of a pointer-based network and waits until it settles
+// It returns the number of transmissions the socket engine lost
+func (e *Experiment) broadcast(emu *emulate.Emulator, origin *node.Node, msg node.Message) int64 {
+	switch e.Engine.Type {
+	case EngineSocket:
+		return emu.Broadcast(origin, msg)
+	case EnginePool:
+		pool := node.NewPool(e.Engine.Workers, e.Engine.MaxInFlight)
+		origin.PublishWith(pool, msg)
+
+		pool.Close() // Wait for broadcast to complete and stop workers
+	default:
+		wg := &sync.WaitGroup{}
+		origin.PublishWith(&node.GoroutineExecutor{WG: wg}, msg)
+
+		wg.Wait() // Wait for broadcast to complete
+	}
+
+	return 0
+}
+
+// message builds a message of a run, with a zeroed payload of payload_bytes
+func (r Run) message(mid p2p.MessageID) node.Message {
+	return node.Message{
+		ID:      mid,
+		Type:    r.Protocol,
+		Payload: make([]byte, int(r.Params["payload_bytes"])),
+	}
+}
+
+// executeSim broadcasts on a CSR graph in virtual time
+// It also returns the virtual duration of the broadcast in milliseconds
+func (e *Experiment) executeSim(run Run) (p2p.NetworkMetric, int64, uint64, error) {
+	g, err := e.BuildGraph(run)
+	if err != nil {
+		return p2p.NetworkMetric{}, 0, 0, err
+	}
+
+	origin := int32(run.Params["origin"])
+	if origin < 0 || int(origin) >= g.NodeCount() {
+		return p2p.NetworkMetric{}, 0, 0, fmt.Errorf("origin %d out of range", origin)
+	}
+
+	g, adversary, err := run.attack(g)
+	if err != nil {
+		return p2p.NetworkMetric{}, 0, 0, err
+	}
+	delay := int(run.Params["max_node_delay"])
+
+	opts := sim.Options{Adversary: adversary, Payload: int(run.Params["payload_bytes"])}
+	if params := e.scoreParams(run); params != nil {
+		opts.Scores = score.NewTable(g.Offsets, g.Neighbors, *params)
+	}
+
+	// Later messages are broadcast with the peer scores learned from the earlier ones
+	// Every message uses the topology seed, so nodes keep their random choices as in one epoch
+	origins := run.origins(g.NodeCount(), adversary, g.Config.Seed)
+
+	var r *sim.Result
+	var first p2p.NetworkMetric
+	for m, o := range origins {
+		if m > 0 && opts.Scores != nil {
+			r.Observe(g, run.Protocol, opts.Scores)
+		}
+
+		r, err = e.simulate(g, o, run.Protocol, opts)
+		if err != nil {
+			return p2p.NetworkMetric{}, 0, 0, err
+		}
+		if m == 0 && len(origins) > 1 {
+			first = r.Metric(g, run.Protocol, delay)
+		}
+	}
+
+	metric := r.Metric(g, run.Protocol, delay)
+	if len(origins) > 1 {
+		metric.SetRecovery(first, len(origins))
+		setPruned(&metric, opts.Scores, adversary)
+	}
+
+	return metric, g.Config.Seed, r.Duration, nil
+}
+
+// simulate runs a single broadcast with the experiment's virtual-time engine
+func (e *Experiment) simulate(g *csr.Graph, origin int32, bt p2p.BroadcastType, opts sim.Options) (*sim.Result, error) {
+	if e.Engine.Type == EngineParallel {
+		return sim.RunParallel(g, origin, bt, g.Config.Seed, sim.ParallelConfig{
+			Workers:   e.Engine.Workers,
+			Lookahead: uint64(g.Config.MinLinkDelay),
+			Options:   opts,
+		})
+	}
+
+	return sim.RunWith(g, origin, bt, g.Config.Seed, opts), nil
+}
+
+// attack adds the Sybils of a run's eclipse scenario to its graph and assigns the adversary roles
+// The adversary is nil for runs without adversary parameters
+func (r Run) attack(g *csr.Graph) (*csr.Graph, *p2p.Adversary, error) {
+	adversary := r.Adversary(g.NodeCount(), g.Config.Seed)
+
+	eclipse := r.Eclipse(g.Config.Seed)
+	if eclipse == nil {
+		return g, adversary, nil
+	}
+
+	honest := g.NodeCount()
+	g, victims, err := g.Eclipse(*eclipse)
+	if err != nil {
+		return nil, nil, fmt.Errorf("eclipse: %w", err)
+	}
+
+	adversary.AddSybils(g.NodeCount() - honest)
+	for _, v := range victims {
+		adversary.Victims = append(adversary.Victims, int(v))
+	}
+
+	return g, adversary, nil
+}
+
+// AttackNetwork applies the adversary and eclipse parameters of a run to a pointer-based network,
+// rebuilding it if Sybils are added. The adversary is nil for runs without adversary parameters
+func (r Run) AttackNetwork(n *network.Network) (*network.Network, *p2p.Adversary, error) {
+	if r.Eclipse(n.Config.Seed) == nil {
+		return n, r.Adversary(len(n.Nodes), n.Config.Seed), nil
+	}
+
+	g, err := csr.FromNetwork(n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	g, adversary, err := r.attack(g)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return g.ToNetwork(), adversary, nil
+}
+
+// BuildNetwork creates the pointer-based network of a run
+func (e *Experiment) BuildNetwork(run Run) (*network.Network, error) {
+	config := run.NetworkConfig()
+
+	switch e.Topology.Generator {
+	case GeneratorRandom:
+		return network.GenerateRandomNetwork(config), nil
+	case GeneratorFile:
+		return e.loadTopology(config)
+	default:
+		return network.GenerateLimitDegreeNetwork(config), nil
+	}
+}
+
+// BuildGraph creates the CSR graph of a run, generating it directly when possible
+func (e *Experiment) BuildGraph(run Run) (*csr.Graph, error) {
+	if e.Topology.Generator == GeneratorLimitDegree {
+		return csr.GenerateLimitDegree(run.NetworkConfig()), nil
+	}
+
+	n, err := e.BuildNetwork(run)
+	if err != nil {
+		return nil, err
+	}
+
+	return csr.FromNetwork(n)
+}
+
+// loadTopology reads the topology file of a file-based experiment
+func (e *Experiment) loadTopology(config network.NetworkConfig) (*network.Network, error) {
+	if e.Topology.Format == "" || e.Topology.Format == "snapshot" {
+		return network.LoadSnapshot(e.Topology.Path)
+	}
+
+	file, err := os.Open(e.Topology.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lc := network.LoadConfig{
+		MinNodeDelay: config.MinNodeDelay,
+		MaxNodeDelay: config.MaxNodeDelay,
+		MinLinkDelay: config.MinLinkDelay,
+		MaxLinkDelay: config.MaxLinkDelay,
+		Seed:         config.Seed,
+	}
+
+	var n *network.Network
+
+	switch e.Topology.Format {
+	case "edgelist":
+		n, _, err = network.LoadEdgeList(file, lc)
+	case "graphml":
+		n, _, err = network.LoadGraphML(file, lc)
+	case "gexf":
+		n, _, err = network.LoadGEXF(file, lc)
+	case "dot":
+		n, _, err = network.LoadDOT(file, lc)
+	default:
+		return nil, fmt.Errorf("unknown topology format %q", e.Topology.Format)
+	}
+
+	return n, err
+}
@@ -0,0 +1,265 @@
+package experiment
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/monitor"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
+)
+
+// RunOptions controls how the runs of an experiment are executed
+type RunOptions struct {
+	TraceDir    string           // Directory for broadcast event logs (empty disables tracing)
+	Log         io.Writer        // Destination of progress messages
+	Concurrency int              // Maximum number of concurrent runs (default 1)
+	MemoryLimit int64            // Estimated memory budget in bytes shared by concurrent runs (0 is unlimited)
+	Resume      bool             // Skip runs already recorded in the manifest or result file
+	Mirror      ResultWriter     // Optional second destination of every row, such as a results store
+	Monitor     *monitor.Monitor // Optional collector of progress and results for a metrics endpoint
+}
+
+// RunAll executes the runs of the experiment and appends one row per run to the output file,
+// written as CSV if its name ends in .csv and as JSONL otherwise
+// Every row is written with a single write and synced before its run ID is added to the manifest
+// (<output>.manifest), so an interrupted sweep can be resumed without losing or corrupting rows.
+// A failing run is reported and skipped; it is retried on the next resume. Note that concurrent
+// runs of the real-time engines compete for CPU and may distort each other's timing
+func (e *Experiment) RunAll(opts RunOptions) error {
+	if err := os.MkdirAll(filepath.Dir(e.Output), fs.ModePerm); err != nil {
+		return err
+	}
+
+	if err := repairResults(e.Output); err != nil {
+		return fmt.Errorf("repairing %s: %w", e.Output, err)
+	}
+
+	done := map[string]bool{}
+	if opts.Resume {
+		var err error
+		if done, err = loadCompleted(e.Output); err != nil {
+			return fmt.Errorf("reading manifest: %w", err)
+		}
+	}
+
+	results, err := os.OpenFile(e.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, fs.ModePerm)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", e.Output, err)
+	}
+	defer results.Close()
+
+	info, err := results.Stat()
+	if err != nil {
+		return err
+	}
+
+	writer, err := NewResultWriter(results, FormatOf(e.Output), info.Size() == 0)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := os.OpenFile(manifestPath(e.Output), os.O_CREATE|os.O_WRONLY|os.O_APPEND, fs.ModePerm)
+	if err != nil {
+		return fmt.Errorf("opening manifest: %w", err)
+	}
+	defer manifest.Close()
+
+	var pending []Run
+	for _, run := range e.Expand() {
+		if !done[run.ID] {
+			pending = append(pending, run)
+		}
+	}
+
+	log := &syncWriter{w: opts.Log}
+	if opts.Log == nil {
+		log.w = io.Discard
+	}
+	if skipped := len(done); skipped > 0 {
+		fmt.Fprintf(log, "Resuming: %d runs already completed, %d remaining\n", skipped, len(pending))
+	}
+
+	started := 0
+	if opts.Monitor != nil {
+		opts.Monitor.Queue(len(pending))
+		defer func() { opts.Monitor.Queue(started - len(pending)) }() // Runs skipped after a write error
+	}
+
+	concurrency := max(opts.Concurrency, 1)
+	slots := make(chan struct{}, concurrency)
+	memory := newMemoryBudget(opts.MemoryLimit)
+
+	var mu sync.Mutex // Serializes result and manifest writes
+	var writeErr error
+	var wg sync.WaitGroup
+
+	for i, run := range pending {
+		cost := e.estimateMemory(run)
+
+		// Acquire slots in run order so large runs are not starved
+		slots <- struct{}{}
+		memory.acquire(cost)
+
+		mu.Lock()
+		failed := writeErr != nil
+		mu.Unlock()
+		if failed {
+			memory.release(cost)
+			<-slots
+			break
+		}
+
+		started++
+		wg.Add(1)
+
+		go func(i int, run Run) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			defer memory.release(cost)
+
+			fmt.Fprintf(log, "Starting %s (%d/%d)\n", run.ID, i+1, len(pending))
+
+			var tracker *monitor.Run
+			var rec trace.Recorder
+			if opts.Monitor != nil {
+				tracker = opts.Monitor.Start()
+				rec = tracker
+			}
+
+			start := time.Now()
+			result, err := e.Execute(run, opts.TraceDir, rec)
+			if tracker != nil {
+				if err != nil {
+					tracker.Finish(nil, time.Since(start))
+				} else {
+					tracker.Finish(&result.NetworkMetric, time.Since(start))
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(log, "Error: %v\n", err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if writeErr == nil {
+				writeErr = appendRecord(writer, results, manifest, result)
+			}
+
+			// The result file stays authoritative; a mirror can be backfilled from it later
+			if writeErr == nil && opts.Mirror != nil {
+				if err := opts.Mirror.Write(result); err != nil {
+					fmt.Fprintf(log, "Error: mirroring %s: %v\n", run.ID, err)
+				}
+			}
+		}(i, run)
+	}
+
+	wg.Wait()
+
+	return writeErr
+}
+
+// appendRecord writes a result row, syncs it, then records its run ID in the manifest
+func appendRecord(writer ResultWriter, results, manifest *os.File, result Result) error {
+	if err := writer.Write(result); err != nil {
+		return fmt.Errorf("writing result: %w", err)
+	}
+	if err := results.Sync(); err != nil {
+		return fmt.Errorf("syncing result: %w", err)
+	}
+
+	if _, err := manifest.WriteString(result.RunID + "\n"); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+
+	return manifest.Sync()
+}
+
+// estimateMemory returns a rough upper bound of the memory a run needs in bytes
+func (e *Experiment) estimateMemory(run Run) int64 {
+	nodes := int64(run.Params["node_count"])
+	degree := int64(run.Params["d_high"])
+	if degree == 0 {
+		degree = int64(run.Params["mean_degree"])
+	}
+	arcs := nodes * degree
+
+	switch e.Engine.Type {
+	case EngineSim, EngineParallel:
+		// CSR arrays, per-node result arrays, seen bitset and peak event queue
+		return nodes*32 + arcs*(8+24) + arcs/8
+	case EngineSocket:
+		// As realtime, plus a socket buffer per node and per TCP stream
+		return nodes*(1024+64<<10) + arcs*(64+4096+8<<10)
+	default:
+		// Node structs with their maps, connection map entries and sleeping goroutines
+		return nodes*1024 + arcs*(64+4096)
+	}
+}
+
+// memoryBudget is a weighted semaphore over an estimated memory limit
+type memoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+// newMemoryBudget creates a budget with the given limit (0 is unlimited)
+func newMemoryBudget(limit int64) *memoryBudget {
+	b := &memoryBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+
+	return b
+}
+
+// acquire blocks until cost fits into the budget
+// A run larger than the whole budget waits until it can run alone
+func (b *memoryBudget) acquire(cost int64) {
+	if b.limit <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cost = min(cost, b.limit)
+	for b.used+cost > b.limit {
+		b.cond.Wait()
+	}
+	b.used += cost
+}
+
+// release returns cost to the budget
+func (b *memoryBudget) release(cost int64) {
+	if b.limit <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used -= min(cost, b.limit)
+	b.cond.Broadcast()
+}
+
+// syncWriter serializes writes from concurrent runs
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes p while holding the lock
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Write(p)
+}
@@ -0,0 +1,59 @@
+package experiment
+
+import (
+	"math/rand"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/score"
+)
+
+// scoreParams returns the peer score parameters of a run, or nil if it does not use scoring
+func (e *Experiment) scoreParams(run Run) *score.Params {
+	if run.Params["scoring"] <= 0 {
+		return nil
+	}
+
+	if e.Scoring != nil {
+		return e.Scoring
+	}
+
+	params := score.DefaultParams()
+	return &params
+}
+
+// origins returns the publisher of every message of a run. The run's origin publishes the first
+// and the last message, so the two can be compared, and random honest nodes the ones in between
+func (r Run) origins(nodeCount int, adversary *p2p.Adversary, seed int64) []int32 {
+	origins := make([]int32, max(int(r.Params["messages"]), 1))
+
+	var honest []int32
+	for i := 0; i < nodeCount; i++ {
+		if adversary.Role(i) == p2p.Honest {
+			honest = append(honest, int32(i))
+		}
+	}
+
+	rng := rand.New(rand.NewSource(seed ^ 0x61c8864680b583eb))
+	for m := range origins {
+		origins[m] = int32(r.Params["origin"])
+		if m > 0 && m < len(origins)-1 {
+			origins[m] = honest[rng.Intn(len(honest))]
+		}
+	}
+
+	return origins
+}
+
+// setPruned counts the links over which nodes no longer send because of their peer scores
+func setPruned(m *p2p.NetworkMetric, t *score.Table, a *p2p.Adversary) {
+	if t == nil {
+		return
+	}
+
+	t.Each(score.Pruned, func(i, j int32, _ score.Status) {
+		m.PrunedLinks++
+		if a.Role(int(j)) == p2p.Honest {
+			m.PrunedHonestLinks++
+		}
+	})
+}
@@ -0,0 +1,338 @@
+package experiment
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/stats"
+)
+
+// Tuning strategies
+const (
+	StrategyBisect = "bisect" // Smallest value meeting the constraint, assuming it is monotone in the parameter
+	StrategyRefine = "refine" // Coarse grid, then repeated refinement around the best value
+)
+
+// Tuning describes a search over one numeric protocol parameter
+type Tuning struct {
+	Protocol   string     `json:"protocol"`   // Protocol whose parameter is searched
+	Param      string     `json:"param"`      // Grid key of the protocol (e.g. level)
+	Min        float64    `json:"min"`        // Lower end of the search range
+	Max        float64    `json:"max"`        // Upper end of the search range
+	Step       float64    `json:"step"`       // Resolution of the search (default 1)
+	Strategy   string     `json:"strategy"`   // One of the Strategy* constants (default bisect)
+	Trials     int        `json:"trials"`     // Repetitions per evaluated value (default the experiment's repetitions)
+	Rounds     int        `json:"rounds"`     // Refinement rounds of the refine strategy (default 4)
+	Objective  string     `json:"objective"`  // Metric to minimize (default duplicate_rate)
+	Constraint Constraint `json:"constraint"` // Requirement every accepted value must meet
+}
+
+// Constraint requires a metric to stay on one side of a bound in a given share of trials
+// For example {"metric": "receiving_rate", "op": ">=", "value": 0.999, "percentile": 99}
+// accepts a value when 99% of its trials deliver to at least 99.9% of the nodes
+type Constraint struct {
+	Metric     string  `json:"metric"`     // Default receiving_rate
+	Op         string  `json:"op"`         // >= or <= (default >=)
+	Value      float64 `json:"value"`      // Bound on the metric
+	Percentile float64 `json:"percentile"` // Share of trials in percent that must meet the bound (default 99)
+}
+
+// Evaluation is the outcome of all trials of one parameter value
+type Evaluation struct {
+	Value     float64   `json:"value"`
+	Feasible  bool      `json:"feasible"`
+	Bound     float64   `json:"bound"` // Trial quantile of the constrained metric compared with the constraint
+	Objective float64   `json:"objective"`
+	Aggregate Aggregate `json:"aggregate"`
+}
+
+// TuneResult holds every evaluated value and the best one found
+type TuneResult struct {
+	Best        *Evaluation  `json:"best"` // Feasible value with the lowest objective (nil if none)
+	Evaluations []Evaluation `json:"evaluations"`
+	Pareto      []Aggregate  `json:"pareto"` // Non-dominated evaluations, see ParetoFrontier
+}
+
+// validate fills in tuning defaults and checks them against the experiment
+func (t *Tuning) validate() error {
+	if t.Step <= 0 {
+		t.Step = 1
+	}
+	if t.Strategy == "" {
+		t.Strategy = StrategyBisect
+	}
+	if t.Rounds <= 0 {
+		t.Rounds = 4
+	}
+	if t.Objective == "" {
+		t.Objective = "duplicate_rate"
+	}
+	if t.Constraint.Metric == "" {
+		t.Constraint.Metric = "receiving_rate"
+	}
+	if t.Constraint.Op == "" {
+		t.Constraint.Op = ">="
+	}
+	if t.Constraint.Percentile <= 0 {
+		t.Constraint.Percentile = 99
+	}
+
+	keys, ok := protocolKeys[t.Protocol]
+	if !ok {
+		return fmt.Errorf("tune: unknown protocol %q", t.Protocol)
+	}
+	if !keys[t.Param] {
+		return fmt.Errorf("tune: %s has no parameter %q", t.Protocol, t.Param)
+	}
+	if t.Min > t.Max {
+		return fmt.Errorf("tune: min %v is above max %v", t.Min, t.Max)
+	}
+
+	switch t.Strategy {
+	case StrategyBisect, StrategyRefine:
+	default:
+		return fmt.Errorf("tune: unknown strategy %q", t.Strategy)
+	}
+
+	if !isMetric(t.Objective) {
+		return fmt.Errorf("tune: unknown objective %q", t.Objective)
+	}
+	if !isMetric(t.Constraint.Metric) {
+		return fmt.Errorf("tune: unknown constraint metric %q", t.Constraint.Metric)
+	}
+	if t.Constraint.Op != ">=" && t.Constraint.Op != "<=" {
+		return fmt.Errorf("tune: constraint op must be >= or <=, not %q", t.Constraint.Op)
+	}
+	if t.Constraint.Percentile > 100 {
+		return fmt.Errorf("tune: constraint percentile %v is above 100", t.Constraint.Percentile)
+	}
+
+	return nil
+}
+
+// Tune searches the tuned parameter, running every evaluated value through RunAll
+// Trials are appended to the experiment's output like a sweep, so a tuning run can be resumed
+// and already evaluated values are not run again
+func (e *Experiment) Tune(opts RunOptions) (*TuneResult, error) {
+	t := e.Tuning
+	if t == nil {
+		return nil, fmt.Errorf("experiment has no tune section")
+	}
+	if n := len(product(e.Topology.Grid)); n != 1 {
+		return nil, fmt.Errorf("tuning needs a single topology, the grid has %d combinations", n)
+	}
+
+	opts.Resume = true // Trials are looked up by run ID, so each must be written only once
+	tuner := &tuner{experiment: e, opts: opts, evaluated: make(map[float64]*Evaluation)}
+
+	var err error
+	if t.Strategy == StrategyRefine {
+		err = tuner.refine()
+	} else {
+		err = tuner.bisect()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TuneResult{}
+	aggregates := make([]Aggregate, 0, len(tuner.evaluated))
+
+	for _, value := range sortedValues(tuner.evaluated) {
+		ev := tuner.evaluated[value]
+		result.Evaluations = append(result.Evaluations, *ev)
+		aggregates = append(aggregates, ev.Aggregate)
+
+		if ev.Feasible && (result.Best == nil || ev.Objective < result.Best.Objective) {
+			result.Best = ev
+		}
+	}
+
+	result.Pareto = ParetoFrontier(aggregates)
+
+	return result, nil
+}
+
+// tuner evaluates parameter values and remembers their outcomes
+type tuner struct {
+	experiment *Experiment
+	opts       RunOptions
+	evaluated  map[float64]*Evaluation
+}
+
+// bisect finds the smallest value meeting the constraint
+// Coverage grows with the fan-out parameters of the protocols here, which makes the
+// constraint monotone and the smallest feasible value the one with the fewest duplicates
+func (t *tuner) bisect() error {
+	tuning := t.experiment.Tuning
+	lo, hi := t.snap(tuning.Min), t.snap(tuning.Max)
+
+	top, err := t.evaluate(hi)
+	if err != nil || !top.Feasible {
+		return err // Nothing in range meets the constraint
+	}
+
+	bottom, err := t.evaluate(lo)
+	if err != nil || bottom.Feasible {
+		return err
+	}
+
+	// Invariant: lo is infeasible and hi is feasible
+	for hi-lo > tuning.Step*1.5 {
+		mid := t.snap((lo + hi) / 2)
+		if mid <= lo || mid >= hi {
+			break
+		}
+
+		ev, err := t.evaluate(mid)
+		if err != nil {
+			return err
+		}
+
+		if ev.Feasible {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	return nil
+}
+
+// refine evaluates a coarse grid, then repeatedly halves the spacing around the best value
+// Unlike bisect it makes no monotonicity assumption, at the cost of more evaluations
+func (t *tuner) refine() error {
+	tuning := t.experiment.Tuning
+	const points = 5
+
+	spacing := (tuning.Max - tuning.Min) / (points - 1)
+	for i := 0; i < points; i++ {
+		if _, err := t.evaluate(t.snap(tuning.Min + float64(i)*spacing)); err != nil {
+			return err
+		}
+	}
+
+	for round := 0; round < tuning.Rounds && spacing > tuning.Step; round++ {
+		spacing /= 2
+		center := t.incumbent()
+
+		for _, value := range []float64{center - spacing, center + spacing} {
+			value = t.snap(math.Min(math.Max(value, tuning.Min), tuning.Max))
+			if _, err := t.evaluate(value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// incumbent returns the feasible value with the lowest objective,
+// or the value closest to meeting the constraint if none is feasible
+func (t *tuner) incumbent() float64 {
+	var best *Evaluation
+	ge := t.experiment.Tuning.Constraint.Op == ">="
+
+	for _, value := range sortedValues(t.evaluated) {
+		ev := t.evaluated[value]
+
+		switch {
+		case best == nil:
+			best = ev
+		case ev.Feasible != best.Feasible:
+			if ev.Feasible {
+				best = ev
+			}
+		case ev.Feasible:
+			if ev.Objective < best.Objective {
+				best = ev
+			}
+		case (ge && ev.Bound > best.Bound) || (!ge && ev.Bound < best.Bound):
+			best = ev
+		}
+	}
+
+	return best.Value
+}
+
+// snap rounds a value to the search resolution
+func (t *tuner) snap(value float64) float64 {
+	tuning := t.experiment.Tuning
+	return tuning.Min + math.Round((value-tuning.Min)/tuning.Step)*tuning.Step
+}
+
+// evaluate runs every trial of a value and checks it against the constraint
+func (t *tuner) evaluate(value float64) (*Evaluation, error) {
+	if ev, ok := t.evaluated[value]; ok {
+		return ev, nil
+	}
+
+	tuning := t.experiment.Tuning
+
+	e := *t.experiment
+	e.Protocols = []Protocol{{Type: tuning.Protocol, Grid: map[string]Values{tuning.Param: {value}}}}
+	if tuning.Trials > 0 {
+		e.Repetitions = tuning.Trials
+	}
+
+	if t.opts.Log != nil {
+		fmt.Fprintf(t.opts.Log, "Evaluating %s %s=%v\n", tuning.Protocol, tuning.Param, value)
+	}
+
+	if err := e.RunAll(t.opts); err != nil {
+		return nil, err
+	}
+
+	// Pick this value's trials out of everything the output holds
+	runs := make(map[string]bool)
+	for _, run := range e.Expand() {
+		runs[run.ID] = true
+	}
+
+	rows, err := ReadResults(e.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	var trials []Result
+	for _, row := range rows {
+		if runs[row.RunID] {
+			trials = append(trials, row)
+		}
+	}
+	if len(trials) == 0 {
+		return nil, fmt.Errorf("no successful trials for %s=%v", tuning.Param, value)
+	}
+
+	aggregates := AggregateResults(trials, AggregateOptions{Seed: e.Seed})
+	c := tuning.Constraint
+
+	// The bound is the trial quantile that has to clear the constraint,
+	// e.g. the 1st percentile of receiving rates for ">=" at percentile 99
+	sorted := stats.Sorted(metricValues(trials, c.Metric))
+	ev := &Evaluation{Value: value, Aggregate: aggregates[0], Objective: aggregates[0].Metrics[tuning.Objective].Mean}
+
+	if c.Op == ">=" {
+		ev.Bound = stats.Quantile(sorted, 1-c.Percentile/100)
+		ev.Feasible = ev.Bound >= c.Value
+	} else {
+		ev.Bound = stats.Quantile(sorted, c.Percentile/100)
+		ev.Feasible = ev.Bound <= c.Value
+	}
+
+	t.evaluated[value] = ev
+
+	return ev, nil
+}
+
+// sortedValues returns the evaluated values in ascending order
+func sortedValues(evaluated map[float64]*Evaluation) []float64 {
+	values := make([]float64, 0, len(evaluated))
+	for v := range evaluated {
+		values = append(values, v)
+	}
+	sort.Float64s(values)
+
+	return values
+}
@@ -0,0 +1,233 @@
+package experiment
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the version of the result row layout written by this build
+// Version 1 is the unversioned layout of older files, see docs/results.md
+const SchemaVersion = 2
+
+// Result file formats
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// ResultWriter writes result rows to a file format
+type ResultWriter interface {
+	Write(r Result) error // Writes one complete row
+}
+
+// FormatOf returns the result format implied by a file name (.csv is CSV, anything else JSONL)
+func FormatOf(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return FormatCSV
+	}
+
+	return FormatJSONL
+}
+
+// NewResultWriter creates a writer for the given format
+// header requests a CSV header row and should be false when appending to a non-empty file
+func NewResultWriter(w io.Writer, format string, header bool) (ResultWriter, error) {
+	switch format {
+	case FormatJSONL, "":
+		return &jsonlWriter{w: w}, nil
+	case FormatCSV:
+		return &csvWriter{w: w, header: header}, nil
+	default:
+		return nil, fmt.Errorf("unknown result format %q", format)
+	}
+}
+
+// jsonlWriter writes one JSON object per line
+type jsonlWriter struct {
+	w io.Writer
+}
+
+// Write marshals the row and writes it with a single write call
+func (j *jsonlWriter) Write(r Result) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = j.w.Write(append(line, '\n'))
+	return err
+}
+
+// csvWriter writes rows in the fixed column order of csvColumns
+type csvWriter struct {
+	w      io.Writer
+	header bool // Header row still to be written
+}
+
+// Write formats the row (preceded by the header on the first call) and writes it with a single write call
+func (c *csvWriter) Write(r Result) error {
+	var b strings.Builder
+	cw := csv.NewWriter(&b)
+
+	if c.header {
+		names := make([]string, len(csvColumns))
+		for i, col := range csvColumns {
+			names[i] = col.name
+		}
+		cw.Write(names)
+	}
+
+	record := make([]string, len(csvColumns))
+	for i, col := range csvColumns {
+		record[i] = col.get(&r)
+	}
+	cw.Write(record)
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(c.w, b.String()); err != nil {
+		return err
+	}
+	c.header = false
+
+	return nil
+}
+
+// csvColumn maps a CSV column to a result field
+type csvColumn struct {
+	name string
+	get  func(r *Result) string
+	set  func(r *Result, v string) error
+}
+
+// csvColumns lists the CSV columns in file order; names match the JSON keys
+// Parameters are stored in a single column as sorted key=value pairs
+var csvColumns = []csvColumn{
+	intColumn("schema", func(r *Result) *int { return &r.Schema }),
+	stringColumn("run_id", func(r *Result) *string { return &r.RunID }),
+	stringColumn("experiment", func(r *Result) *string { return &r.Experiment }),
+	stringColumn("git_revision", func(r *Result) *string { return &r.GitRevision }),
+	stringColumn("started_at", func(r *Result) *string { return &r.StartedAt }),
+	floatColumn("wall_ms", func(r *Result) *float64 { return &r.WallMs }),
+	floatColumn("virtual_ms", func(r *Result) *float64 { return &r.VirtualMs }),
+	{
+		name: "params",
+		get:  func(r *Result) string { return formatParams(r.Params) },
+		set: func(r *Result, v string) (err error) {
+			r.Params, err = parseParams(v)
+			return err
+		},
+	},
+	intColumn("repetition", func(r *Result) *int { return &r.Repetition }),
+	{
+		name: "seed",
+		get:  func(r *Result) string { return strconv.FormatInt(r.Seed, 10) },
+		set: func(r *Result, v string) (err error) {
+			r.Seed, err = strconv.ParseInt(v, 10, 64)
+			return err
+		},
+	},
+	intColumn("node_count", func(r *Result) *int { return &r.NodeCount }),
+	stringColumn("broadcast", func(r *Result) *string { return &r.Broadcast }),
+	floatColumn("avg_degree", func(r *Result) *float64 { return &r.AvgDegree }),
+	intColumn("delay", func(r *Result) *int { return &r.Delay }),
+	floatColumn("duplicate_rate", func(r *Result) *float64 { return &r.DuplicateRate }),
+	floatColumn("receiving_rate", func(r *Result) *float64 { return &r.ReceivingRate }),
+	intColumn("receptions", func(r *Result) *int { return &r.Receptions }),
+	intColumn("reached", func(r *Result) *int { return &r.Reached }),
+	floatColumn("latency_p50_ms", func(r *Result) *float64 { return &r.LatencyP50 }),
+	floatColumn("latency_p99_ms", func(r *Result) *float64 { return &r.LatencyP99 }),
+	floatColumn("latency_max_ms", func(r *Result) *float64 { return &r.LatencyMax }),
+	intColumn("lost", func(r *Result) *int { return &r.Lost }),
+	{
+		name: "bytes",
+		get:  func(r *Result) string { return strconv.FormatInt(r.Bytes, 10) },
+		set: func(r *Result, v string) (err error) {
+			r.Bytes, err = strconv.ParseInt(v, 10, 64)
+			return err
+		},
+	},
+	floatColumn("bytes_per_node", func(r *Result) *float64 { return &r.BytesPerNode }),
+	intColumn("adversaries", func(r *Result) *int { return &r.Adversaries }),
+	floatColumn("honest_receiving_rate", func(r *Result) *float64 { return &r.HonestReceivingRate }),
+	floatColumn("honest_duplicate_rate", func(r *Result) *float64 { return &r.HonestDuplicateRate }),
+	floatColumn("honest_latency_p50_ms", func(r *Result) *float64 { return &r.HonestLatencyP50 }),
+	floatColumn("honest_latency_p99_ms", func(r *Result) *float64 { return &r.HonestLatencyP99 }),
+	intColumn("victims", func(r *Result) *int { return &r.Victims }),
+	floatColumn("victim_receiving_rate", func(r *Result) *float64 { return &r.VictimReceivingRate }),
+	floatColumn("victim_latency_p50_ms", func(r *Result) *float64 { return &r.VictimLatencyP50 }),
+	floatColumn("victim_latency_max_ms", func(r *Result) *float64 { return &r.VictimLatencyMax }),
+	intColumn("messages", func(r *Result) *int { return &r.Messages }),
+	floatColumn("initial_receiving_rate", func(r *Result) *float64 { return &r.InitialReceivingRate }),
+	floatColumn("initial_duplicate_rate", func(r *Result) *float64 { return &r.InitialDuplicateRate }),
+	floatColumn("initial_latency_p99_ms", func(r *Result) *float64 { return &r.InitialLatencyP99 }),
+	floatColumn("initial_honest_receiving_rate", func(r *Result) *float64 { return &r.InitialHonestReceivingRate }),
+	floatColumn("initial_honest_latency_p99_ms", func(r *Result) *float64 { return &r.InitialHonestLatencyP99 }),
+	intColumn("pruned_links", func(r *Result) *int { return &r.PrunedLinks }),
+	intColumn("pruned_honest_links", func(r *Result) *int { return &r.PrunedHonestLinks }),
+}
+
+// stringColumn, intColumn and floatColumn build columns over a field pointer
+func stringColumn(name string, field func(r *Result) *string) csvColumn {
+	return csvColumn{
+		name: name,
+		get:  func(r *Result) string { return *field(r) },
+		set: func(r *Result, v string) error {
+			*field(r) = v
+			return nil
+		},
+	}
+}
+
+func intColumn(name string, field func(r *Result) *int) csvColumn {
+	return csvColumn{
+		name: name,
+		get:  func(r *Result) string { return strconv.Itoa(*field(r)) },
+		set: func(r *Result, v string) (err error) {
+			*field(r), err = strconv.Atoi(v)
+			return err
+		},
+	}
+}
+
+func floatColumn(name string, field func(r *Result) *float64) csvColumn {
+	return csvColumn{
+		name: name,
+		get:  func(r *Result) string { return strconv.FormatFloat(*field(r), 'g', -1, 64) },
+		set: func(r *Result, v string) (err error) {
+			*field(r), err = strconv.ParseFloat(v, 64)
+			return err
+		},
+	}
+}
+
+// parseParams parses the key=value pairs written by formatParams
+func parseParams(s string) (map[string]float64, error) {
+	params := make(map[string]float64)
+	if s == "" {
+		return params, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed parameter %q", pair)
+		}
+
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", k, err)
+		}
+		params[k] = f
+	}
+
+	return params, nil
+}
@@ -0,0 +1,137 @@
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of the Prometheus text format written by WriteTo
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelEscaper escapes label values as the text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ServeHTTP writes the current metrics for a scrape
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	m.WriteTo(w)
+}
+
+// WriteTo writes the current metrics in the Prometheus text format
+// Latency and duration gauges are in seconds as the format's conventions ask, unlike the result files
+func (m *Monitor) WriteTo(w io.Writer) (int64, error) {
+	now := time.Now()
+	bw := bufio.NewWriter(w)
+	e := &exposition{w: bw}
+
+	e.family("p2p_runs_total", "counter", "Runs finished, by outcome")
+	e.sample("p2p_runs_total", `status="done"`, float64(m.done.Load()))
+	e.sample("p2p_runs_total", `status="failed"`, float64(m.failed.Load()))
+	e.gauge("p2p_runs_running", "Runs broadcasting now", float64(m.running.Load()))
+	e.gauge("p2p_runs_queued", "Runs waiting to start", float64(m.queued.Load()))
+
+	total := int64(0)
+	e.family("p2p_events_total", "counter", "Broadcast events, recorded live by real-time engines and counted at the end of virtual-time runs")
+	for i, kind := range kinds {
+		n := m.events[i].Load()
+		total += n
+		e.sample("p2p_events_total", fmt.Sprintf("kind=%q", kind), float64(n))
+	}
+
+	m.mu.Lock()
+	rate := m.eventRate(now, total)
+	protocols := make([]string, 0, len(m.latest))
+	for protocol := range m.latest {
+		protocols = append(protocols, protocol)
+	}
+	slices.Sort(protocols)
+	last := make([]latest, len(protocols))
+	for i, protocol := range protocols {
+		last[i] = m.latest[protocol]
+	}
+	m.mu.Unlock()
+
+	e.gauge("p2p_events_per_second", "Broadcast events per second over about the last 10 seconds", rate)
+	e.gauge("p2p_messages_in_flight", "Transmissions of running real-time broadcasts sent but not received yet", float64(m.inFlight.Load()))
+
+	// Latest result of every protocol
+	gauges := []struct {
+		name, help string
+		value      func(l latest) float64
+	}{
+		{"p2p_last_node_count", "Nodes of the network", func(l latest) float64 { return float64(l.metric.NodeCount) }},
+		{"p2p_last_receiving_rate", "Share of nodes reached", func(l latest) float64 { return l.metric.ReceivingRate }},
+		{"p2p_last_duplicate_rate", "Duplicate receptions per reached node", func(l latest) float64 { return l.metric.DuplicateRate }},
+		{"p2p_last_latency_p50_seconds", "Median first receipt latency", func(l latest) float64 { return l.metric.LatencyP50 / 1000 }},
+		{"p2p_last_latency_p99_seconds", "99th percentile first receipt latency", func(l latest) float64 { return l.metric.LatencyP99 / 1000 }},
+		{"p2p_last_bytes_per_node", "Wire bytes sent per node", func(l latest) float64 { return l.metric.BytesPerNode }},
+		{"p2p_last_wall_seconds", "Wall-clock duration of the run", func(l latest) float64 { return l.wall.Seconds() }},
+		{"p2p_last_timestamp_seconds", "Unix time the run finished", func(l latest) float64 { return float64(l.at.UnixMilli()) / 1000 }},
+	}
+	for _, g := range gauges {
+		e.family(g.name, "gauge", "Last run of each protocol: "+g.help)
+		for i, protocol := range protocols {
+			e.sample(g.name, `protocol="`+labelEscaper.Replace(protocol)+`"`, g.value(last[i]))
+		}
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	e.gauge("go_goroutines", "Number of goroutines that currently exist", float64(runtime.NumGoroutine()))
+	e.gauge("go_memstats_heap_alloc_bytes", "Heap bytes allocated and still in use", float64(mem.HeapAlloc))
+	e.gauge("go_memstats_heap_inuse_bytes", "Heap bytes in in-use spans", float64(mem.HeapInuse))
+	e.gauge("go_memstats_sys_bytes", "Bytes obtained from the system", float64(mem.Sys))
+	e.family("go_gc_cycles_total", "counter", "Completed garbage collection cycles")
+	e.sample("go_gc_cycles_total", "", float64(mem.NumGC))
+	e.gauge("process_start_time_seconds", "Unix time the process started", float64(m.start.UnixMilli())/1000)
+
+	if e.err == nil {
+		e.err = bw.Flush()
+	}
+
+	return e.n, e.err
+}
+
+// exposition writes metric families, keeping the first write error
+type exposition struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// family writes the help and type lines of a metric
+func (e *exposition) family(name, typ, help string) {
+	e.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a sample with optional labels
+func (e *exposition) sample(name, labels string, value float64) {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+
+	e.printf("%s %g\n", name, value)
+}
+
+// gauge writes a gauge with a single unlabeled sample
+func (e *exposition) gauge(name, help string, value float64) {
+	e.family(name, "gauge", help)
+	e.sample(name, "", value)
+}
+
+func (e *exposition) printf(format string, args ...any) {
+	if e.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(e.w, format, args...)
+	e.n += int64(n)
+	e.err = err
+}
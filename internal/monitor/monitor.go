@@ -0,0 +1,145 @@
+package monitor
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
+)
+
+// rateWindow is roughly the span events per second are averaged over
+const rateWindow = 10 * time.Second
+
+// kinds are the event kinds counted, in exposition order
+var kinds = []trace.Kind{trace.Publish, trace.Send, trace.Receive, trace.Duplicate, trace.Drop}
+
+// Monitor collects the progress and results of broadcasts for a Prometheus scraper
+// It is safe for concurrent use by the runs it tracks and the scrapes it serves
+type Monitor struct {
+	start    time.Time
+	queued   atomic.Int64
+	running  atomic.Int64
+	done     atomic.Int64
+	failed   atomic.Int64
+	inFlight atomic.Int64
+	events   [5]atomic.Int64 // Indexed as kinds
+
+	mu      sync.Mutex
+	latest  map[string]latest // Last successful run of every protocol
+	samples []sample          // Event totals at recent scrapes, for the event rate
+}
+
+// latest is the outcome of the last successful run of a protocol
+type latest struct {
+	metric p2p.NetworkMetric
+	wall   time.Duration
+	at     time.Time
+}
+
+// sample is the event total at a point in time
+type sample struct {
+	at     time.Time
+	events int64
+}
+
+// New creates a monitor with no runs
+func New() *Monitor {
+	now := time.Now()
+
+	return &Monitor{
+		start:   now,
+		latest:  map[string]latest{},
+		samples: []sample{{at: now}},
+	}
+}
+
+// Queue counts n more runs waiting to start (negative to withdraw them)
+func (m *Monitor) Queue(n int) {
+	m.queued.Add(int64(n))
+}
+
+// Start moves a queued run to running and returns the tracker of its broadcast
+func (m *Monitor) Start() *Run {
+	m.queued.Add(-1)
+	m.running.Add(1)
+
+	return &Run{monitor: m}
+}
+
+// Run tracks a running broadcast; as a trace.Recorder it counts the events of real-time engines
+type Run struct {
+	monitor  *Monitor
+	recorded atomic.Int64 // Events recorded by the run
+	inFlight atomic.Int64 // Transmissions sent but not received yet
+}
+
+// Record counts an event recorded by a node
+// Transmissions are in flight from their send until their receipt or duplicate;
+// copies a receiver discards as invalid stay counted until the run finishes
+func (r *Run) Record(e trace.Event) {
+	r.recorded.Add(1)
+	r.monitor.count(e.Kind, 1)
+
+	switch e.Kind {
+	case trace.Send:
+		r.inFlight.Add(1)
+		r.monitor.inFlight.Add(1)
+	case trace.Receive, trace.Duplicate:
+		r.inFlight.Add(-1)
+		r.monitor.inFlight.Add(-1)
+	}
+}
+
+// Finish records the outcome of the run: its metric, or nil if it failed, and its wall-clock duration
+// The receptions of virtual-time engines, which record no events, are counted from the metric
+func (r *Run) Finish(metric *p2p.NetworkMetric, wall time.Duration) {
+	m := r.monitor
+	m.inFlight.Add(-r.inFlight.Swap(0)) // Lost or discarded transmissions
+	m.running.Add(-1)
+
+	if metric == nil {
+		m.failed.Add(1)
+		return
+	}
+
+	if r.recorded.Load() == 0 {
+		receives := int64(metric.Reached) // First receipts, which the origin has none of
+		m.count(trace.Receive, receives)
+		m.count(trace.Duplicate, max(int64(metric.Receptions)-receives, 0))
+	}
+
+	m.mu.Lock()
+	m.latest[metric.Broadcast] = latest{metric: *metric, wall: wall, at: time.Now()}
+	m.mu.Unlock()
+
+	m.done.Add(1)
+}
+
+// count adds n events of a kind
+func (m *Monitor) count(kind trace.Kind, n int64) {
+	for i, k := range kinds {
+		if k == kind {
+			m.events[i].Add(n)
+			return
+		}
+	}
+}
+
+// eventRate returns the events per second since the oldest sample within rateWindow,
+// or since the start before the first scrape; the caller holds the lock
+func (m *Monitor) eventRate(now time.Time, total int64) float64 {
+	m.samples = append(m.samples, sample{at: now, events: total})
+	for len(m.samples) > 2 && now.Sub(m.samples[1].at) >= rateWindow {
+		m.samples = m.samples[1:]
+	}
+
+	first := m.samples[0]
+	elapsed := now.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(total-first.events) / elapsed
+}
@@ -0,0 +1,47 @@
+package network
+
+import (
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// SetAdversary assigns the roles of an adversary to the nodes (nil makes every node honest again)
+// Metric then also reports how the honest nodes fared
+func (n *Network) SetAdversary(a *p2p.Adversary) {
+	n.adversary = a
+
+	for i := range n.Nodes {
+		n.Nodes[i].SetRole(a.Role(i), a)
+	}
+}
+
+// Adversary returns the roles assigned with SetAdversary
+func (n *Network) Adversary() *p2p.Adversary {
+	return n.adversary
+}
+
+// setHonest fills the honest-node metrics of a message from the nodes' receive records
+func (n *Network) setHonest(metric *p2p.NetworkMetric, mid p2p.MessageID) {
+	times := make([]time.Time, len(n.Nodes))
+	reached := make([]bool, len(n.Nodes))
+	origin := -1
+
+	for i := range n.Nodes {
+		times[i], reached[i] = n.Nodes[i].RelayTime(mid)
+		if reached[i] && (origin < 0 || times[i].Before(times[origin])) {
+			origin = i // The origin relays first, as in Propagation
+		}
+	}
+
+	if origin < 0 {
+		return // Never published
+	}
+
+	metric.SetHonest(n.adversary, p2p.HonestView{
+		Origin:   origin,
+		Reached:  func(i int) bool { return reached[i] },
+		Latency:  func(i int) float64 { return float64(times[i].Sub(times[origin])) / float64(time.Millisecond) },
+		Receipts: func(i int) int { return len(n.Nodes[i].ReceiveRoute(mid)) },
+	})
+}
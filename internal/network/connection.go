@@ -2,23 +2,25 @@ package network
 
 import (
 	"math/rand"
+	"slices"
 
 	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
 )
 
-// makeRandomConnection creates a random bidirectional connection between two random nodes
+// makeRandomConnection creates a bidirectional connection with the given link delay between two random nodes
 func (n *Network) makeRandomConnection(link p2p.Delay) bool {
 	if len(n.Nodes) < 2 {
 		return false // Not enough nodes to make a connection
 	}
 
 	// Select two different random nodes
-	nodeA := rand.Uint64() % uint64(len(n.Nodes))
-	nodeB := rand.Uint64() % uint64(len(n.Nodes))
+	rng := n.random()
+	nodeA := rng.Uint64() % uint64(len(n.Nodes))
+	nodeB := rng.Uint64() % uint64(len(n.Nodes))
 
 	// Ensure nodeB is different from nodeA
 	for nodeB == nodeA {
-		nodeB = rand.Uint64() % uint64(len(n.Nodes))
+		nodeB = rng.Uint64() % uint64(len(n.Nodes))
 	}
 
 	// Check if connection already exists
@@ -26,10 +28,7 @@ func (n *Network) makeRandomConnection(link p2p.Delay) bool {
 		return false // Connection already exists
 	}
 
-	// Generate random link delay within the specified range
-	linkDelay := p2p.Delay(rand.Uint64() % uint64(link))
-
-	n.AddBidirectConnection(nodeA, nodeB, linkDelay)
+	n.AddBidirectConnection(nodeA, nodeB, link)
 
 	return true
 }
@@ -87,6 +86,17 @@ func (n *Network) RemoveConnection(nodeA uint64, nodeB uint64) {
 	delete(n.Nodes[nodeB].Connections(), &n.Nodes[nodeA])
 }
 
+// neighbor returns the k-th neighbor of a node in ascending ID order
+func (n *Network) neighbor(i int, k uint64) uint64 {
+	ids := make([]uint64, 0, len(n.Nodes[i].Connections()))
+	for conn := range n.Nodes[i].Connections() {
+		ids = append(ids, uint64(conn.ID()))
+	}
+	slices.Sort(ids)
+
+	return ids[k]
+}
+
 // AvgDegree calculates the average degree (number of connections) across all nodes
 // Returns 0 if the network has no nodes
 func (n *Network) AvgDegree() float64 {
@@ -106,11 +116,11 @@ func (n *Network) AvgDegree() float64 {
 
 // delay generates a random delay value within the specified range [min, max]
 // Ensures min <= max by swapping if necessary
-func delay(min, max p2p.Delay) p2p.Delay {
+func delay(rng *rand.Rand, min, max p2p.Delay) p2p.Delay {
 	if min > max {
 		min, max = max, min // Ensure min is less than or equal to max
 	}
 
 	// Generate random value in range [min, max] inclusive
-	return p2p.Delay(rand.Uint64()%(uint64(max)-uint64(min)+1) + uint64(min))
+	return p2p.Delay(rng.Uint64()%(uint64(max)-uint64(min)+1) + uint64(min))
 }
@@ -0,0 +1,599 @@
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// dotToken kinds produced by the DOT lexer
+const (
+	dotEOF   = iota // End of input
+	dotID           // Identifier, numeral, quoted or HTML string
+	dotPunct        // Single-character punctuation: { } [ ] ; , = :
+	dotEdge         // Edge operator: -- or ->
+)
+
+// dotToken is a single lexical token of a DOT document
+type dotToken struct {
+	kind   int
+	text   string
+	quoted bool // Quoted strings are never treated as keywords
+}
+
+// dotParser is a recursive-descent parser for the Graphviz DOT language
+// Subgraphs are flattened and ports/compass points on node IDs are ignored
+type dotParser struct {
+	tokens   []dotToken
+	pos      int
+	directed bool
+	b        *graphBuilder
+}
+
+// LoadDOT builds a network from a Graphviz DOT document
+// Node and edge delays are read from attributes matching the configured attribute names,
+// including values inherited from "node [...]" and "edge [...]" default statements
+func LoadDOT(r io.Reader, config LoadConfig) (*Network, map[string]p2p.NodeID, error) {
+	tokens, err := lexDOT(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("dot: %w", err)
+	}
+
+	p := &dotParser{
+		tokens: tokens,
+		b:      newGraphBuilder(config),
+	}
+
+	if err := p.parseGraph(); err != nil {
+		return nil, nil, fmt.Errorf("dot: %w", err)
+	}
+
+	network, ids := p.b.build()
+
+	return network, ids, nil
+}
+
+// lexDOT splits a DOT document into tokens, discarding comments and preprocessor lines
+func lexDOT(r io.Reader) ([]dotToken, error) {
+	br := bufio.NewReader(r)
+	var tokens []dotToken
+	lineStart := true
+
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case c == '\n':
+			lineStart = true
+			continue
+		case unicode.IsSpace(c):
+			continue
+		case c == '#' && lineStart:
+			// Preprocessor output line, skip to end of line
+			if _, err := br.ReadString('\n'); err != nil && err != io.EOF {
+				return nil, err
+			}
+			continue
+		}
+
+		lineStart = false
+
+		switch {
+		case c == '/':
+			next, _, err := br.ReadRune()
+			if err != nil {
+				return nil, fmt.Errorf("unexpected '/'")
+			}
+
+			if next == '/' {
+				if _, err := br.ReadString('\n'); err != nil && err != io.EOF {
+					return nil, err
+				}
+				lineStart = true
+			} else if next == '*' {
+				if err := skipBlockComment(br); err != nil {
+					return nil, err
+				}
+			} else {
+				return nil, fmt.Errorf("unexpected '/'")
+			}
+		case c == '"':
+			text, err := readQuoted(br)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, dotToken{kind: dotID, text: text, quoted: true})
+		case c == '<':
+			text, err := readHTML(br)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, dotToken{kind: dotID, text: text, quoted: true})
+		case c == '-':
+			next, _, err := br.ReadRune()
+			if err == nil && (next == '-' || next == '>') {
+				tokens = append(tokens, dotToken{kind: dotEdge, text: string([]rune{c, next})})
+				continue
+			}
+			if err == nil {
+				br.UnreadRune()
+			}
+
+			// Negative numeral
+			text := "-" + readWhile(br, isDOTNumeral)
+			tokens = append(tokens, dotToken{kind: dotID, text: text})
+		case strings.ContainsRune("{}[];,=:", c):
+			tokens = append(tokens, dotToken{kind: dotPunct, text: string(c)})
+		case isDOTIDStart(c) || isDOTNumeral(c):
+			br.UnreadRune()
+
+			var text string
+			if isDOTIDStart(c) {
+				text = readWhile(br, isDOTIDPart)
+			} else {
+				text = readWhile(br, isDOTNumeral)
+			}
+			tokens = append(tokens, dotToken{kind: dotID, text: text})
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+
+	return append(tokens, dotToken{kind: dotEOF}), nil
+}
+
+// skipBlockComment consumes input until the end of a /* ... */ comment
+func skipBlockComment(br *bufio.Reader) error {
+	prev := rune(0)
+
+	for {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			return fmt.Errorf("unterminated comment")
+		}
+
+		if prev == '*' && c == '/' {
+			return nil
+		}
+		prev = c
+	}
+}
+
+// readQuoted reads a double-quoted string, handling escaped quotes and line continuations
+func readQuoted(br *bufio.Reader) (string, error) {
+	var sb strings.Builder
+
+	for {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			return "", fmt.Errorf("unterminated string")
+		}
+
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			next, _, err := br.ReadRune()
+			if err != nil {
+				return "", fmt.Errorf("unterminated string")
+			}
+
+			if next == '\n' {
+				continue // Line continuation
+			}
+			if next != '"' {
+				sb.WriteRune(c)
+			}
+			sb.WriteRune(next)
+		default:
+			sb.WriteRune(c)
+		}
+	}
+}
+
+// readHTML reads an HTML string delimited by balanced angle brackets
+func readHTML(br *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	depth := 1
+
+	for {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			return "", fmt.Errorf("unterminated HTML string")
+		}
+
+		if c == '<' {
+			depth++
+		} else if c == '>' {
+			depth--
+			if depth == 0 {
+				return sb.String(), nil
+			}
+		}
+		sb.WriteRune(c)
+	}
+}
+
+// readWhile reads runes as long as they satisfy the predicate
+func readWhile(br *bufio.Reader, pred func(rune) bool) string {
+	var sb strings.Builder
+
+	for {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			break
+		}
+		if !pred(c) {
+			br.UnreadRune()
+			break
+		}
+		sb.WriteRune(c)
+	}
+
+	return sb.String()
+}
+
+// isDOTIDStart reports whether a rune can start an unquoted DOT identifier
+func isDOTIDStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || c >= 0x80
+}
+
+// isDOTIDPart reports whether a rune can continue an unquoted DOT identifier
+func isDOTIDPart(c rune) bool {
+	return isDOTIDStart(c) || unicode.IsDigit(c)
+}
+
+// isDOTNumeral reports whether a rune can be part of a DOT numeral
+func isDOTNumeral(c rune) bool {
+	return c == '.' || unicode.IsDigit(c)
+}
+
+// peek returns the current token without consuming it
+func (p *dotParser) peek() dotToken {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the current token
+func (p *dotParser) next() dotToken {
+	t := p.tokens[p.pos]
+	if t.kind != dotEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// isKeyword reports whether a token is the given case-insensitive keyword
+func (t dotToken) isKeyword(keyword string) bool {
+	return t.kind == dotID && !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+// isPunct reports whether a token is the given punctuation character
+func (t dotToken) isPunct(punct string) bool {
+	return t.kind == dotPunct && t.text == punct
+}
+
+// expect consumes the given punctuation or returns an error
+func (p *dotParser) expect(punct string) error {
+	t := p.next()
+	if !t.isPunct(punct) {
+		return fmt.Errorf("expected %q, got %q", punct, t.text)
+	}
+
+	return nil
+}
+
+// parseGraph parses: [strict] (graph | digraph) [ID] '{' stmt_list '}'
+func (p *dotParser) parseGraph() error {
+	if p.peek().isKeyword("strict") {
+		p.next()
+	}
+
+	t := p.next()
+	switch {
+	case t.isKeyword("graph"):
+		p.directed = false
+	case t.isKeyword("digraph"):
+		p.directed = true
+	default:
+		return fmt.Errorf("expected graph or digraph, got %q", t.text)
+	}
+
+	if p.peek().kind == dotID {
+		p.next() // Graph name
+	}
+
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	_, err := p.parseStmtList(map[string]string{}, map[string]string{})
+
+	return err
+}
+
+// parseStmtList parses statements until the closing brace and returns the nodes they mention
+// Node and edge defaults are scoped to the enclosing (sub)graph
+func (p *dotParser) parseStmtList(nodeDefaults, edgeDefaults map[string]string) ([]string, error) {
+	nodeDefaults = copyAttrs(nodeDefaults)
+	edgeDefaults = copyAttrs(edgeDefaults)
+	var members []string
+
+	for {
+		t := p.peek()
+
+		switch {
+		case t.kind == dotEOF:
+			return nil, fmt.Errorf("unexpected end of input")
+		case t.isPunct("}"):
+			p.next()
+			return members, nil
+		case t.isPunct(";"):
+			p.next()
+		case t.isKeyword("graph"):
+			p.next()
+			if _, err := p.parseAttrLists(); err != nil {
+				return nil, err
+			}
+		case t.isKeyword("node"):
+			p.next()
+			attrs, err := p.parseAttrLists()
+			if err != nil {
+				return nil, err
+			}
+			mergeAttrs(nodeDefaults, attrs)
+		case t.isKeyword("edge"):
+			p.next()
+			attrs, err := p.parseAttrLists()
+			if err != nil {
+				return nil, err
+			}
+			mergeAttrs(edgeDefaults, attrs)
+		default:
+			nodes, err := p.parseNodeOrEdgeStmt(nodeDefaults, edgeDefaults)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, nodes...)
+		}
+	}
+}
+
+// parseNodeOrEdgeStmt parses a node statement, an edge statement, a subgraph or an ID '=' ID assignment
+func (p *dotParser) parseNodeOrEdgeStmt(nodeDefaults, edgeDefaults map[string]string) ([]string, error) {
+	// Graph attribute assignment: ID '=' ID
+	if p.peek().kind == dotID && p.tokens[p.pos+1].isPunct("=") {
+		p.next()
+		p.next()
+		if t := p.next(); t.kind != dotID {
+			return nil, fmt.Errorf("expected attribute value, got %q", t.text)
+		}
+		return nil, nil
+	}
+
+	first, isSubgraph, err := p.parseEndpoint(nodeDefaults, edgeDefaults)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := [][]string{first}
+
+	for p.peek().kind == dotEdge {
+		op := p.next()
+		if (op.text == "->") != p.directed {
+			return nil, fmt.Errorf("edge operator %q does not match graph type", op.text)
+		}
+
+		group, _, err := p.parseEndpoint(nodeDefaults, edgeDefaults)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	attrs, err := p.parseAttrLists()
+	if err != nil {
+		return nil, err
+	}
+
+	var members []string
+	for _, group := range groups {
+		members = append(members, group...)
+	}
+
+	if len(groups) == 1 {
+		if isSubgraph {
+			return members, nil
+		}
+
+		// Node statement: defaults were applied when the node was first seen
+		if err := p.applyNodeAttrs(first[0], attrs); err != nil {
+			return nil, err
+		}
+
+		return members, nil
+	}
+
+	// Edge statement: connect every node of each group to every node of the next
+	merged := copyAttrs(edgeDefaults)
+	mergeAttrs(merged, attrs)
+	delayValue := merged[p.b.config.linkDelayAttr()]
+
+	for i := 0; i+1 < len(groups); i++ {
+		for _, from := range groups[i] {
+			for _, to := range groups[i+1] {
+				if err := p.b.edge(from, to, delayValue, p.directed); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return members, nil
+}
+
+// parseEndpoint parses a node ID (with optional port) or a subgraph and returns the nodes it denotes
+func (p *dotParser) parseEndpoint(nodeDefaults, edgeDefaults map[string]string) ([]string, bool, error) {
+	t := p.peek()
+
+	if t.isKeyword("subgraph") || t.isPunct("{") {
+		if t.isKeyword("subgraph") {
+			p.next()
+			if p.peek().kind == dotID {
+				p.next() // Subgraph name
+			}
+		}
+
+		if err := p.expect("{"); err != nil {
+			return nil, false, err
+		}
+
+		members, err := p.parseStmtList(nodeDefaults, edgeDefaults)
+
+		return members, true, err
+	}
+
+	if t.kind != dotID {
+		return nil, false, fmt.Errorf("expected node ID, got %q", t.text)
+	}
+	p.next()
+
+	// Skip port and compass point: ID [':' ID [':' ID]]
+	for p.peek().isPunct(":") {
+		p.next()
+		if port := p.next(); port.kind != dotID {
+			return nil, false, fmt.Errorf("expected port, got %q", port.text)
+		}
+	}
+
+	// Node defaults only apply to nodes created by this statement
+	if _, known := p.b.ids[t.text]; !known {
+		if err := p.applyNodeAttrs(t.text, nodeDefaults); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return []string{t.text}, false, nil
+}
+
+// applyNodeAttrs registers a node and records its delay attribute if present
+func (p *dotParser) applyNodeAttrs(name string, attrs map[string]string) error {
+	p.b.node(name)
+
+	if value, ok := attrs[p.b.config.nodeDelayAttr()]; ok {
+		return p.b.setNodeDelay(name, value)
+	}
+
+	return nil
+}
+
+// parseAttrLists parses zero or more '[' a_list ']' blocks into a single attribute map
+func (p *dotParser) parseAttrLists() (map[string]string, error) {
+	attrs := make(map[string]string)
+
+	for p.peek().isPunct("[") {
+		p.next()
+
+		for !p.peek().isPunct("]") {
+			key := p.next()
+			if key.kind != dotID {
+				return nil, fmt.Errorf("expected attribute name, got %q", key.text)
+			}
+
+			value := "true"
+			if p.peek().isPunct("=") {
+				p.next()
+				v := p.next()
+				if v.kind != dotID {
+					return nil, fmt.Errorf("expected attribute value, got %q", v.text)
+				}
+				value = v.text
+			}
+			attrs[key.text] = value
+
+			if p.peek().isPunct(",") || p.peek().isPunct(";") {
+				p.next()
+			}
+		}
+		p.next() // Closing bracket
+	}
+
+	return attrs, nil
+}
+
+// copyAttrs returns a shallow copy of an attribute map
+func copyAttrs(attrs map[string]string) map[string]string {
+	result := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		result[k] = v
+	}
+
+	return result
+}
+
+// mergeAttrs copies all attributes from src into dst, overwriting existing keys
+func mergeAttrs(dst, src map[string]string) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// WriteDOT writes the network topology as a Graphviz DOT document
+// Node processing delays and link delays are written as "delay" attributes
+func (n *Network) WriteDOT(w io.Writer) error {
+	edges, directed := n.exportEdges()
+
+	keyword, op := "graph", "--"
+	if directed {
+		keyword, op = "digraph", "->"
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s network {\n", keyword)
+
+	for i := range n.Nodes {
+		fmt.Fprintf(bw, "  %d [delay=%d];\n", n.Nodes[i].ID(), n.Nodes[i].Delay())
+	}
+
+	for _, e := range edges {
+		fmt.Fprintf(bw, "  %d %s %d [delay=%d];\n", e.from, op, e.to, e.delay)
+	}
+
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
+
+// WritePropagationDOT writes the propagation of a message as a Graphviz DOT digraph
+// Times are milliseconds since the broadcast started. Tree edges are solid; duplicate
+// edges are dashed and only written when duplicates is set
+func (p *Propagation) WritePropagationDOT(w io.Writer, duplicates bool) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph propagation_%d {\n", p.Message)
+
+	for _, id := range p.sortedNodeIDs() {
+		fmt.Fprintf(bw, "  %d [time=%.3f, origin=%t];\n", id, millis(p.Received[id]), id == p.Origin)
+	}
+
+	for _, e := range p.filteredEdges(duplicates) {
+		style := "solid"
+		if !e.First {
+			style = "dashed"
+		}
+
+		fmt.Fprintf(bw, "  %d -> %d [time=%.3f, first=%t, style=%s];\n", e.From, e.To, millis(e.Time), e.First, style)
+	}
+
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
@@ -0,0 +1,79 @@
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// edgeListHeaders contains first-column names recognized as a header row
+var edgeListHeaders = map[string]bool{
+	"source": true,
+	"src":    true,
+	"from":   true,
+	"node1":  true,
+}
+
+// LoadEdgeList builds a network from a CSV or whitespace-separated edge list
+// Each line holds "source target [delay]", lines starting with '#' or '%' are comments
+// and an optional header row is skipped. Returns the network and the mapping from
+// external node identifiers to NodeIDs
+func LoadEdgeList(r io.Reader, config LoadConfig) (*Network, map[string]p2p.NodeID, error) {
+	b := newGraphBuilder(config)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "%") {
+			continue // Skip blank lines and comments
+		}
+
+		fields := splitEdgeLine(line)
+
+		if len(fields) < 2 {
+			return nil, nil, fmt.Errorf("line %d: expected at least two columns", lineNo)
+		}
+
+		// Skip a header row such as "source,target,delay"
+		if len(b.names) == 0 && len(b.edges) == 0 && edgeListHeaders[strings.ToLower(fields[0])] {
+			continue
+		}
+
+		delayValue := ""
+		if len(fields) >= 3 {
+			delayValue = fields[2]
+		}
+
+		if err := b.edge(fields[0], fields[1], delayValue, config.Directed); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	network, ids := b.build()
+
+	return network, ids, nil
+}
+
+// splitEdgeLine splits a line on commas, semicolons, tabs or spaces and trims surrounding quotes
+func splitEdgeLine(line string) []string {
+	fields := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t'
+	})
+
+	for i := range fields {
+		fields[i] = strings.Trim(fields[i], `"'`)
+	}
+
+	return fields
+}
@@ -0,0 +1,125 @@
+package network
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// gexfDoc mirrors the subset of the GEXF schema used for topologies
+type gexfDoc struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+// gexfGraph holds attribute declarations, nodes and edges
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+// gexfAttributes declares the attributes of one element class (node or edge)
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+// gexfAttribute declares a single typed attribute
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+// gexfNode is a single node element with its attribute values
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr,omitempty"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+// gexfEdge is a single edge element with its attribute values
+type gexfEdge struct {
+	ID        string         `xml:"id,attr"`
+	Source    string         `xml:"source,attr"`
+	Target    string         `xml:"target,attr"`
+	Type      string         `xml:"type,attr,omitempty"`
+	Weight    string         `xml:"weight,attr,omitempty"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+// gexfAttValue holds a value for a declared attribute
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// LoadGEXF builds a network from a GEXF document
+// Delays are read from attributes whose title or id matches the configured attribute names.
+// If the link delay attribute is named "weight", the edge weight is used as the link delay
+func LoadGEXF(r io.Reader, config LoadConfig) (*Network, map[string]p2p.NodeID, error) {
+	var doc gexfDoc
+
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("gexf: %w", err)
+	}
+
+	// Resolve attribute IDs holding node and edge delays
+	nodeAttrs := map[string]bool{config.nodeDelayAttr(): true}
+	edgeAttrs := map[string]bool{config.linkDelayAttr(): true}
+
+	for _, attrs := range doc.Graph.Attributes {
+		for _, attr := range attrs.Attributes {
+			if attrs.Class == "node" && attr.Title == config.nodeDelayAttr() {
+				nodeAttrs[attr.ID] = true
+			}
+			if attrs.Class == "edge" && attr.Title == config.linkDelayAttr() {
+				edgeAttrs[attr.ID] = true
+			}
+		}
+	}
+
+	directedDefault := doc.Graph.DefaultEdgeType == "directed"
+	b := newGraphBuilder(config)
+
+	for _, n := range doc.Graph.Nodes {
+		b.node(n.ID)
+
+		for _, v := range n.AttValues {
+			if nodeAttrs[v.For] {
+				if err := b.setNodeDelay(n.ID, v.Value); err != nil {
+					return nil, nil, fmt.Errorf("gexf: %w", err)
+				}
+			}
+		}
+	}
+
+	for _, e := range doc.Graph.Edges {
+		directed := directedDefault
+		if e.Type != "" {
+			directed = e.Type == "directed"
+		}
+
+		delayValue := ""
+		if config.linkDelayAttr() == "weight" {
+			delayValue = e.Weight
+		}
+		for _, v := range e.AttValues {
+			if edgeAttrs[v.For] {
+				delayValue = v.Value
+			}
+		}
+
+		if err := b.edge(e.Source, e.Target, delayValue, directed); err != nil {
+			return nil, nil, fmt.Errorf("gexf: %w", err)
+		}
+	}
+
+	network, ids := b.build()
+
+	return network, ids, nil
+}
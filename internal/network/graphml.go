@@ -0,0 +1,227 @@
+package network
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// graphMLDoc mirrors the subset of the GraphML schema used for topologies
+type graphMLDoc struct {
+	XMLName xml.Name       `xml:"graphml"`
+	Xmlns   string         `xml:"xmlns,attr,omitempty"`
+	Keys    []graphMLKey   `xml:"key"`
+	Graphs  []graphMLGraph `xml:"graph"`
+}
+
+// graphMLKey declares an attribute available on nodes or edges
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+// graphMLGraph holds the nodes and edges of a single graph
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+// graphMLNode is a single node element with its data values
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+// graphMLEdge is a single edge element with its data values
+type graphMLEdge struct {
+	ID       string        `xml:"id,attr,omitempty"`
+	Source   string        `xml:"source,attr"`
+	Target   string        `xml:"target,attr"`
+	Directed string        `xml:"directed,attr,omitempty"`
+	Data     []graphMLData `xml:"data"`
+}
+
+// graphMLData holds a value for a declared key
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// LoadGraphML builds a network from a GraphML document
+// Only the first graph in the document is imported. Node and edge delays are read from
+// keys whose attr.name matches the configured attribute names (or whose id matches directly)
+func LoadGraphML(r io.Reader, config LoadConfig) (*Network, map[string]p2p.NodeID, error) {
+	var doc graphMLDoc
+
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("graphml: %w", err)
+	}
+
+	if len(doc.Graphs) == 0 {
+		return nil, nil, fmt.Errorf("graphml: document contains no graph")
+	}
+
+	// Resolve key IDs holding node and edge delays
+	nodeKeys := make(map[string]bool)
+	edgeKeys := make(map[string]bool)
+
+	for _, key := range doc.Keys {
+		if (key.For == "node" || key.For == "all") && (key.Name == config.nodeDelayAttr() || key.ID == config.nodeDelayAttr()) {
+			nodeKeys[key.ID] = true
+		}
+		if (key.For == "edge" || key.For == "all") && (key.Name == config.linkDelayAttr() || key.ID == config.linkDelayAttr()) {
+			edgeKeys[key.ID] = true
+		}
+	}
+
+	graph := doc.Graphs[0]
+	directedDefault := graph.EdgeDefault == "directed"
+	b := newGraphBuilder(config)
+
+	for _, n := range graph.Nodes {
+		b.node(n.ID)
+
+		for _, data := range n.Data {
+			if nodeKeys[data.Key] {
+				if err := b.setNodeDelay(n.ID, data.Value); err != nil {
+					return nil, nil, fmt.Errorf("graphml: %w", err)
+				}
+			}
+		}
+	}
+
+	for _, e := range graph.Edges {
+		directed := directedDefault
+		if e.Directed != "" {
+			directed = e.Directed == "true"
+		}
+
+		delayValue := ""
+		for _, data := range e.Data {
+			if edgeKeys[data.Key] {
+				delayValue = data.Value
+			}
+		}
+
+		if err := b.edge(e.Source, e.Target, delayValue, directed); err != nil {
+			return nil, nil, fmt.Errorf("graphml: %w", err)
+		}
+	}
+
+	network, ids := b.build()
+
+	return network, ids, nil
+}
+
+// graphMLNamespace is the XML namespace of GraphML documents
+const graphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+
+// WriteGraphML writes the network topology as a GraphML document
+// Node processing delays and link delays are written under keys named "delay"
+func (n *Network) WriteGraphML(w io.Writer) error {
+	edges, directed := n.exportEdges()
+
+	graph := graphMLGraph{
+		ID:          "network",
+		EdgeDefault: "undirected",
+	}
+	if directed {
+		graph.EdgeDefault = "directed"
+	}
+
+	for i := range n.Nodes {
+		graph.Nodes = append(graph.Nodes, graphMLNode{
+			ID:   graphMLID(n.Nodes[i].ID()),
+			Data: []graphMLData{{Key: "d0", Value: strconv.FormatUint(uint64(n.Nodes[i].Delay()), 10)}},
+		})
+	}
+
+	for _, e := range edges {
+		graph.Edges = append(graph.Edges, graphMLEdge{
+			Source: graphMLID(e.from),
+			Target: graphMLID(e.to),
+			Data:   []graphMLData{{Key: "d1", Value: strconv.FormatUint(uint64(e.delay), 10)}},
+		})
+	}
+
+	return writeGraphML(w, graphMLDoc{
+		Xmlns: graphMLNamespace,
+		Keys: []graphMLKey{
+			{ID: "d0", For: "node", Name: "delay", Type: "long"},
+			{ID: "d1", For: "edge", Name: "delay", Type: "long"},
+		},
+		Graphs: []graphMLGraph{graph},
+	})
+}
+
+// WritePropagationGraphML writes the propagation of a message as a directed GraphML document
+// Times are milliseconds since the broadcast started. Duplicate edges are only written when
+// duplicates is set and are marked with first=false
+func (p *Propagation) WritePropagationGraphML(w io.Writer, duplicates bool) error {
+	graph := graphMLGraph{
+		ID:          "propagation_" + strconv.FormatUint(uint64(p.Message), 10),
+		EdgeDefault: "directed",
+	}
+
+	for _, id := range p.sortedNodeIDs() {
+		graph.Nodes = append(graph.Nodes, graphMLNode{
+			ID: graphMLID(id),
+			Data: []graphMLData{
+				{Key: "d0", Value: strconv.FormatFloat(millis(p.Received[id]), 'f', 3, 64)},
+				{Key: "d1", Value: strconv.FormatBool(id == p.Origin)},
+			},
+		})
+	}
+
+	for _, e := range p.filteredEdges(duplicates) {
+		graph.Edges = append(graph.Edges, graphMLEdge{
+			Source: graphMLID(e.From),
+			Target: graphMLID(e.To),
+			Data: []graphMLData{
+				{Key: "d2", Value: strconv.FormatFloat(millis(e.Time), 'f', 3, 64)},
+				{Key: "d3", Value: strconv.FormatBool(e.First)},
+			},
+		})
+	}
+
+	return writeGraphML(w, graphMLDoc{
+		Xmlns: graphMLNamespace,
+		Keys: []graphMLKey{
+			{ID: "d0", For: "node", Name: "time", Type: "double"},
+			{ID: "d1", For: "node", Name: "origin", Type: "boolean"},
+			{ID: "d2", For: "edge", Name: "time", Type: "double"},
+			{ID: "d3", For: "edge", Name: "first", Type: "boolean"},
+		},
+		Graphs: []graphMLGraph{graph},
+	})
+}
+
+// writeGraphML encodes a GraphML document with an XML header
+func writeGraphML(w io.Writer, doc graphMLDoc) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// graphMLID formats a node ID as a GraphML element ID
+func graphMLID(id p2p.NodeID) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
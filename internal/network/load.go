@@ -0,0 +1,182 @@
+package network
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/node"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// LoadConfig contains configuration parameters for importing an external topology
+type LoadConfig struct {
+	Directed      bool      // Create unidirectional connections for edge lists (other formats declare direction themselves)
+	NodeDelayAttr string    // Attribute name holding node processing delay (default "delay")
+	LinkDelayAttr string    // Attribute name holding link transmission delay (default "delay")
+	MinNodeDelay  p2p.Delay // Minimum processing delay for nodes without a delay attribute
+	MaxNodeDelay  p2p.Delay // Maximum processing delay for nodes without a delay attribute
+	MinLinkDelay  p2p.Delay // Minimum transmission delay for links without a delay attribute
+	MaxLinkDelay  p2p.Delay // Maximum transmission delay for links without a delay attribute
+	Seed          int64     // Random seed for missing delays (0 picks a time-based seed)
+}
+
+// nodeDelayAttr returns the attribute name used for node delays
+func (c LoadConfig) nodeDelayAttr() string {
+	if c.NodeDelayAttr == "" {
+		return "delay"
+	}
+
+	return c.NodeDelayAttr
+}
+
+// linkDelayAttr returns the attribute name used for link delays
+func (c LoadConfig) linkDelayAttr() string {
+	if c.LinkDelayAttr == "" {
+		return "delay"
+	}
+
+	return c.LinkDelayAttr
+}
+
+// edgeSpec describes a single imported edge before nodes are materialized
+type edgeSpec struct {
+	from     p2p.NodeID // Source node
+	to       p2p.NodeID // Target node
+	delay    p2p.Delay  // Link delay (valid only if hasDelay is set)
+	hasDelay bool       // Whether the source file specified a delay
+	directed bool       // Whether the edge is unidirectional
+}
+
+// graphBuilder collects nodes and edges from a parser and builds a Network
+// External node identifiers are mapped to sequential NodeIDs in order of first appearance
+type graphBuilder struct {
+	config     LoadConfig
+	ids        map[string]p2p.NodeID    // External ID -> internal NodeID
+	names      []string                 // Internal NodeID -> external ID
+	nodeDelays map[p2p.NodeID]p2p.Delay // Node delays specified by the source file
+	edges      []edgeSpec
+}
+
+// newGraphBuilder creates an empty builder for the given configuration
+func newGraphBuilder(config LoadConfig) *graphBuilder {
+	return &graphBuilder{
+		config:     config,
+		ids:        make(map[string]p2p.NodeID),
+		nodeDelays: make(map[p2p.NodeID]p2p.Delay),
+	}
+}
+
+// node returns the internal ID for an external identifier, registering it if necessary
+func (b *graphBuilder) node(name string) p2p.NodeID {
+	if id, ok := b.ids[name]; ok {
+		return id
+	}
+
+	id := p2p.NodeID(len(b.names))
+	b.ids[name] = id
+	b.names = append(b.names, name)
+
+	return id
+}
+
+// setNodeDelay records the processing delay for an external node
+func (b *graphBuilder) setNodeDelay(name string, value string) error {
+	d, err := parseDelay(value)
+	if err != nil {
+		return fmt.Errorf("node %q: %w", name, err)
+	}
+
+	b.nodeDelays[b.node(name)] = d
+
+	return nil
+}
+
+// edge records an edge between two external nodes with an optional delay value
+func (b *graphBuilder) edge(from, to string, delayValue string, directed bool) error {
+	e := edgeSpec{
+		from:     b.node(from),
+		to:       b.node(to),
+		directed: directed,
+	}
+
+	if delayValue != "" {
+		d, err := parseDelay(delayValue)
+		if err != nil {
+			return fmt.Errorf("edge %q -> %q: %w", from, to, err)
+		}
+
+		e.delay = d
+		e.hasDelay = true
+	}
+
+	b.edges = append(b.edges, e)
+
+	return nil
+}
+
+// build materializes the collected nodes and edges into a Network
+// Missing delays are drawn uniformly from the configured ranges, self-loops and repeated edges are ignored
+func (b *graphBuilder) build() (*Network, map[string]p2p.NodeID) {
+	config := NetworkConfig{
+		NodeCount:    len(b.names),
+		MinNodeDelay: b.config.MinNodeDelay,
+		MaxNodeDelay: b.config.MaxNodeDelay,
+		MinLinkDelay: b.config.MinLinkDelay,
+		MaxLinkDelay: b.config.MaxLinkDelay,
+		Seed:         resolveSeed(b.config.Seed),
+	}
+	rng := rand.New(rand.NewSource(config.Seed))
+	nodes := make([]node.Node, len(b.names))
+
+	for i := range nodes {
+		d, ok := b.nodeDelays[p2p.NodeID(i)]
+		if !ok {
+			d = delay(rng, b.config.MinNodeDelay, b.config.MaxNodeDelay)
+		}
+
+		nodes[i] = *node.NewNode(p2p.NodeID(i), d)
+	}
+
+	network := &Network{
+		Nodes:  nodes,
+		Config: config,
+		rng:    rng,
+	}
+
+	for _, e := range b.edges {
+		if e.from == e.to {
+			continue // Self-loops carry no meaning for broadcast
+		}
+
+		link := e.delay
+		if !e.hasDelay {
+			link = delay(rng, b.config.MinLinkDelay, b.config.MaxLinkDelay)
+		}
+
+		if e.directed {
+			network.AddDirectConnection(uint64(e.from), uint64(e.to), link)
+		} else {
+			network.AddBidirectConnection(uint64(e.from), uint64(e.to), link)
+		}
+	}
+
+	return network, b.ids
+}
+
+// parseDelay converts a numeric attribute value into a delay in milliseconds
+// Fractional values are rounded to the nearest millisecond
+func parseDelay(value string) (p2p.Delay, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid delay %q", value)
+	}
+
+	if f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid delay %q", value)
+	}
+
+	return p2p.Delay(math.Round(f)), nil
+}
@@ -0,0 +1,311 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"maps"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// loader is the signature shared by the topology importers
+type loader func(io.Reader, LoadConfig) (*Network, map[string]p2p.NodeID, error)
+
+// loadCase is a document and the topology it should load as, or ok unset if it must be rejected
+type loadCase struct {
+	name   string
+	input  string
+	config LoadConfig
+	arcs   map[string]p2p.Delay // Link delays keyed "from>to" by external IDs
+	nodes  map[string]p2p.Delay // Node delays by external ID (nil skips the check)
+	ok     bool
+}
+
+// arcs returns the link delays of a loaded network keyed "from>to" by external IDs
+func arcs(n *Network, ids map[string]p2p.NodeID) map[string]p2p.Delay {
+	names := make(map[p2p.NodeID]string, len(ids))
+	for name, id := range ids {
+		names[id] = name
+	}
+
+	result := make(map[string]p2p.Delay)
+	for i := range n.Nodes {
+		for conn, d := range n.Nodes[i].Connections() {
+			result[names[n.Nodes[i].ID()]+">"+names[conn.ID()]] = d
+		}
+	}
+
+	return result
+}
+
+// runLoadCases loads each case and compares the links and node delays
+func runLoadCases(t *testing.T, load loader, tests []loadCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Seed = 1
+
+			n, ids, err := load(strings.NewReader(tt.input), tt.config)
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("loaded %d nodes from a malformed document", len(n.Nodes))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := arcs(n, ids); !maps.Equal(got, tt.arcs) {
+				t.Errorf("got links %v, want %v", got, tt.arcs)
+			}
+			for name, want := range tt.nodes {
+				id, ok := ids[name]
+				if !ok {
+					t.Errorf("node %q missing", name)
+					continue
+				}
+				if got := n.Nodes[id].Delay(); got != want {
+					t.Errorf("node %q: got delay %d, want %d", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// both returns the arcs of undirected links in both directions
+func both(links map[string]p2p.Delay) map[string]p2p.Delay {
+	result := make(map[string]p2p.Delay, 2*len(links))
+	for key, d := range links {
+		from, to, _ := strings.Cut(key, ">")
+		result[from+">"+to] = d
+		result[to+">"+from] = d
+	}
+
+	return result
+}
+
+func TestLoadDOT(t *testing.T) {
+	runLoadCases(t, LoadDOT, []loadCase{
+		{name: "undirected", input: `graph g { a -- b [delay=5]; b -- c [delay=7] }`,
+			arcs: both(map[string]p2p.Delay{"a>b": 5, "b>c": 7}), ok: true},
+		{name: "directed", input: `digraph { a -> b [delay=3]; b -> c [delay=4] }`,
+			arcs: map[string]p2p.Delay{"a>b": 3, "b>c": 4}, ok: true},
+		{name: "strict", input: `strict graph { a -- b [delay=1] }`,
+			arcs: both(map[string]p2p.Delay{"a>b": 1}), ok: true},
+		{name: "quoted IDs", input: `graph { "node one" -- "x\"y" [delay="4"]; "graph" -- 12 [delay=2.4] }`,
+			arcs: both(map[string]p2p.Delay{`node one>x"y`: 4, "graph>12": 2}), ok: true},
+		{name: "comments", input: "# 1 \"gen.dot\"\n// header\ngraph { /* a -- c */ a -- b [delay=2]; // b -- c\n}\n",
+			arcs: both(map[string]p2p.Delay{"a>b": 2}), ok: true},
+		{name: "chain", input: `graph { a -- b -- c [delay=1] }`,
+			arcs: both(map[string]p2p.Delay{"a>b": 1, "b>c": 1}), ok: true},
+		{name: "defaults", input: `graph { node [delay=4]; edge [delay=9]; a -- b; c [delay=6]; a -- c [delay=1] }`,
+			arcs:  both(map[string]p2p.Delay{"a>b": 9, "a>c": 1}),
+			nodes: map[string]p2p.Delay{"a": 4, "b": 4, "c": 6}, ok: true},
+		{name: "subgraph", input: `graph { a -- subgraph s { b; c } [delay=1]; { d } -- e [delay=2] }`,
+			arcs: both(map[string]p2p.Delay{"a>b": 1, "a>c": 1, "d>e": 2}), ok: true},
+		{name: "ports", input: `digraph { a:p1:n -> b:sw [delay=1] }`,
+			arcs: map[string]p2p.Delay{"a>b": 1}, ok: true},
+		{name: "duplicate edges", input: `graph { a -- b [delay=1]; b -- a [delay=2]; a -- b [delay=3] }`,
+			arcs: both(map[string]p2p.Delay{"a>b": 1}), ok: true},
+		{name: "self-loop", input: `graph { a -- a [delay=1]; a -- b [delay=2] }`,
+			arcs: both(map[string]p2p.Delay{"a>b": 2}), ok: true},
+		{name: "custom attribute", input: `graph { a [lat=3]; a -- b [w=8, delay=1] }`,
+			config: LoadConfig{NodeDelayAttr: "lat", LinkDelayAttr: "w"},
+			arcs:   both(map[string]p2p.Delay{"a>b": 8}),
+			nodes:  map[string]p2p.Delay{"a": 3}, ok: true},
+		{name: "arrow in graph", input: `graph { a -> b }`},
+		{name: "dashes in digraph", input: `digraph { a -- b }`},
+		{name: "unterminated string", input: `graph { "a -- b }`},
+		{name: "unterminated comment", input: `graph { /* a -- b }`},
+		{name: "missing brace", input: `graph { a -- b`},
+		{name: "no graph keyword", input: `{ a -- b }`},
+		{name: "negative delay", input: `graph { a -- b [delay=-1] }`},
+		{name: "invalid delay", input: `graph { a -- b [delay=fast] }`},
+		{name: "invalid node delay", input: `graph { a [delay=slow] }`},
+	})
+}
+
+func TestLoadEdgeList(t *testing.T) {
+	runLoadCases(t, LoadEdgeList, []loadCase{
+		{name: "csv header", input: "source,target,delay\na,b,5\nb,c,6\n",
+			arcs: both(map[string]p2p.Delay{"a>b": 5, "b>c": 6}), ok: true},
+		{name: "whitespace", input: "a b 5\n\tb\tc\t6\n\n",
+			arcs: both(map[string]p2p.Delay{"a>b": 5, "b>c": 6}), ok: true},
+		{name: "comments", input: "# generated\n% konect\na;b;5\n",
+			arcs: both(map[string]p2p.Delay{"a>b": 5}), ok: true},
+		{name: "quoted IDs", input: `"a","b",5` + "\n'c' 'd' 6\n",
+			arcs: both(map[string]p2p.Delay{"a>b": 5, "c>d": 6}), ok: true},
+		{name: "missing delay", input: "a b\n",
+			arcs: both(map[string]p2p.Delay{"a>b": 0}), ok: true},
+		{name: "duplicate edges", input: "a b 1\nb a 2\na b 3\n",
+			arcs: both(map[string]p2p.Delay{"a>b": 1}), ok: true},
+		{name: "self-loop", input: "a a 1\na b 2\n",
+			arcs: both(map[string]p2p.Delay{"a>b": 2}), ok: true},
+		{name: "directed", input: "a b 1\nb a 2\nb c 3\n", config: LoadConfig{Directed: true},
+			arcs: map[string]p2p.Delay{"a>b": 1, "b>a": 2, "b>c": 3}, ok: true},
+		{name: "one column", input: "a b 1\nc\n"},
+		{name: "invalid delay", input: "a b fast\n"},
+		{name: "negative delay", input: "a b -1\n"},
+	})
+}
+
+func TestLoadGraphML(t *testing.T) {
+	runLoadCases(t, LoadGraphML, []loadCase{
+		{name: "undirected", input: `<graphml>
+  <key id="k0" for="node" attr.name="delay"/>
+  <key id="k1" for="edge" attr.name="delay"/>
+  <key id="k2" for="edge" attr.name="weight"/>
+  <graph edgedefault="undirected">
+    <node id="a"><data key="k0">4</data></node>
+    <node id="b"/>
+    <edge source="a" target="b"><data key="k2">9</data><data key="k1">5</data></edge>
+    <edge source="b" target="c"><data key="k1">6.6</data></edge>
+  </graph>
+</graphml>`,
+			arcs:  both(map[string]p2p.Delay{"a>b": 5, "b>c": 7}),
+			nodes: map[string]p2p.Delay{"a": 4}, ok: true},
+		{name: "directed", input: `<graphml>
+  <key id="delay" for="all"/>
+  <graph edgedefault="directed">
+    <edge source="a" target="b"><data key="delay">1</data></edge>
+    <edge source="b" target="c" directed="false"><data key="delay">2</data></edge>
+  </graph>
+</graphml>`,
+			arcs: map[string]p2p.Delay{"a>b": 1, "b>c": 2, "c>b": 2}, ok: true},
+		{name: "duplicate edges", input: `<graphml>
+  <key id="d" for="edge" attr.name="delay"/>
+  <graph>
+    <edge source="a" target="b"><data key="d">1</data></edge>
+    <edge source="b" target="a"><data key="d">2</data></edge>
+  </graph>
+</graphml>`,
+			arcs: both(map[string]p2p.Delay{"a>b": 1}), ok: true},
+		{name: "first graph only", input: `<graphml>
+  <graph><edge source="a" target="b"/></graph>
+  <graph><edge source="c" target="d"/></graph>
+</graphml>`,
+			arcs: both(map[string]p2p.Delay{"a>b": 0}), ok: true},
+		{name: "not XML", input: `graph { a -- b }`},
+		{name: "unclosed element", input: `<graphml><graph><node id="a">`},
+		{name: "no graph", input: `<graphml><key id="d" for="edge"/></graphml>`},
+		{name: "invalid delay", input: `<graphml>
+  <key id="d" for="edge" attr.name="delay"/>
+  <graph><edge source="a" target="b"><data key="d">fast</data></edge></graph>
+</graphml>`},
+	})
+}
+
+func TestLoadGEXF(t *testing.T) {
+	runLoadCases(t, LoadGEXF, []loadCase{
+		{name: "attributes", input: `<gexf><graph defaultedgetype="undirected">
+  <attributes class="node"><attribute id="0" title="delay" type="integer"/></attributes>
+  <attributes class="edge"><attribute id="1" title="delay" type="integer"/></attributes>
+  <nodes>
+    <node id="a"><attvalues><attvalue for="0" value="4"/></attvalues></node>
+    <node id="b"/>
+  </nodes>
+  <edges>
+    <edge id="0" source="a" target="b" weight="9"><attvalues><attvalue for="1" value="5"/></attvalues></edge>
+  </edges>
+</graph></gexf>`,
+			arcs:  both(map[string]p2p.Delay{"a>b": 5}),
+			nodes: map[string]p2p.Delay{"a": 4}, ok: true},
+		{name: "weight", input: `<gexf><graph>
+  <edges><edge id="0" source="a" target="b" weight="3.4"/></edges>
+</graph></gexf>`,
+			config: LoadConfig{LinkDelayAttr: "weight"},
+			arcs:   both(map[string]p2p.Delay{"a>b": 3}), ok: true},
+		{name: "directed", input: `<gexf><graph defaultedgetype="directed">
+  <edges>
+    <edge id="0" source="a" target="b" weight="1"/>
+    <edge id="1" source="b" target="c" type="undirected" weight="2"/>
+  </edges>
+</graph></gexf>`,
+			config: LoadConfig{LinkDelayAttr: "weight"},
+			arcs:   map[string]p2p.Delay{"a>b": 1, "b>c": 2, "c>b": 2}, ok: true},
+		{name: "duplicate edges", input: `<gexf><graph>
+  <edges>
+    <edge id="0" source="a" target="b" weight="1"/>
+    <edge id="1" source="b" target="a" weight="2"/>
+  </edges>
+</graph></gexf>`,
+			config: LoadConfig{LinkDelayAttr: "weight"},
+			arcs:   both(map[string]p2p.Delay{"a>b": 1}), ok: true},
+		{name: "not XML", input: `a,b,1`},
+		{name: "invalid delay", input: `<gexf><graph>
+  <nodes><node id="a"><attvalues><attvalue for="delay" value="slow"/></attvalues></node></nodes>
+</graph></gexf>`},
+	})
+}
+
+// directedNetwork returns a small network with one-way links, which exporters write as a digraph
+func directedNetwork() *Network {
+	n := GenerateRandomNetwork(NetworkConfig{NodeCount: 20, EdgeCount: 40, MaxLinkDelay: 50, MaxNodeDelay: 100, Seed: 5})
+	n.AddDirectConnection(0, 19, 77)
+	n.Nodes[19].Connections()[&n.Nodes[0]] = 78
+
+	return n
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	networks := map[string]*Network{
+		"limit-degree": GenerateLimitDegreeNetwork(NetworkConfig{NodeCount: 100, D: 8, DLow: 6, DHigh: 10, MinLinkDelay: 5, MaxLinkDelay: 50, MaxNodeDelay: 100, Seed: 2}),
+		"directed":     directedNetwork(),
+	}
+	formats := []struct {
+		name  string
+		write func(*Network, io.Writer) error
+		load  loader
+	}{
+		{"dot", (*Network).WriteDOT, LoadDOT},
+		{"graphml", (*Network).WriteGraphML, LoadGraphML},
+	}
+
+	for name, n := range networks {
+		for _, f := range formats {
+			t.Run(name+"/"+f.name, func(t *testing.T) {
+				var buf bytes.Buffer
+				if err := f.write(n, &buf); err != nil {
+					t.Fatal(err)
+				}
+
+				loaded, ids, err := f.load(&buf, LoadConfig{Seed: 1})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(loaded.Nodes) != len(n.Nodes) {
+					t.Fatalf("got %d nodes, want %d", len(loaded.Nodes), len(n.Nodes))
+				}
+
+				for i := range n.Nodes {
+					id, ok := ids[strconv.Itoa(i)]
+					if !ok {
+						t.Fatalf("node %d missing", i)
+					}
+					if loaded.Nodes[id].Delay() != n.Nodes[i].Delay() {
+						t.Errorf("node %d: got delay %d, want %d", i, loaded.Nodes[id].Delay(), n.Nodes[i].Delay())
+					}
+				}
+
+				// The generator can draw self-links, which the loaders drop
+				want := make(map[string]p2p.Delay)
+				for i := range n.Nodes {
+					for conn, d := range n.Nodes[i].Connections() {
+						if conn == &n.Nodes[i] {
+							continue
+						}
+						want[strconv.Itoa(i)+">"+strconv.Itoa(int(conn.ID()))] = d
+					}
+				}
+				if got := arcs(loaded, ids); !maps.Equal(got, want) {
+					t.Errorf("links differ after the round trip: %d arcs, want %d", len(got), len(want))
+				}
+			})
+		}
+	}
+}
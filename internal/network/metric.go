@@ -0,0 +1,85 @@
+package network
+
+import (
+	"sort"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
+)
+
+// Metric calculates broadcast performance metrics for a message from the nodes' receive records
+func (n *Network) Metric(mid p2p.MessageID, broadcastType p2p.BroadcastType, delay int) p2p.NetworkMetric {
+	origin := n.origin(mid)
+
+	recvCount := 0     // Total number of message receptions (including duplicates)
+	dontRecvCount := 0 // Number of nodes that didn't receive the message
+	bytes := int64(0)  // Wire bytes sent by all nodes
+	for i := range n.Nodes {
+		recvCount += len(n.Nodes[i].ReceiveRoute(mid))
+		bytes += n.Nodes[i].SentBytes(mid)
+
+		// The formula expects the origin among the nodes without receipts, even if a copy came back
+		if len(n.Nodes[i].ReceiveRoute(mid)) == 0 || i == origin {
+			dontRecvCount++
+		}
+	}
+
+	metric := p2p.NewNetworkMetric(len(n.Nodes), broadcastType.String(), delay, n.AvgDegree(), recvCount, dontRecvCount)
+	metric.SetLatencies(n.latencies(mid))
+	metric.SetBytes(bytes)
+
+	if n.adversary != nil {
+		n.setHonest(&metric, mid)
+	}
+
+	return metric
+}
+
+// origin returns the index of the node that relayed a message first, or -1 if none did
+func (n *Network) origin(mid p2p.MessageID) int {
+	origin := -1
+	var first time.Time
+
+	for i := range n.Nodes {
+		if t, ok := n.Nodes[i].RelayTime(mid); ok && (origin < 0 || t.Before(first)) {
+			origin, first = i, t
+		}
+	}
+
+	return origin
+}
+
+// latencies returns the first receipt latency of every reached node relative to the origin, in milliseconds
+// The origin is the node that relayed first, as in Propagation
+func (n *Network) latencies(mid p2p.MessageID) []float64 {
+	times := make([]time.Time, 0, len(n.Nodes))
+	for i := range n.Nodes {
+		if t, ok := n.Nodes[i].RelayTime(mid); ok {
+			times = append(times, t)
+		}
+	}
+
+	if len(times) == 0 {
+		return nil
+	}
+
+	sort.Slice(times, func(a, b int) bool {
+		return times[a].Before(times[b])
+	})
+
+	latencies := make([]float64, 0, len(times)-1)
+	for _, t := range times[1:] {
+		latencies = append(latencies, float64(t.Sub(times[0]))/float64(time.Millisecond))
+	}
+
+	return latencies
+}
+
+// SetRecorder attaches an event recorder to every node in the network
+// Pass nil to stop recording
+func (n *Network) SetRecorder(r trace.Recorder) {
+	for i := range n.Nodes {
+		n.Nodes[i].SetRecorder(r)
+	}
+}
@@ -2,6 +2,7 @@ package network
 
 import (
 	"math/rand"
+	"time"
 
 	"github.com/elecbug/p2p-broadcast-tester/internal/node"
 	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
@@ -9,7 +10,11 @@ import (
 
 // Network represents a P2P network containing multiple nodes
 type Network struct {
-	Nodes []node.Node // List of all nodes in the network
+	Nodes  []node.Node   // List of all nodes in the network
+	Config NetworkConfig // Configuration the network was generated with
+	rng    *rand.Rand    // Random source used for generation
+
+	adversary *p2p.Adversary // Malicious roles of the nodes (nil if all are honest)
 }
 
 // NetworkConfig contains configuration parameters for network generation
@@ -23,24 +28,30 @@ type NetworkConfig struct {
 	D            int       // Target degree for each node (for degree-limited network)
 	DLow         int       // Minimum allowed degree for nodes
 	DHigh        int       // Maximum allowed degree for nodes
+	Seed         int64     // Random seed for generation (0 picks a time-based seed)
 }
 
 // GenerateRandomNetwork creates a network with randomly distributed connections
 func GenerateRandomNetwork(config NetworkConfig) *Network {
+	config.Seed = resolveSeed(config.Seed)
+	rng := rand.New(rand.NewSource(config.Seed))
+
 	// Create nodes with random delays within specified range
 	nodes := make([]node.Node, config.NodeCount)
 
 	for i := 0; i < config.NodeCount; i++ {
-		nodes[i] = *node.NewNode(p2p.NodeID(i), delay(config.MinNodeDelay, config.MaxNodeDelay))
+		nodes[i] = *node.NewNode(p2p.NodeID(i), delay(rng, config.MinNodeDelay, config.MaxNodeDelay))
 	}
 
 	network := &Network{
-		Nodes: nodes,
+		Nodes:  nodes,
+		Config: config,
+		rng:    rng,
 	}
 
 	// Create random connections between nodes
 	for i := 0; i < config.EdgeCount; i++ {
-		if !network.makeRandomConnection(delay(config.MinLinkDelay, config.MaxLinkDelay)) {
+		if !network.makeRandomConnection(delay(rng, config.MinLinkDelay, config.MaxLinkDelay)) {
 			i-- // Retry if connection could not be made
 		}
 	}
@@ -48,18 +59,27 @@ func GenerateRandomNetwork(config NetworkConfig) *Network {
 	return network
 }
 
+// RandomRemovalRounds is the number of degree-limiting rounds that unlink random nodes
+// A random node is rarely a neighbor in a large network, so later rounds unlink random neighbors
+const RandomRemovalRounds = 8
+
 // GenerateLimitDegreeNetwork creates a network where each node's degree is controlled
 // to be within specified bounds (DLow <= degree <= DHigh)
 func GenerateLimitDegreeNetwork(config NetworkConfig) *Network {
+	config.Seed = resolveSeed(config.Seed)
+	rng := rand.New(rand.NewSource(config.Seed))
+
 	// Create nodes with random delays within specified range
 	nodes := make([]node.Node, config.NodeCount)
 
 	for i := 0; i < config.NodeCount; i++ {
-		nodes[i] = *node.NewNode(p2p.NodeID(i), delay(config.MinNodeDelay, config.MaxNodeDelay))
+		nodes[i] = *node.NewNode(p2p.NodeID(i), delay(rng, config.MinNodeDelay, config.MaxNodeDelay))
 	}
 
 	network := &Network{
-		Nodes: nodes,
+		Nodes:  nodes,
+		Config: config,
+		rng:    rng,
 	}
 
 	// Iteratively adjust node degrees to meet constraints
@@ -76,9 +96,9 @@ func GenerateLimitDegreeNetwork(config NetworkConfig) *Network {
 			// Add connections if degree is below minimum threshold
 			if len(network.Nodes[i].Connections()) < config.DLow {
 				for j := 0; j < config.D-len(network.Nodes[i].Connections()); j++ {
-					target := rand.Uint64() % uint64(len(network.Nodes))
+					target := rng.Uint64() % uint64(len(network.Nodes))
 
-					if !network.AddBidirectConnection(uint64(i), target, delay(config.MinLinkDelay, config.MaxLinkDelay)) {
+					if !network.AddBidirectConnection(uint64(i), target, delay(rng, config.MinLinkDelay, config.MaxLinkDelay)) {
 						j-- // Retry if connection could not be made
 						flag = true
 					}
@@ -88,7 +108,12 @@ func GenerateLimitDegreeNetwork(config NetworkConfig) *Network {
 			// Remove connections if degree is above maximum threshold
 			if len(network.Nodes[i].Connections()) > config.DHigh {
 				for j := 0; j < len(network.Nodes[i].Connections())-config.D; j++ {
-					target := rand.Uint64() % uint64(len(network.Nodes))
+					var target uint64
+					if re < RandomRemovalRounds {
+						target = rng.Uint64() % uint64(len(network.Nodes))
+					} else {
+						target = network.neighbor(i, rng.Uint64()%uint64(len(network.Nodes[i].Connections())))
+					}
 
 					network.RemoveConnection(uint64(i), target)
 					flag = true
@@ -103,3 +128,31 @@ func GenerateLimitDegreeNetwork(config NetworkConfig) *Network {
 
 	return network
 }
+
+// resolveSeed returns the given seed, or a time-based seed if it is zero
+// The resolved seed is stored in the network config so the run can be reproduced
+func resolveSeed(seed int64) int64 {
+	if seed != 0 {
+		return seed
+	}
+
+	return time.Now().UnixNano()
+}
+
+// random returns the network's random source, creating one if the network was not generated
+func (n *Network) random() *rand.Rand {
+	if n.rng == nil {
+		n.Config.Seed = resolveSeed(n.Config.Seed)
+		n.rng = rand.New(rand.NewSource(n.Config.Seed))
+	}
+
+	return n.rng
+}
+
+// ResetEpoch makes every node draw new Dandelion choices on its next stem message,
+// so a broadcast on a reused network does not inherit those of an earlier one
+func (n *Network) ResetEpoch() {
+	for i := range n.Nodes {
+		n.Nodes[i].ResetEpoch()
+	}
+}
@@ -0,0 +1,30 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+func TestGenerateRandomNetworkLinks(t *testing.T) {
+	ranges := [][2]p2p.Delay{{0, 0}, {0, 1}, {5, 50}}
+
+	for _, r := range ranges {
+		for _, seed := range []int64{1, 2, 3} {
+			n := GenerateRandomNetwork(NetworkConfig{NodeCount: 50, EdgeCount: 100, MinLinkDelay: r[0], MaxLinkDelay: r[1], MaxNodeDelay: 100, Seed: seed})
+
+			links := 0
+			for i := range n.Nodes {
+				for _, d := range n.Nodes[i].Connections() {
+					if d < r[0] || d > r[1] {
+						t.Errorf("link delays %v, seed %d: delay %d out of range", r, seed, d)
+					}
+					links++
+				}
+			}
+			if links != 200 {
+				t.Errorf("link delays %v, seed %d: %d arcs, want 200", r, seed, links)
+			}
+		}
+	}
+}
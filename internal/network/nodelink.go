@@ -0,0 +1,85 @@
+package network
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// nodeLinkGraph mirrors the node-link JSON format used by networkx and d3
+type nodeLinkGraph struct {
+	Directed   bool           `json:"directed"`
+	Multigraph bool           `json:"multigraph"`
+	Graph      map[string]any `json:"graph"`
+	Nodes      []nodeLinkNode `json:"nodes"`
+	Links      []nodeLinkLink `json:"links"`
+}
+
+// nodeLinkNode is a single node entry with optional attributes
+type nodeLinkNode struct {
+	ID     p2p.NodeID `json:"id"`
+	Delay  *p2p.Delay `json:"delay,omitempty"`
+	Time   *float64   `json:"time,omitempty"`
+	Origin bool       `json:"origin,omitempty"`
+}
+
+// nodeLinkLink is a single link entry with optional attributes
+type nodeLinkLink struct {
+	Source p2p.NodeID `json:"source"`
+	Target p2p.NodeID `json:"target"`
+	Delay  *p2p.Delay `json:"delay,omitempty"`
+	Time   *float64   `json:"time,omitempty"`
+	First  *bool      `json:"first,omitempty"`
+}
+
+// WriteNodeLinkJSON writes the network topology in node-link JSON format
+func (n *Network) WriteNodeLinkJSON(w io.Writer) error {
+	edges, directed := n.exportEdges()
+
+	graph := nodeLinkGraph{
+		Directed: directed,
+		Graph:    map[string]any{"name": "network"},
+		Nodes:    make([]nodeLinkNode, 0, len(n.Nodes)),
+		Links:    make([]nodeLinkLink, 0, len(edges)),
+	}
+
+	for i := range n.Nodes {
+		d := n.Nodes[i].Delay()
+		graph.Nodes = append(graph.Nodes, nodeLinkNode{ID: n.Nodes[i].ID(), Delay: &d})
+	}
+
+	for _, e := range edges {
+		d := e.delay
+		graph.Links = append(graph.Links, nodeLinkLink{Source: e.from, Target: e.to, Delay: &d})
+	}
+
+	return json.NewEncoder(w).Encode(graph)
+}
+
+// WritePropagationNodeLinkJSON writes the propagation of a message in node-link JSON format
+// Times are milliseconds since the broadcast started. Duplicate links are only written when
+// duplicates is set and are marked with first=false
+func (p *Propagation) WritePropagationNodeLinkJSON(w io.Writer, duplicates bool) error {
+	edges := p.filteredEdges(duplicates)
+
+	graph := nodeLinkGraph{
+		Directed: true,
+		Graph:    map[string]any{"name": "propagation", "message": p.Message, "origin": p.Origin},
+		Nodes:    make([]nodeLinkNode, 0, len(p.Received)),
+		Links:    make([]nodeLinkLink, 0, len(edges)),
+	}
+
+	for _, id := range p.sortedNodeIDs() {
+		t := millis(p.Received[id])
+		graph.Nodes = append(graph.Nodes, nodeLinkNode{ID: id, Time: &t, Origin: id == p.Origin})
+	}
+
+	for _, e := range edges {
+		t := millis(e.Time)
+		first := e.First
+		graph.Links = append(graph.Links, nodeLinkLink{Source: e.From, Target: e.To, Time: &t, First: &first})
+	}
+
+	return json.NewEncoder(w).Encode(graph)
+}
@@ -0,0 +1,169 @@
+package network
+
+import (
+	"sort"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/node"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// PropagationEdge is a single transmission of a message between two nodes
+type PropagationEdge struct {
+	From  p2p.NodeID    // Sending node
+	To    p2p.NodeID    // Receiving node
+	First bool          // Whether this transmission was the receiver's first receipt (tree edge)
+	Time  time.Duration // Receipt time relative to the origin's broadcast start
+}
+
+// Propagation describes how a single message spread through the network
+// First-receipt edges form the propagation tree, the remaining edges are duplicates
+type Propagation struct {
+	Message  p2p.MessageID                // Propagated message
+	Origin   p2p.NodeID                   // Node that started the broadcast
+	Received map[p2p.NodeID]time.Duration // First receipt time of every reached node
+	Edges    []PropagationEdge            // All transmissions ordered by receipt time
+}
+
+// Propagation collects the propagation DAG of a message from the nodes' receive records
+// Returns nil if no node has seen the message
+func (n *Network) Propagation(mid p2p.MessageID) *Propagation {
+	// The origin is the node with the earliest relay time
+	var start time.Time
+	origin := -1
+
+	for i := range n.Nodes {
+		if t, ok := n.Nodes[i].RelayTime(mid); ok && (origin < 0 || t.Before(start)) {
+			start = t
+			origin = i
+		}
+	}
+
+	if origin < 0 {
+		return nil
+	}
+
+	p := &Propagation{
+		Message:  mid,
+		Origin:   n.Nodes[origin].ID(),
+		Received: make(map[p2p.NodeID]time.Duration),
+	}
+
+	for i := range n.Nodes {
+		nd := &n.Nodes[i]
+
+		t, ok := nd.RelayTime(mid)
+		if !ok {
+			continue
+		}
+		p.Received[nd.ID()] = t.Sub(start)
+
+		route := nd.ReceiveRoute(mid)
+		times := nd.ReceiveTimes(mid)
+
+		for j, from := range route {
+			edge := PropagationEdge{
+				From:  from,
+				To:    nd.ID(),
+				First: j == 0 && i != origin, // The origin only ever receives duplicates
+			}
+
+			if j < len(times) {
+				edge.Time = times[j].Sub(start)
+			}
+
+			p.Edges = append(p.Edges, edge)
+		}
+	}
+
+	sort.SliceStable(p.Edges, func(a, b int) bool {
+		return p.Edges[a].Time < p.Edges[b].Time
+	})
+
+	return p
+}
+
+// sortedNodeIDs returns the IDs of all reached nodes in ascending order
+func (p *Propagation) sortedNodeIDs() []p2p.NodeID {
+	ids := make([]p2p.NodeID, 0, len(p.Received))
+	for id := range p.Received {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(a, b int) bool {
+		return ids[a] < ids[b]
+	})
+
+	return ids
+}
+
+// filteredEdges returns the tree edges, plus duplicate edges when requested
+func (p *Propagation) filteredEdges(duplicates bool) []PropagationEdge {
+	edges := make([]PropagationEdge, 0, len(p.Edges))
+
+	for _, e := range p.Edges {
+		if e.First || duplicates {
+			edges = append(edges, e)
+		}
+	}
+
+	return edges
+}
+
+// exportEdge is a single topology edge prepared for export
+type exportEdge struct {
+	from  p2p.NodeID
+	to    p2p.NodeID
+	delay p2p.Delay
+}
+
+// exportEdges lists all connections in deterministic order
+// If every connection is symmetric with equal delay, the network is reported as undirected
+// and each link is listed once
+func (n *Network) exportEdges() ([]exportEdge, bool) {
+	directed := false
+
+	for i := range n.Nodes {
+		for conn, d := range n.Nodes[i].Connections() {
+			back, ok := conn.Connections()[&n.Nodes[i]]
+			if !ok || back != d {
+				directed = true
+			}
+		}
+	}
+
+	var edges []exportEdge
+
+	for i := range n.Nodes {
+		nd := &n.Nodes[i]
+
+		for _, conn := range sortedConnections(nd) {
+			if !directed && conn.ID() < nd.ID() {
+				continue // Undirected links are listed once
+			}
+
+			edges = append(edges, exportEdge{from: nd.ID(), to: conn.ID(), delay: nd.Connections()[conn]})
+		}
+	}
+
+	return edges, directed
+}
+
+// sortedConnections returns the connected nodes of a node ordered by ID
+func sortedConnections(nd *node.Node) []*node.Node {
+	conns := make([]*node.Node, 0, len(nd.Connections()))
+	for conn := range nd.Connections() {
+		conns = append(conns, conn)
+	}
+
+	sort.Slice(conns, func(a, b int) bool {
+		return conns[a].ID() < conns[b].ID()
+	})
+
+	return conns
+}
+
+// millis converts a duration to fractional milliseconds for export
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
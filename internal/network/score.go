@@ -0,0 +1,88 @@
+package network
+
+import (
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/score"
+)
+
+// SetScores makes every node act on the peer scores of a table indexed by node IDs (nil disables scoring)
+func (n *Network) SetScores(t *score.Table) {
+	for i := range n.Nodes {
+		n.Nodes[i].SetScores(t)
+	}
+}
+
+// ObserveScores feeds what every node saw from its peers during a message to their scores and updates them
+// The message must have settled, since statuses change while the nodes read them
+func (n *Network) ObserveScores(mid p2p.MessageID, bt p2p.BroadcastType, t *score.Table) {
+	origin := n.origin(mid)
+
+	for i := range n.Nodes {
+		node := &n.Nodes[i]
+		if i == origin {
+			continue // The origin published the message and expects nothing from its peers
+		}
+
+		route := node.ReceiveRoute(mid)
+		times := node.ReceiveTimes(mid)
+
+		copies := make(map[p2p.NodeID]int, len(route))
+		arrival := make(map[p2p.NodeID]time.Time, len(route))
+		for k, id := range route {
+			if copies[id] == 0 {
+				arrival[id] = times[k]
+			}
+			copies[id]++
+		}
+
+		invalid := make(map[p2p.NodeID]int)
+		for _, id := range node.InvalidRoute(mid) {
+			invalid[id]++
+		}
+
+		for conn := range node.Connections() {
+			arc := t.Arc(int32(i), int32(conn.ID()))
+			if arc < 0 {
+				continue
+			}
+
+			d := score.Delivery{
+				Received: len(route) > 0,
+				Expected: len(route) > 0 && n.expects(mid, bt, t, i, int(conn.ID()), times[0]),
+				Copies:   copies[conn.ID()],
+				First:    len(route) > 0 && route[0] == conn.ID(),
+				Invalid:  invalid[conn.ID()],
+			}
+			if d.Copies > 0 {
+				d.Lag = uint64(arrival[conn.ID()].Sub(times[0]) / time.Millisecond)
+			}
+
+			t.Observe(arc, d)
+		}
+	}
+
+	t.Update()
+}
+
+// expects reports whether the protocol had peer j deliver node i, which received the message at
+// received, a copy within the delivery window, as sim.Result does for simulated broadcasts
+func (n *Network) expects(mid p2p.MessageID, bt p2p.BroadcastType, t *score.Table, i, j int, received time.Time) bool {
+	peer := &n.Nodes[j]
+	relayed, ok := peer.RelayTime(mid)
+	if !bt.Floods() || !ok || t.Status(int32(j), int32(i)) >= score.Pruned {
+		return false
+	}
+
+	forward := relayed.Add(time.Duration(peer.Delay()) * time.Millisecond)
+	times := peer.ReceiveTimes(mid)
+	for k, id := range peer.ReceiveRoute(mid) {
+		if int(id) == i && !times[k].After(forward) {
+			return false
+		}
+	}
+
+	link := time.Duration(peer.Connections()[&n.Nodes[i]]) * time.Millisecond
+	return forward.Add(link).Sub(received) <= time.Duration(t.Params.DeliveryWindow)*time.Millisecond
+}
@@ -0,0 +1,231 @@
+package network
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/node"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// Snapshot file layout (gzip-compressed):
+//
+//	magic    [4]byte  "NWSN"
+//	version  uvarint  snapshotVersion
+//	config   uvarint length + JSON-encoded NetworkConfig
+//	nodes    uvarint  node count
+//	per node uvarint  node ID, processing delay, connection count,
+//	         then per connection: delta-encoded target index and link delay
+//
+// Connection targets are stored as node indices in ascending order, so the
+// pointer structure of the network is restored exactly on load.
+const (
+	snapshotMagic   = "NWSN"
+	snapshotVersion = 1
+)
+
+// Bounds on the sizes a snapshot declares, so a corrupt one cannot exhaust memory
+const (
+	maxSnapshotConfig = 64 << 10 // Bytes of the JSON-encoded config
+	maxSnapshotNodes  = 1 << 28  // Nodes, which are also allocated as they are read rather than upfront
+)
+
+// ErrSnapshotFormat is returned when a snapshot has an unknown magic or version, or is corrupt
+var ErrSnapshotFormat = errors.New("invalid network snapshot")
+
+// SaveSnapshot writes the network to a snapshot file at the given path
+func (n *Network) SaveSnapshot(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := n.WriteSnapshot(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// LoadSnapshot reads a network from a snapshot file at the given path
+func LoadSnapshot(path string) (*Network, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadSnapshot(file)
+}
+
+// WriteSnapshot serializes the network's nodes, delays, connections and config
+func (n *Network) WriteSnapshot(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	putUvarint := func(v uint64) {
+		bw.Write(buf[:binary.PutUvarint(buf, v)])
+	}
+
+	// Map node pointers back to indices
+	index := make(map[*node.Node]uint64, len(n.Nodes))
+	for i := range n.Nodes {
+		index[&n.Nodes[i]] = uint64(i)
+	}
+
+	config, err := json.Marshal(n.Config)
+	if err != nil {
+		return err
+	}
+
+	bw.WriteString(snapshotMagic)
+	putUvarint(snapshotVersion)
+	putUvarint(uint64(len(config)))
+	bw.Write(config)
+	putUvarint(uint64(len(n.Nodes)))
+
+	for i := range n.Nodes {
+		nd := &n.Nodes[i]
+
+		// Sort targets so they can be delta-encoded
+		targets := make([]uint64, 0, len(nd.Connections()))
+		delays := make(map[uint64]p2p.Delay, len(nd.Connections()))
+		for conn, d := range nd.Connections() {
+			idx, ok := index[conn]
+			if !ok {
+				return fmt.Errorf("node %d is connected to a node outside the network", nd.ID())
+			}
+
+			targets = append(targets, idx)
+			delays[idx] = d
+		}
+		sort.Slice(targets, func(a, b int) bool {
+			return targets[a] < targets[b]
+		})
+
+		putUvarint(uint64(nd.ID()))
+		putUvarint(uint64(nd.Delay()))
+		putUvarint(uint64(len(targets)))
+
+		prev := uint64(0)
+		for _, target := range targets {
+			putUvarint(target - prev)
+			putUvarint(uint64(delays[target]))
+			prev = target
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// ReadSnapshot deserializes a network written by WriteSnapshot
+func ReadSnapshot(r io.Reader) (*Network, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotFormat, err)
+	}
+	defer zr.Close()
+
+	br := bufio.NewReader(zr)
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != snapshotMagic {
+		return nil, ErrSnapshotFormat
+	}
+
+	version, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrSnapshotFormat, version)
+	}
+
+	configLen, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+
+	if configLen > maxSnapshotConfig {
+		return nil, fmt.Errorf("%w: config of %d bytes", ErrSnapshotFormat, configLen)
+	}
+
+	configData := make([]byte, configLen)
+	if _, err := io.ReadFull(br, configData); err != nil {
+		return nil, err
+	}
+
+	network := &Network{}
+	if err := json.Unmarshal(configData, &network.Config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotFormat, err)
+	}
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxSnapshotNodes {
+		return nil, fmt.Errorf("%w: %d nodes", ErrSnapshotFormat, count)
+	}
+
+	// Read all nodes before connecting them, since connections refer to node addresses
+	type arc struct {
+		from, to uint64
+		delay    p2p.Delay
+	}
+	var arcs []arc
+
+	// A truncated stream ends the loop before a declared count far beyond its size is allocated
+	network.Nodes = make([]node.Node, 0, min(count, 1<<16))
+
+	for i := uint64(0); i < count; i++ {
+		var fields [3]uint64
+		for f := range fields {
+			if fields[f], err = binary.ReadUvarint(br); err != nil {
+				return nil, err
+			}
+		}
+
+		network.Nodes = slices.Grow(network.Nodes, 1)[:i+1]
+		network.Nodes[i] = *node.NewNode(p2p.NodeID(fields[0]), p2p.Delay(fields[1]))
+
+		target := uint64(0)
+		for c := uint64(0); c < fields[2]; c++ {
+			delta, err := binary.ReadUvarint(br)
+			if err != nil {
+				return nil, err
+			}
+			d, err := binary.ReadUvarint(br)
+			if err != nil {
+				return nil, err
+			}
+
+			target += delta
+			if target >= count {
+				return nil, fmt.Errorf("%w: connection target %d out of range", ErrSnapshotFormat, target)
+			}
+
+			arcs = append(arcs, arc{from: i, to: target, delay: p2p.Delay(d)})
+		}
+	}
+
+	for _, a := range arcs {
+		network.AddDirectConnection(a.from, a.to, a.delay)
+	}
+
+	return network, nil
+}
@@ -0,0 +1,75 @@
+package network
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// gzipped compresses a raw snapshot body
+func gzipped(t *testing.T, body []byte) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(body)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	n := GenerateLimitDegreeNetwork(NetworkConfig{NodeCount: 200, D: 8, DLow: 6, DHigh: 12, MaxLinkDelay: 50, MaxNodeDelay: 100, Seed: 3})
+
+	var buf bytes.Buffer
+	if err := n.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Config != n.Config || len(loaded.Nodes) != len(n.Nodes) {
+		t.Fatalf("loaded %d nodes with %+v, want %d with %+v", len(loaded.Nodes), loaded.Config, len(n.Nodes), n.Config)
+	}
+	for i := range n.Nodes {
+		want, got := &n.Nodes[i], &loaded.Nodes[i]
+		if got.ID() != want.ID() || got.Delay() != want.Delay() || len(got.Connections()) != len(want.Connections()) {
+			t.Fatalf("node %d differs", i)
+		}
+		for conn, d := range want.Connections() {
+			if got.Connections()[&loaded.Nodes[conn.ID()]] != d {
+				t.Fatalf("link %d-%d differs", i, conn.ID())
+			}
+		}
+	}
+}
+
+func TestSnapshotCorruptSizes(t *testing.T) {
+	header := append([]byte(snapshotMagic), snapshotVersion)
+	config := []byte("{}")
+
+	tests := map[string][]byte{
+		"config length": binary.AppendUvarint(bytes.Clone(header), 1<<62),
+		"node count":    binary.AppendUvarint(append(binary.AppendUvarint(bytes.Clone(header), uint64(len(config))), config...), 1<<62),
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ReadSnapshot(gzipped(t, body)); !errors.Is(err, ErrSnapshotFormat) {
+				t.Errorf("got %v, want %v", err, ErrSnapshotFormat)
+			}
+		})
+	}
+
+	// A count within the bound but beyond the stream fails on the missing nodes
+	body := binary.AppendUvarint(append(binary.AppendUvarint(bytes.Clone(header), uint64(len(config))), config...), maxSnapshotNodes)
+	if _, err := ReadSnapshot(gzipped(t, body)); err == nil {
+		t.Error("truncated snapshot loaded")
+	}
+}
@@ -6,95 +6,180 @@ import (
 	"time"
 
 	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/score"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
+	"github.com/elecbug/p2p-broadcast-tester/internal/wire"
 )
 
+// Message is a broadcast message in flight between two nodes
+type Message struct {
+	ID      p2p.MessageID     // Identifier of the broadcast
+	Type    p2p.BroadcastType // Broadcast algorithm and its parameters
+	Hop     int               // Hop counter (0 for the origin's transmissions)
+	Origin  p2p.NodeID        // Node that published the message
+	Stem    bool              // Dandelion stem phase
+	Payload []byte            // Application data, shared by every copy
+}
+
 // Broadcast initiates a message broadcast using the specified broadcast type
+// Every transmission runs in its own goroutine tracked by wg
 func (n *Node) Broadcast(messageID p2p.MessageID, broadcastType p2p.BroadcastType, wg *sync.WaitGroup) {
 	defer wg.Done()
 	wg.Add(1)
 
-	switch broadcastType.Type {
-	case p2p.BasicPublish:
-		// Basic flooding-based broadcast: send to all connected nodes
+	n.BroadcastWith(&GoroutineExecutor{WG: wg}, messageID, broadcastType)
+}
+
+// BroadcastWith initiates a message broadcast, scheduling all delayed steps on the given executor
+func (n *Node) BroadcastWith(exec Executor, messageID p2p.MessageID, broadcastType p2p.BroadcastType) {
+	n.PublishWith(exec, Message{ID: messageID, Type: broadcastType})
+}
+
+// PublishWith broadcasts a message from this node, carrying its payload to every receiver
+// The hop, origin and stem fields are set here
+func (n *Node) PublishWith(exec Executor, msg Message) {
+	messageID := msg.ID
+
+	switch msg.Type.Type {
+	case p2p.BasicPublish, p2p.WavePublish, p2p.Dandelion:
 		n.mu.Lock()
 
 		n.relayMap[messageID] = time.Now()
 		n.receiveMap[messageID] = []p2p.NodeID{} // Reset duplicates for this relay
+		n.receiveTime[messageID] = []time.Time{}
 
 		n.mu.Unlock()
 
-		// Simulate node processing delay
-		time.Sleep(time.Duration(n.delay) * time.Millisecond)
-
-		// Send message to all connected nodes
-		for conn, delay := range n.connections {
-			if n.checkReceiving(messageID, conn) {
-				continue
-			}
+		n.record(trace.Publish, messageID, n.id, n.id, 0)
 
-			wg.Add(1)
+		msg.Hop, msg.Origin, msg.Stem = 0, n.id, false
 
-			go func(conn *Node, delay p2p.Delay) {
-				defer wg.Done()
+		if msg.Type.Type == p2p.Dandelion {
+			msg.Stem = true
+			exec.Process(n, n.delay, func() {
+				n.stem(exec, nil, msg)
+			})
+			return
+		}
 
-				// Simulate network transmission delay
-				time.Sleep(time.Duration(delay) * time.Millisecond)
+		// Simulate node processing delay, then send to all connected nodes
+		exec.Process(n, n.delay, func() {
+			for conn, delay := range n.connections {
+				if n.checkReceiving(messageID, conn) || n.peerStatus(conn) >= score.Pruned {
+					continue
+				}
 
-				conn.relayBasic(messageID, n, wg)
-			}(conn, delay)
-		}
-	case p2p.WavePublish:
-		// Wave-based broadcast with controlled propagation using level parameter
-		coef := float64(broadcastType.Level) / 100.0 // Convert level to coefficient (0.0 to 1.0)
+				n.send(exec, conn, delay, msg)
+			}
+		})
+	}
+}
 
+// Receive handles a message arriving from a connected node
+// Executors call this once the link delay of a transmission has elapsed
+func (n *Node) Receive(exec Executor, from *Node, msg Message) {
+	// Messages from graylisted peers are ignored and invalid copies discarded
+	if n.peerStatus(from) == score.Graylisted {
+		n.record(trace.Drop, msg.ID, from.id, n.id, msg.Hop)
+		return
+	}
+	if from.role == p2p.Forger {
 		n.mu.Lock()
+		n.invalidMap[msg.ID] = append(n.invalidMap[msg.ID], from.id)
+		n.mu.Unlock()
 
-		n.relayMap[messageID] = time.Now()
-		n.receiveMap[messageID] = []p2p.NodeID{} // Reset duplicates for this relay
+		n.record(trace.Drop, msg.ID, from.id, n.id, msg.Hop)
+		return
+	}
 
+	n.mu.Lock()
+
+	// Check if message has already been processed by this node
+	if _, ok := n.relayMap[msg.ID]; ok {
+		n.receiveMap[msg.ID] = append(n.receiveMap[msg.ID], from.id) // Track duplicate sender
+		n.receiveTime[msg.ID] = append(n.receiveTime[msg.ID], time.Now())
 		n.mu.Unlock()
 
-		// Simulate node processing delay
-		time.Sleep(time.Duration(n.delay) * time.Millisecond)
+		n.record(trace.Duplicate, msg.ID, from.id, n.id, msg.Hop)
 
-		// Send message to all connected nodes with wave propagation
-		for conn, delay := range n.connections {
-			if n.checkReceiving(messageID, conn) {
-				continue
-			}
+		// A Dandelion stem node diffuses the message once it sees it again
+		if msg.Type.Type == p2p.Dandelion {
+			n.fluff(exec, from, msg, true)
+		}
+		return
+	}
 
-			wg.Add(1)
+	// First time receiving this message
+	now := time.Now()
+	n.relayMap[msg.ID] = now
+	n.receiveMap[msg.ID] = []p2p.NodeID{from.id} // Reset duplicates for this relay
+	n.receiveTime[msg.ID] = []time.Time{now}
+	if msg.Type.Type == p2p.Dandelion && !msg.Stem {
+		n.fluffed[msg.ID] = true
+	}
+	n.mu.Unlock()
 
-			go func(conn *Node, delay p2p.Delay) {
-				defer wg.Done()
+	n.record(trace.Receive, msg.ID, from.id, n.id, msg.Hop)
 
-				// Simulate network transmission delay
-				time.Sleep(time.Duration(delay) * time.Millisecond)
+	delay, ok := n.forwardDelay(msg)
+	if !ok {
+		n.record(trace.Drop, msg.ID, from.id, n.id, msg.Hop)
+		return
+	}
 
-				conn.relayWave(messageID, n, 0, coef, wg)
-			}(conn, delay)
+	if n.role == p2p.Spammer {
+		exec.Process(n, delay, func() {
+			n.relaySpam(exec, msg)
+		})
+		return
+	}
+
+	// Simulate node processing delay, then relay according to the broadcast type
+	exec.Process(n, delay, func() {
+		switch msg.Type.Type {
+		case p2p.BasicPublish:
+			n.relayBasic(exec, from, msg)
+		case p2p.WavePublish:
+			n.relayWave(exec, from, msg)
+		case p2p.Dandelion:
+			n.relayDandelion(exec, from, msg)
 		}
+	})
+}
+
+// forwardDelay returns how long the node holds a message before relaying it,
+// or false if its role never relays the message
+func (n *Node) forwardDelay(msg Message) (p2p.Delay, bool) {
+	switch n.role {
+	case p2p.Dropper, p2p.Sybil:
+		return 0, false
+	case p2p.Censor:
+		if n.adversary.Censored[msg.Origin] {
+			return 0, false
+		}
+	case p2p.Delayer:
+		return n.delay + n.adversary.Hold, true // Hold the message before relaying it
 	}
+
+	return n.delay, true
 }
 
-// relayBasic handles message relay using basic flooding algorithm
-func (n *Node) relayBasic(messageID p2p.MessageID, from *Node, wg *sync.WaitGroup) {
+// send hands a message to the executor for transmission to a connected node
+func (n *Node) send(exec Executor, conn *Node, delay p2p.Delay, msg Message) {
+	n.record(trace.Send, msg.ID, n.id, conn.id, msg.Hop)
+
+	size := int64(wire.FrameSize(msg.Envelope(n.id)))
 	n.mu.Lock()
+	n.sentBytes[msg.ID] += size
+	n.mu.Unlock()
 
-	// Check if message has already been processed by this node
-	if _, ok := n.relayMap[messageID]; ok {
-		n.receiveMap[messageID] = append(n.receiveMap[messageID], from.id) // Track duplicate sender
-		n.mu.Unlock()
-		return
-	} else {
-		// First time receiving this message
-		n.relayMap[messageID] = time.Now()
-		n.receiveMap[messageID] = []p2p.NodeID{from.id} // Reset duplicates for this relay
-		n.mu.Unlock()
-	}
+	exec.Transmit(n, conn, delay, msg)
+}
 
-	// Simulate node processing delay
-	time.Sleep(time.Duration(n.delay) * time.Millisecond)
+// relayBasic handles message relay using basic flooding algorithm
+func (n *Node) relayBasic(exec Executor, from *Node, msg Message) {
+	next := msg
+	next.Hop++
 
 	// Forward message to all connected nodes except the sender
 	for conn, delay := range n.connections {
@@ -102,63 +187,32 @@ func (n *Node) relayBasic(messageID p2p.MessageID, from *Node, wg *sync.WaitGrou
 			continue // Skip excluded node
 		}
 
-		if n.checkReceiving(messageID, conn) {
+		if n.checkReceiving(msg.ID, conn) || n.peerStatus(conn) >= score.Pruned {
 			continue
 		}
 
-		wg.Add(1)
-
-		go func(conn *Node, delay p2p.Delay) {
-			defer wg.Done()
-
-			// Simulate network transmission delay
-			time.Sleep(time.Duration(delay) * time.Millisecond)
-
-			conn.relayBasic(messageID, n, wg)
-		}(conn, delay)
+		n.send(exec, conn, delay, next)
 	}
 }
 
 // relayWave handles message relay using wave-based algorithm with hop-based selective forwarding
-func (n *Node) relayWave(messageID p2p.MessageID, from *Node, hop int, coef float64, wg *sync.WaitGroup) {
-	n.mu.Lock()
-
-	// Check if message has already been processed by this node
-	if _, ok := n.relayMap[messageID]; ok {
-		n.receiveMap[messageID] = append(n.receiveMap[messageID], from.id) // Track duplicate sender
-		n.mu.Unlock()
-		return
-	} else {
-		// First time receiving this message
-		n.relayMap[messageID] = time.Now()
-		n.receiveMap[messageID] = []p2p.NodeID{from.id} // Reset duplicates for this relay
-		n.mu.Unlock()
-	}
-
-	// Simulate node processing delay
-	time.Sleep(time.Duration(n.delay) * time.Millisecond)
+func (n *Node) relayWave(exec Executor, from *Node, msg Message) {
+	coef := float64(msg.Type.Level) / 100.0 // Convert level to coefficient (0.0 to 1.0)
+	next := msg
+	next.Hop++
 
-	if hop%2 == 0 {
+	if msg.Hop%2 == 0 {
 		// Even hop: forward to all connected nodes (full propagation)
 		for conn, delay := range n.connections {
 			if conn == from {
 				continue // Skip excluded node
 			}
 
-			if n.checkReceiving(messageID, conn) {
+			if n.checkReceiving(msg.ID, conn) || n.peerStatus(conn) >= score.Pruned {
 				continue
 			}
 
-			wg.Add(1)
-
-			go func(conn *Node, delay p2p.Delay) {
-				defer wg.Done()
-
-				// Simulate network transmission delay
-				time.Sleep(time.Duration(delay) * time.Millisecond)
-
-				conn.relayWave(messageID, n, hop+1, coef, wg)
-			}(conn, delay)
+			n.send(exec, conn, delay, next)
 		}
 	} else {
 		// Odd hop: forward to limited number of nodes based on coefficient
@@ -169,12 +223,30 @@ func (n *Node) relayWave(messageID p2p.MessageID, from *Node, hop int, coef floa
 		// Calculate maximum number of nodes to send to (at least 1)
 		maxSend := max(int(coef*float64(len(n.connections))), 1)
 
-		// Create a copy of connections to modify during iteration
+		// Create a copy of connections to modify during iteration, without pruned peers
 		copiedConnections := make(map[*Node]p2p.Delay, len(n.connections))
+		preferred := 0
 		for conn, delay := range n.connections {
+			switch n.peerStatus(conn) {
+			case score.Normal:
+				if conn != from && !n.checkReceiving(msg.ID, conn) {
+					preferred++
+				}
+			case score.Pruned, score.Graylisted:
+				continue
+			}
 			copiedConnections[conn] = delay
 		}
 
+		// Deprioritized peers are only picked if there are not enough others
+		if preferred >= maxSend {
+			for conn := range copiedConnections {
+				if n.peerStatus(conn) == score.Deprioritized {
+					delete(copiedConnections, conn)
+				}
+			}
+		}
+
 		// Randomly select and send to maxSend number of nodes
 		for send < maxSend {
 			flag := false
@@ -184,7 +256,7 @@ func (n *Node) relayWave(messageID p2p.MessageID, from *Node, hop int, coef floa
 					continue // Skip excluded node
 				}
 
-				if n.checkReceiving(messageID, conn) {
+				if n.checkReceiving(msg.ID, conn) {
 					continue
 				}
 
@@ -192,16 +264,7 @@ func (n *Node) relayWave(messageID p2p.MessageID, from *Node, hop int, coef floa
 
 				// Send to randomly selected node
 				if i == randN {
-					wg.Add(1)
-
-					go func(conn *Node, delay p2p.Delay) {
-						defer wg.Done()
-
-						// Simulate network transmission delay
-						time.Sleep(time.Duration(delay) * time.Millisecond)
-
-						conn.relayWave(messageID, n, hop+1, coef, wg)
-					}(conn, delay)
+					n.send(exec, conn, delay, next)
 
 					// Remove selected node from available connections
 					delete(copiedConnections, conn)
@@ -222,6 +285,19 @@ func (n *Node) relayWave(messageID p2p.MessageID, from *Node, hop int, coef floa
 	}
 }
 
+// relaySpam floods several copies of a message to every connected node,
+// including the sender and nodes known to have it, to inflate duplicates
+func (n *Node) relaySpam(exec Executor, msg Message) {
+	next := msg
+	next.Hop++
+
+	for conn, delay := range n.connections {
+		for c := 0; c < n.adversary.Copies; c++ {
+			n.send(exec, conn, delay, next)
+		}
+	}
+}
+
 // checkReceiving checks if a node has already received a message from this connection
 // to prevent duplicate transmissions and optimize network efficiency
 func (n *Node) checkReceiving(relayNumber p2p.MessageID, conn *Node) bool {
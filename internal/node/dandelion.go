@@ -0,0 +1,183 @@
+package node
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/score"
+)
+
+// epoch holds a node's Dandelion choices, drawn once on its first stem message
+// An experiment run builds a new network, so it is one epoch; a network reused for
+// several broadcasts starts a new one with ResetEpoch
+type epoch struct {
+	drawn    bool
+	diffuser bool          // Floods stem messages instead of relaying them
+	relays   [2]*Node      // Stem relays; the node's own messages take the first
+	inbound  map[*Node]int // Relay chosen for each inbound neighbor
+}
+
+// relayDandelion handles message relay using Dandelion++: stem messages travel along a single
+// relay per hop until a diffuser or a fail-safe timer switches them to flooding (fluff)
+func (n *Node) relayDandelion(exec Executor, from *Node, msg Message) {
+	if msg.Stem && n.stem(exec, from, msg) {
+		return
+	}
+
+	fluff := msg
+	fluff.Stem = false
+	n.relayBasic(exec, from, fluff)
+}
+
+// stem forwards a stem message to one of the node's relays and arms the fail-safe timer
+// It returns false if the node is a diffuser and floods the message instead
+func (n *Node) stem(exec Executor, from *Node, msg Message) bool {
+	n.mu.Lock()
+
+	if n.fluffed[msg.ID] {
+		n.mu.Unlock()
+		return true // Already diffusing after receiving the fluff phase
+	}
+
+	n.drawEpoch(msg.Type.Level)
+
+	// The origin always stems its own message
+	if from != nil && n.epoch.diffuser {
+		n.fluffed[msg.ID] = true
+		n.mu.Unlock()
+		return false
+	}
+
+	relay := n.trusted(n.epoch.relay(from))
+	n.mu.Unlock()
+
+	if relay == nil {
+		return true // No connections
+	}
+
+	next := msg
+	next.Hop++
+	n.send(exec, relay, n.connections[relay], next)
+
+	// Fluff the message ourselves unless it comes back diffused before the timer expires
+	timer := msg.Type.FailSafe() + p2p.Delay(rand.ExpFloat64()*float64(msg.Type.FailSafe()))
+	exec.Process(n, timer, func() {
+		n.fluff(exec, nil, msg, false)
+	})
+
+	return true
+}
+
+// fluff floods a message the node holds but has not diffused yet, after the given processing delay
+// It is used when a stem node receives the message again and when its fail-safe timer expires
+func (n *Node) fluff(exec Executor, from *Node, msg Message, process bool) {
+	delay, ok := n.forwardDelay(msg)
+	if !ok {
+		return
+	}
+
+	n.mu.Lock()
+	if n.fluffed[msg.ID] {
+		n.mu.Unlock()
+		return
+	}
+	n.fluffed[msg.ID] = true
+	n.mu.Unlock()
+
+	fluff := msg
+	fluff.Stem = false
+
+	if !process {
+		n.relayBasic(exec, from, fluff)
+		return
+	}
+
+	exec.Process(n, delay, func() {
+		n.relayBasic(exec, from, fluff)
+	})
+}
+
+// drawEpoch picks the diffuser role with probability q percent and two random stem relays
+// The caller must hold the node's lock
+func (n *Node) drawEpoch(q int) {
+	if n.epoch.drawn {
+		return
+	}
+
+	conns := make([]*Node, 0, len(n.connections))
+	for conn := range n.connections {
+		conns = append(conns, conn)
+	}
+	sort.Slice(conns, func(a, b int) bool {
+		return conns[a].id < conns[b].id
+	})
+	rand.Shuffle(len(conns), func(a, b int) {
+		conns[a], conns[b] = conns[b], conns[a]
+	})
+
+	n.epoch = epoch{
+		drawn:    true,
+		diffuser: rand.Intn(100) < q,
+		inbound:  make(map[*Node]int),
+	}
+	copy(n.epoch.relays[:], conns)
+	if n.epoch.relays[1] == nil {
+		n.epoch.relays[1] = n.epoch.relays[0]
+	}
+}
+
+// ResetEpoch forgets the node's Dandelion choices, so its next stem message draws them anew
+// It must not be called while the node broadcasts
+func (n *Node) ResetEpoch() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.epoch = epoch{}
+}
+
+// relay returns the stem relay for a message from an inbound neighbor (nil for the node's own),
+// followed by the other relay. The relay back to the sender is avoided when the other one differs
+func (e *epoch) relay(from *Node) (*Node, *Node) {
+	if from == nil {
+		return e.relays[0], e.relays[1]
+	}
+
+	k, ok := e.inbound[from]
+	if !ok {
+		k = rand.Intn(2)
+		e.inbound[from] = k
+	}
+
+	if e.relays[k] == from {
+		return e.relays[1-k], e.relays[k]
+	}
+
+	return e.relays[k], e.relays[1-k]
+}
+
+// trusted returns the first of the chosen relays whose peer is not deprioritized, otherwise
+// another such connection, falling back to a peer that is at least not pruned
+func (n *Node) trusted(relay, other *Node) *Node {
+	if n.scores == nil || relay == nil {
+		return relay
+	}
+
+	conns := make([]*Node, 0, len(n.connections))
+	for conn := range n.connections {
+		conns = append(conns, conn)
+	}
+	sort.Slice(conns, func(a, b int) bool {
+		return conns[a].id < conns[b].id
+	})
+
+	for _, want := range []score.Status{score.Normal, score.Deprioritized} {
+		for _, conn := range append([]*Node{relay, other}, conns...) {
+			if n.peerStatus(conn) <= want {
+				return conn
+			}
+		}
+	}
+
+	return relay
+}
@@ -0,0 +1,45 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// Executor schedules the delayed steps of a broadcast
+// Implementations decide how waiting for processing and link delays is carried out
+type Executor interface {
+	// Process runs task on behalf of node n after its processing delay
+	Process(n *Node, delay p2p.Delay, task func())
+	// Transmit delivers msg from one node to another after the link delay
+	Transmit(from, to *Node, delay p2p.Delay, msg Message)
+}
+
+// GoroutineExecutor runs every transmission in its own goroutine
+// Processing delays are slept inline by the goroutine that received the message
+type GoroutineExecutor struct {
+	WG *sync.WaitGroup // Tracks all in-flight transmissions
+}
+
+// Process sleeps for the processing delay and runs the task in the calling goroutine
+func (e *GoroutineExecutor) Process(n *Node, delay p2p.Delay, task func()) {
+	// Simulate node processing delay
+	time.Sleep(time.Duration(delay) * time.Millisecond)
+
+	task()
+}
+
+// Transmit starts a goroutine that sleeps for the link delay and delivers the message
+func (e *GoroutineExecutor) Transmit(from, to *Node, delay p2p.Delay, msg Message) {
+	e.WG.Add(1)
+
+	go func() {
+		defer e.WG.Done()
+
+		// Simulate network transmission delay
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+
+		to.Receive(e, from, msg)
+	}()
+}
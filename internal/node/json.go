@@ -17,12 +17,14 @@ func (n *Node) ToJson() (string, error) {
 		Connections map[p2p.NodeID]p2p.Delay       `json:"connections"`
 		RelayMap    map[p2p.MessageID]time.Time    `json:"relay_map"`
 		ReceiveMap  map[p2p.MessageID][]p2p.NodeID `json:"receive_map"`
+		ReceiveTime map[p2p.MessageID][]time.Time  `json:"receive_time"`
 	}{
 		ID:          n.id,
 		Delay:       n.delay,
 		Connections: connectionConv(n.connections), // Convert node pointers to node IDs
 		RelayMap:    n.relayMap,
 		ReceiveMap:  n.receiveMap,
+		ReceiveTime: n.receiveTime,
 	}
 
 	// Marshal the struct to JSON
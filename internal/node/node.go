@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/score"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
 )
 
 // Node represents a single node in the P2P network
@@ -13,7 +15,16 @@ type Node struct {
 	delay       p2p.Delay                      // Network delay for this node
 	relayMap    map[p2p.MessageID]time.Time    // For tracking relay times
 	receiveMap  map[p2p.MessageID][]p2p.NodeID // For tracking duplicates
+	receiveTime map[p2p.MessageID][]time.Time  // Receipt times matching receiveMap entries
+	invalidMap  map[p2p.MessageID][]p2p.NodeID // Senders of invalid copies
+	sentBytes   map[p2p.MessageID]int64        // Framed wire bytes sent per message
 	connections map[*Node]p2p.Delay            // Map of connected nodes and their delays
+	recorder    trace.Recorder                 // Optional sink for broadcast events
+	role        p2p.Role                       // Behavior towards the protocol (honest by default)
+	adversary   *p2p.Adversary                 // Parameters of malicious roles
+	scores      *score.Table                   // Peer scores the node acts on (nil treats all peers equally)
+	fluffed     map[p2p.MessageID]bool         // Dandelion messages this node diffuses
+	epoch       epoch                          // Dandelion relays and role
 	mu          sync.RWMutex                   // Mutex for thread-safe access
 }
 
@@ -25,6 +36,10 @@ func NewNode(id p2p.NodeID, delay p2p.Delay) *Node {
 		delay:       delay,
 		relayMap:    make(map[p2p.MessageID]time.Time),
 		receiveMap:  make(map[p2p.MessageID][]p2p.NodeID),
+		receiveTime: make(map[p2p.MessageID][]time.Time),
+		invalidMap:  make(map[p2p.MessageID][]p2p.NodeID),
+		sentBytes:   make(map[p2p.MessageID]int64),
+		fluffed:     make(map[p2p.MessageID]bool),
 		mu:          sync.RWMutex{},
 	}
 }
@@ -60,3 +75,60 @@ func (n *Node) ReceiveRoute(messageID p2p.MessageID) []p2p.NodeID {
 
 	return n.receiveMap[messageID]
 }
+
+// ReceiveTimes returns the receipt times of a message, aligned with ReceiveRoute
+func (n *Node) ReceiveTimes(messageID p2p.MessageID) []time.Time {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.receiveTime[messageID]
+}
+
+// InvalidRoute returns the list of node IDs from which invalid copies of a message were received
+func (n *Node) InvalidRoute(messageID p2p.MessageID) []p2p.NodeID {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.invalidMap[messageID]
+}
+
+// SetScores makes the node act on the scores of its peers in a table indexed by node IDs
+// The table must not be updated while a message propagates
+func (n *Node) SetScores(t *score.Table) {
+	n.scores = t
+}
+
+// peerStatus returns how the node treats a connected peer according to its score
+func (n *Node) peerStatus(conn *Node) score.Status {
+	return n.scores.Status(int32(n.id), int32(conn.id))
+}
+
+// SetRecorder attaches an event recorder to this node (nil disables recording)
+func (n *Node) SetRecorder(r trace.Recorder) {
+	n.recorder = r
+}
+
+// SetRole makes the node follow a malicious role with the parameters of adversary
+func (n *Node) SetRole(role p2p.Role, adversary *p2p.Adversary) {
+	n.role = role
+	n.adversary = adversary
+}
+
+// Role returns the behavior of the node towards the protocol
+func (n *Node) Role() p2p.Role {
+	return n.role
+}
+
+// Drop records that a transmission from the node to a peer was lost on its way
+func (n *Node) Drop(to *Node, msg Message) {
+	n.record(trace.Drop, msg.ID, n.id, to.id, msg.Hop)
+}
+
+// record emits a broadcast event if a recorder is attached
+func (n *Node) record(kind trace.Kind, messageID p2p.MessageID, from, to p2p.NodeID, hop int) {
+	if n.recorder == nil {
+		return
+	}
+
+	n.recorder.Record(trace.Event{Kind: kind, Message: messageID, From: from, To: to, Hop: hop})
+}
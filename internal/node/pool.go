@@ -0,0 +1,294 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// poolTask is a unit of work waiting for its due time
+type poolTask struct {
+	due      time.Time
+	seq      uint64 // Insertion order, breaks ties between equal due times
+	node     *Node  // Node the task runs on behalf of
+	transmit bool   // Whether the task counts against the in-flight cap
+	run      func()
+}
+
+// Pool executes broadcasts on a fixed number of worker goroutines
+// Delayed tasks wait in a single timer queue; once due they move to the inbox of the
+// node they run on. Workers drain one node's inbox at a time, so each node handles its
+// messages sequentially. Transmit blocks while MaxInFlight transmissions are waiting for
+// their link delay, which bounds memory use for very large networks
+type Pool struct {
+	mu          sync.Mutex
+	work        *sync.Cond // Signals workers that a node became ready
+	space       *sync.Cond // Signals senders that the in-flight count dropped
+	timers      []poolTask // Min-heap of tasks ordered by due time
+	seq         uint64
+	wake        chan struct{} // Wakes the timer goroutine when an earlier task is added
+	inboxes     map[*Node][]func()
+	ready       []*Node        // Nodes with pending inbox tasks, not yet claimed by a worker
+	active      map[*Node]bool // Nodes that are ready or being drained by a worker
+	inFlight    int
+	maxInFlight int
+	pending     sync.WaitGroup // Tasks scheduled but not yet finished
+	closed      bool
+	done        sync.WaitGroup // Worker and timer goroutines
+}
+
+// NewPool creates and starts a pool with the given number of workers
+// maxInFlight caps the number of transmissions waiting for their link delay (0 means unlimited)
+func NewPool(workers, maxInFlight int) *Pool {
+	p := &Pool{
+		wake:        make(chan struct{}, 1),
+		inboxes:     make(map[*Node][]func()),
+		active:      make(map[*Node]bool),
+		maxInFlight: maxInFlight,
+	}
+	p.work = sync.NewCond(&p.mu)
+	p.space = sync.NewCond(&p.mu)
+
+	workers = max(workers, 1)
+	p.done.Add(workers + 1)
+
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	go p.timer()
+
+	return p
+}
+
+// Process schedules a task on node n after its processing delay
+func (p *Pool) Process(n *Node, delay p2p.Delay, task func()) {
+	p.schedule(n, delay, false, task)
+}
+
+// Transmit schedules delivery of a message after the link delay, blocking while the pool is at capacity
+func (p *Pool) Transmit(from, to *Node, delay p2p.Delay, msg Message) {
+	p.schedule(to, delay, true, func() {
+		to.Receive(p, from, msg)
+	})
+}
+
+// Wait blocks until every scheduled task has finished
+func (p *Pool) Wait() {
+	p.pending.Wait()
+}
+
+// Close stops all worker goroutines after pending tasks have finished
+func (p *Pool) Close() {
+	p.Wait()
+
+	p.mu.Lock()
+	p.closed = true
+	p.work.Broadcast()
+	p.space.Broadcast()
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+
+	p.done.Wait()
+}
+
+// schedule adds a task to the timer queue, or directly to the node's inbox if it is due
+func (p *Pool) schedule(n *Node, delay p2p.Delay, transmit bool, run func()) {
+	p.pending.Add(1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if delay == 0 {
+		p.enqueue(n, run)
+		return
+	}
+
+	// Apply backpressure on transmissions waiting for their link delay
+	if transmit && p.maxInFlight > 0 {
+		for p.inFlight >= p.maxInFlight && !p.closed {
+			p.space.Wait()
+		}
+	}
+
+	if transmit {
+		p.inFlight++
+	}
+
+	p.seq++
+	p.pushTimer(poolTask{
+		due:      time.Now().Add(time.Duration(delay) * time.Millisecond),
+		seq:      p.seq,
+		node:     n,
+		transmit: transmit,
+		run:      run,
+	})
+
+	// Wake the timer goroutine if this task is now the earliest
+	if p.timers[0].seq == p.seq {
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// enqueue appends a due task to a node's inbox and marks the node ready
+// Must be called with p.mu held
+func (p *Pool) enqueue(n *Node, run func()) {
+	p.inboxes[n] = append(p.inboxes[n], run)
+
+	if !p.active[n] {
+		p.active[n] = true
+		p.ready = append(p.ready, n)
+		p.work.Signal()
+	}
+}
+
+// timer moves due tasks from the timer queue into node inboxes
+func (p *Pool) timer() {
+	defer p.done.Done()
+
+	t := time.NewTimer(time.Hour)
+	defer t.Stop()
+
+	for {
+		p.mu.Lock()
+
+		now := time.Now()
+		for len(p.timers) > 0 && !p.timers[0].due.After(now) {
+			task := p.popTimer()
+			if task.transmit {
+				p.inFlight--
+				p.space.Signal()
+			}
+			p.enqueue(task.node, task.run)
+		}
+
+		wait := time.Duration(-1)
+		if len(p.timers) > 0 {
+			wait = p.timers[0].due.Sub(now)
+		}
+		closed := p.closed
+
+		p.mu.Unlock()
+
+		if closed {
+			return
+		}
+
+		if wait < 0 {
+			<-p.wake
+			continue
+		}
+
+		t.Reset(wait)
+		select {
+		case <-p.wake:
+			if !t.Stop() {
+				<-t.C
+			}
+		case <-t.C:
+		}
+	}
+}
+
+// worker drains the inboxes of ready nodes
+func (p *Pool) worker() {
+	defer p.done.Done()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		for len(p.ready) == 0 && !p.closed {
+			p.work.Wait()
+		}
+		if p.closed {
+			return
+		}
+
+		n := p.ready[0]
+		p.ready[0] = nil
+		p.ready = p.ready[1:]
+
+		// Run all due tasks of this node without holding the lock
+		for len(p.inboxes[n]) > 0 {
+			tasks := p.inboxes[n]
+			delete(p.inboxes, n)
+
+			p.mu.Unlock()
+			for _, run := range tasks {
+				run()
+				p.pending.Done()
+			}
+			p.mu.Lock()
+		}
+
+		delete(p.active, n)
+	}
+}
+
+// pushTimer adds a task to the timer heap
+// Must be called with p.mu held
+func (p *Pool) pushTimer(task poolTask) {
+	p.timers = append(p.timers, task)
+
+	i := len(p.timers) - 1
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !p.timers[i].before(&p.timers[parent]) {
+			break
+		}
+		p.timers[i], p.timers[parent] = p.timers[parent], p.timers[i]
+		i = parent
+	}
+}
+
+// popTimer removes and returns the earliest task from the timer heap
+// Must be called with p.mu held
+func (p *Pool) popTimer() poolTask {
+	h := p.timers
+	top := h[0]
+
+	last := len(h) - 1
+	h[0] = h[last]
+	h[last] = poolTask{}
+	h = h[:last]
+
+	i := 0
+	for {
+		smallest := i
+		left, right := 2*i+1, 2*i+2
+
+		if left < len(h) && h[left].before(&h[smallest]) {
+			smallest = left
+		}
+		if right < len(h) && h[right].before(&h[smallest]) {
+			smallest = right
+		}
+		if smallest == i {
+			break
+		}
+
+		h[i], h[smallest] = h[smallest], h[i]
+		i = smallest
+	}
+
+	p.timers = h
+
+	return top
+}
+
+// before orders tasks by due time, then by insertion order
+func (t *poolTask) before(o *poolTask) bool {
+	if !t.due.Equal(o.due) {
+		return t.due.Before(o.due)
+	}
+
+	return t.seq < o.seq
+}
@@ -0,0 +1,148 @@
+package node
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// testNodes links count nodes into a ring with random chords and short random delays
+// The last node stays isolated, so a broadcast never reaches it
+func testNodes(count int, seed int64) []*Node {
+	rng := rand.New(rand.NewSource(seed))
+
+	nodes := make([]*Node, count)
+	for i := range nodes {
+		nodes[i] = NewNode(p2p.NodeID(i), p2p.Delay(rng.Intn(2)))
+	}
+
+	link := func(a, b *Node) {
+		if a == b {
+			return
+		}
+		d := p2p.Delay(rng.Intn(3) + 1)
+		a.connections[b] = d
+		b.connections[a] = d
+	}
+	for i := 0; i < count-1; i++ {
+		link(nodes[i], nodes[(i+1)%(count-1)])
+		link(nodes[i], nodes[rng.Intn(count-1)])
+	}
+
+	return nodes
+}
+
+// reached returns the IDs of the nodes holding a message
+func reached(nodes []*Node, messageID p2p.MessageID) map[p2p.NodeID]bool {
+	result := make(map[p2p.NodeID]bool)
+	for _, n := range nodes {
+		if _, ok := n.RelayTime(messageID); ok {
+			result[n.id] = true
+		}
+	}
+
+	return result
+}
+
+func TestPoolReachesSameNodes(t *testing.T) {
+	const count = 200
+	bt := p2p.BroadcastType{Type: p2p.BasicPublish}
+
+	nodes := testNodes(count, 1)
+	wg := &sync.WaitGroup{}
+	nodes[0].Broadcast(1, bt, wg)
+	wg.Wait()
+	want := reached(nodes, 1)
+
+	if len(want) != count-1 {
+		t.Fatalf("goroutine executor reached %d nodes, want %d", len(want), count-1)
+	}
+
+	pools := []struct {
+		name        string
+		workers     int
+		maxInFlight int
+	}{
+		{"one worker", 1, 0},
+		{"unlimited", 8, 0},
+		{"capped", 4, 3},
+		{"cap of one", 2, 1},
+	}
+
+	for _, tt := range pools {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := testNodes(count, 1)
+
+			p := NewPool(tt.workers, tt.maxInFlight)
+			nodes[0].BroadcastWith(p, 1, bt)
+			p.Close()
+
+			got := reached(nodes, 1)
+			if len(got) != len(want) {
+				t.Fatalf("reached %d nodes, want %d", len(got), len(want))
+			}
+			for id := range want {
+				if !got[id] {
+					t.Errorf("node %d not reached", id)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolInFlightCap(t *testing.T) {
+	const (
+		senders     = 12
+		maxInFlight = 3
+	)
+	bt := p2p.BroadcastType{Type: p2p.BasicPublish}
+
+	to := NewNode(0, 0)
+	from := make([]*Node, senders)
+	for i := range from {
+		from[i] = NewNode(p2p.NodeID(i+1), 0)
+	}
+
+	p := NewPool(2, maxInFlight)
+	defer p.Close()
+
+	var returned atomic.Int32
+	for i := range from {
+		go func() {
+			p.Transmit(from[i], to, 100, Message{ID: 1, Type: bt, Origin: from[i].id})
+			returned.Add(1)
+		}()
+	}
+
+	// Transmit returns once the transmission is queued, so at most the cap return before the first delivery
+	time.Sleep(10 * time.Millisecond)
+	if n := returned.Load(); n > maxInFlight {
+		t.Errorf("%d transmissions queued before any was delivered, cap is %d", n, maxInFlight)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for returned.Load() < senders {
+			p.mu.Lock()
+			inFlight := p.inFlight
+			p.mu.Unlock()
+
+			if inFlight > maxInFlight {
+				t.Errorf("%d transmissions in flight, cap is %d", inFlight, maxInFlight)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	<-done
+	p.Wait()
+
+	if got := len(to.ReceiveRoute(1)); got != senders {
+		t.Errorf("received %d copies, want %d", got, senders)
+	}
+}
@@ -0,0 +1,44 @@
+package node
+
+import (
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/wire"
+)
+
+// Envelope returns the wire envelope of a message sent by a node
+func (m Message) Envelope(from p2p.NodeID) wire.Envelope {
+	kind := wire.Relay
+	if m.Stem {
+		kind = wire.Stem
+	}
+
+	return wire.Envelope{
+		Kind:     kind,
+		ID:       m.ID,
+		Origin:   m.Origin,
+		From:     from,
+		Hop:      m.Hop,
+		Protocol: m.Type,
+		Payload:  m.Payload,
+	}
+}
+
+// MessageOf returns the message carried by a wire envelope
+func MessageOf(e wire.Envelope) Message {
+	return Message{
+		ID:      e.ID,
+		Type:    e.Protocol,
+		Hop:     e.Hop,
+		Origin:  e.Origin,
+		Stem:    e.Kind == wire.Stem,
+		Payload: e.Payload,
+	}
+}
+
+// SentBytes returns the bytes a node sent for a message, framed as by the wire codec
+func (n *Node) SentBytes(messageID p2p.MessageID) int64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.sentBytes[messageID]
+}
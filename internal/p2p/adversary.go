@@ -0,0 +1,209 @@
+package p2p
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Role is the behavior of a node towards the broadcast protocol
+type Role uint8
+
+// Node roles; every role but Honest deviates from the protocol
+const (
+	Honest  Role = iota // Follows the protocol
+	Dropper             // Receives messages but never forwards them
+	Censor              // Drops messages published by censored origins, forwards the rest
+	Delayer             // Forwards after holding each message for an extra delay
+	Spammer             // Sends several copies to every neighbor, ignoring duplicate suppression
+	Sybil               // Attacker identity occupying connection slots; receives but never forwards
+	Forger              // Forwards copies with a forged payload that receivers discard as invalid
+)
+
+// String returns the lower-case name of the role
+func (r Role) String() string {
+	switch r {
+	case Honest:
+		return "honest"
+	case Dropper:
+		return "dropper"
+	case Censor:
+		return "censor"
+	case Delayer:
+		return "delayer"
+	case Spammer:
+		return "spammer"
+	case Sybil:
+		return "sybil"
+	case Forger:
+		return "forger"
+	default:
+		return "unknown"
+	}
+}
+
+// AdversaryConfig describes which share of the nodes misbehaves and how
+type AdversaryConfig struct {
+	Droppers float64  // Fraction of nodes that are droppers
+	Censors  float64  // Fraction of nodes that are censors
+	Delayers float64  // Fraction of nodes that are delayers
+	Spammers float64  // Fraction of nodes that are spammers
+	Forgers  float64  // Fraction of nodes that are forgers
+	Censored []NodeID // Origins whose messages censors drop
+	Hold     Delay    // Extra delay of delayers in milliseconds
+	Copies   int      // Copies a spammer sends to each neighbor (at least 1)
+}
+
+// Adversary assigns a role to every node of a network, indexed like its nodes
+// Node IDs are assumed to equal node indices, as in every generator and loader
+type Adversary struct {
+	Roles    []Role
+	Censored map[NodeID]bool
+	Hold     Delay
+	Copies   int
+	Victims  []int // Honest nodes targeted by an eclipse attack
+}
+
+// NewAdversary assigns roles to nodeCount nodes at random according to config
+// Protected nodes (such as the broadcast origin) always stay honest. Experiment validation keeps
+// the fractions summing to at most 1; otherwise the roles listed last get fewer nodes than asked
+func NewAdversary(nodeCount int, config AdversaryConfig, protected []NodeID, seed int64) *Adversary {
+	a := &Adversary{
+		Roles:    make([]Role, nodeCount),
+		Censored: make(map[NodeID]bool, len(config.Censored)),
+		Hold:     config.Hold,
+		Copies:   max(config.Copies, 1),
+	}
+	for _, id := range config.Censored {
+		a.Censored[id] = true
+	}
+
+	isProtected := make(map[NodeID]bool, len(protected))
+	for _, id := range protected {
+		isProtected[id] = true
+	}
+
+	candidates := make([]int, 0, nodeCount)
+	for i := 0; i < nodeCount; i++ {
+		if !isProtected[NodeID(i)] {
+			candidates = append(candidates, i)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	// Take consecutive slices of the shuffled candidates for each role
+	next := 0
+	for _, share := range []struct {
+		role     Role
+		fraction float64
+	}{
+		{Dropper, config.Droppers},
+		{Censor, config.Censors},
+		{Delayer, config.Delayers},
+		{Spammer, config.Spammers},
+		{Forger, config.Forgers},
+	} {
+		count := int(math.Round(share.fraction * float64(nodeCount)))
+		for ; count > 0 && next < len(candidates); count-- {
+			a.Roles[candidates[next]] = share.role
+			next++
+		}
+	}
+
+	return a
+}
+
+// Role returns the role of the node at index i
+func (a *Adversary) Role(i int) Role {
+	if a == nil || i >= len(a.Roles) {
+		return Honest
+	}
+
+	return a.Roles[i]
+}
+
+// AddSybils appends count Sybil nodes after the existing ones
+func (a *Adversary) AddSybils(count int) {
+	for ; count > 0; count-- {
+		a.Roles = append(a.Roles, Sybil)
+	}
+}
+
+// Count returns the number of nodes with a malicious role
+func (a *Adversary) Count() int {
+	count := 0
+	for _, r := range a.Roles {
+		if r != Honest {
+			count++
+		}
+	}
+
+	return count
+}
+
+// HonestView is the per-node outcome of a broadcast that SetHonest summarizes
+type HonestView struct {
+	Origin   int
+	Reached  func(i int) bool    // Whether node i received the message
+	Latency  func(i int) float64 // First receipt latency of node i in milliseconds
+	Receipts func(i int) int     // Receptions of node i, including duplicates
+}
+
+// SetHonest fills the honest-node metrics: coverage, duplicates and latency over the honest nodes
+// other than the origin, which show how much the adversaries degrade the broadcast for everyone else
+func (m *NetworkMetric) SetHonest(a *Adversary, view HonestView) {
+	honest, reached, receipts := 0, 0, 0
+	var latencies []float64
+
+	for i := range a.Roles {
+		if a.Roles[i] != Honest || i == view.Origin {
+			continue
+		}
+
+		honest++
+		if view.Reached(i) {
+			reached++
+			receipts += view.Receipts(i)
+			latencies = append(latencies, view.Latency(i))
+		}
+	}
+
+	sort.Float64s(latencies)
+
+	m.Adversaries = a.Count()
+	if honest > 0 {
+		m.HonestReceivingRate = float64(reached) / float64(honest)
+	}
+	if reached > 0 {
+		m.HonestDuplicateRate = float64(receipts)/float64(reached) - 1
+	}
+	m.HonestLatencyP50 = percentile(latencies, 0.50)
+	m.HonestLatencyP99 = percentile(latencies, 0.99)
+
+	if len(a.Victims) > 0 {
+		m.setVictims(a.Victims, view)
+	}
+}
+
+// setVictims fills the eclipse metrics: whether and when the victims received the message
+func (m *NetworkMetric) setVictims(victims []int, view HonestView) {
+	var latencies []float64
+	for _, v := range victims {
+		if view.Reached(v) {
+			latencies = append(latencies, view.Latency(v))
+		}
+	}
+
+	sort.Float64s(latencies)
+
+	m.Victims = len(victims)
+	m.VictimReceivingRate = float64(len(latencies)) / float64(len(victims))
+	m.VictimLatencyP50 = percentile(latencies, 0.50)
+	if len(latencies) > 0 {
+		m.VictimLatencyMax = latencies[len(latencies)-1]
+	}
+}
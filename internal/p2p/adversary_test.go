@@ -0,0 +1,42 @@
+package p2p
+
+import "testing"
+
+func TestNewAdversaryRoles(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AdversaryConfig
+		want   map[Role]int
+	}{
+		{"honest", AdversaryConfig{}, map[Role]int{}},
+		{"droppers", AdversaryConfig{Droppers: 0.1}, map[Role]int{Dropper: 10}},
+		{"mixed", AdversaryConfig{Droppers: 0.1, Censors: 0.2, Forgers: 0.05}, map[Role]int{Dropper: 10, Censor: 20, Forger: 5}},
+		{"every unprotected node", AdversaryConfig{Droppers: 0.5, Spammers: 0.5}, map[Role]int{Dropper: 50, Spammer: 49}},
+		{"fractions above 1", AdversaryConfig{Droppers: 0.7, Delayers: 0.7}, map[Role]int{Dropper: 70, Delayer: 29}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAdversary(100, tt.config, []NodeID{0}, 1)
+
+			if a.Role(0) != Honest {
+				t.Errorf("protected node has role %v", a.Role(0))
+			}
+
+			got := make(map[Role]int)
+			for i := range a.Roles {
+				if r := a.Role(i); r != Honest {
+					got[r]++
+				}
+			}
+			for role, count := range tt.want {
+				if got[role] != count {
+					t.Errorf("%v: got %d nodes, want %d", role, got[role], count)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got roles %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
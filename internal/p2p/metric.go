@@ -1,5 +1,6 @@
 package p2p
 
+// NetworkMetric holds the result of a single broadcast experiment
 type NetworkMetric struct {
 	NodeCount     int     `json:"node_count"`
 	Broadcast     string  `json:"broadcast"`
@@ -7,4 +8,102 @@ type NetworkMetric struct {
 	Delay         int     `json:"delay"`
 	DuplicateRate float64 `json:"duplicate_rate"`
 	ReceivingRate float64 `json:"receiving_rate"`
+	Receptions    int     `json:"receptions"`               // Receptions over all nodes, including duplicates
+	Reached       int     `json:"reached"`                  // Non-origin nodes holding the message
+	LatencyP50    float64 `json:"latency_p50_ms,omitempty"` // Median first receipt latency
+	LatencyP99    float64 `json:"latency_p99_ms,omitempty"` // 99th percentile first receipt latency
+	LatencyMax    float64 `json:"latency_max_ms,omitempty"` // Time until the last reached node received the message
+	Lost          int     `json:"lost,omitempty"`           // Transmissions the socket engine lost over all messages of the run
+	Bytes         int64   `json:"bytes,omitempty"`          // Wire bytes sent for the message, length prefixes included
+	BytesPerNode  float64 `json:"bytes_per_node,omitempty"` // Wire bytes sent per node
+
+	// Set by SetHonest when some nodes misbehave
+	Adversaries         int     `json:"adversaries,omitempty"`           // Nodes with a malicious role
+	HonestReceivingRate float64 `json:"honest_receiving_rate,omitempty"` // Share of honest nodes reached
+	HonestDuplicateRate float64 `json:"honest_duplicate_rate,omitempty"` // Duplicate receptions per reached honest node
+	HonestLatencyP50    float64 `json:"honest_latency_p50_ms,omitempty"` // Median latency of reached honest nodes
+	HonestLatencyP99    float64 `json:"honest_latency_p99_ms,omitempty"` // 99th percentile latency of reached honest nodes
+
+	// Set by SetHonest when an eclipse attack targets some nodes
+	Victims             int     `json:"victims,omitempty"`               // Honest nodes whose connection slots the Sybils occupy
+	VictimReceivingRate float64 `json:"victim_receiving_rate,omitempty"` // Share of victims reached
+	VictimLatencyP50    float64 `json:"victim_latency_p50_ms,omitempty"` // Median latency of reached victims
+	VictimLatencyMax    float64 `json:"victim_latency_max_ms,omitempty"` // Latency of the last reached victim
+
+	// Set by SetRecovery when a run broadcasts several messages; the metrics above describe the last one
+	Messages                   int     `json:"messages,omitempty"`                      // Messages broadcast in the run
+	InitialReceivingRate       float64 `json:"initial_receiving_rate,omitempty"`        // Receiving rate of the first message
+	InitialDuplicateRate       float64 `json:"initial_duplicate_rate,omitempty"`        // Duplicate rate of the first message
+	InitialLatencyP99          float64 `json:"initial_latency_p99_ms,omitempty"`        // 99th percentile latency of the first message
+	InitialHonestReceivingRate float64 `json:"initial_honest_receiving_rate,omitempty"` // Honest receiving rate of the first message
+	InitialHonestLatencyP99    float64 `json:"initial_honest_latency_p99_ms,omitempty"` // Honest 99th percentile latency of the first message
+	PrunedLinks                int     `json:"pruned_links,omitempty"`                  // Peer links pruned or graylisted for the last message
+	PrunedHonestLinks          int     `json:"pruned_honest_links,omitempty"`           // Pruned links whose peer is honest
+}
+
+// NewNetworkMetric computes a broadcast metric from reception counts
+// DuplicateRate is the number of duplicate receptions per reached node (not a fraction: it
+// exceeds 1 in dense networks) and ReceivingRate the share of non-origin nodes reached;
+// both are 0 when no node was reached, so the metric always encodes as JSON
+// Parameters:
+//   - recvCount: total number of message receptions over all nodes (including duplicates)
+//   - dontRecvCount: number of nodes that never received the message, counting the origin
+//     among them even if a copy came back to it, so Reached leaves the origin out
+func NewNetworkMetric(nodeCount int, broadcast string, delay int, avgDegree float64, recvCount, dontRecvCount int) NetworkMetric {
+	recvTarget := nodeCount - 1 // Expected number of receivers (excluding sender)
+	reached := recvTarget - dontRecvCount + 1
+
+	m := NetworkMetric{
+		NodeCount:  nodeCount,
+		Broadcast:  broadcast,
+		Delay:      delay,
+		AvgDegree:  avgDegree,
+		Receptions: recvCount,
+		Reached:    reached,
+	}
+	if reached > 0 {
+		m.DuplicateRate = float64(recvCount)/float64(reached) - 1 // Duplicate reception rate
+		m.ReceivingRate = float64(reached) / float64(recvTarget)  // Message delivery rate
+	}
+
+	return m
+}
+
+// SetLatencies fills the latency percentiles from first receipt latencies in milliseconds
+// The latencies must be sorted in ascending order
+func (m *NetworkMetric) SetLatencies(sorted []float64) {
+	m.LatencyP50 = percentile(sorted, 0.50)
+	m.LatencyP99 = percentile(sorted, 0.99)
+	m.LatencyMax = percentile(sorted, 1.00)
+}
+
+// SetBytes records the wire bytes sent for the message, as sized by the wire codec
+func (m *NetworkMetric) SetBytes(bytes int64) {
+	m.Bytes = bytes
+	if m.NodeCount > 0 {
+		m.BytesPerNode = float64(bytes) / float64(m.NodeCount)
+	}
+}
+
+// SetRecovery records the metric of the first of several messages, so it can be compared
+// with the last one to measure how far peer scoring recovers from the adversaries
+func (m *NetworkMetric) SetRecovery(first NetworkMetric, messages int) {
+	m.Messages = messages
+	m.InitialReceivingRate = first.ReceivingRate
+	m.InitialDuplicateRate = first.DuplicateRate
+	m.InitialLatencyP99 = first.LatencyP99
+	m.InitialHonestReceivingRate = first.HonestReceivingRate
+	m.InitialHonestLatencyP99 = first.HonestLatencyP99
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	idx := int(p*float64(len(sorted))+0.5) - 1
+	idx = min(max(idx, 0), len(sorted)-1)
+
+	return sorted[idx]
 }
@@ -13,16 +13,35 @@ type Delay uint64
 
 // BroadcastType defines the type and configuration of broadcast method
 type BroadcastType struct {
-	Type  string // The broadcast algorithm type (BasicPublish or WavePublish)
-	Level int    // The level parameter for WavePublish (unused for BasicPublish)
+	Type    string // The broadcast algorithm type (BasicPublish, WavePublish or Dandelion)
+	Level   int    // The level parameter for WavePublish, the fluff probability q in percent for Dandelion
+	Timeout Delay  // Mean fail-safe timeout for Dandelion in milliseconds (0 uses DefaultDandelionTimeout)
 }
 
 // Constants for different broadcast algorithm types
 const (
 	BasicPublish = "BasicPublish" // Simple flooding-based broadcast
 	WavePublish  = "WavePublish"  // Wave-based broadcast with level control
+	Dandelion    = "Dandelion"    // Dandelion++: anonymous stem phase, then flooding (fluff)
 )
 
+// DefaultDandelionTimeout is the fail-safe timeout of Dandelion if none is given
+const DefaultDandelionTimeout Delay = 1000
+
+// FailSafe returns the fail-safe timeout of a Dandelion broadcast
+func (bt BroadcastType) FailSafe() Delay {
+	if bt.Timeout == 0 {
+		return DefaultDandelionTimeout
+	}
+
+	return bt.Timeout
+}
+
+// Floods reports whether every node sends the message to all its peers but the one it came from
+func (bt BroadcastType) Floods() bool {
+	return bt.Type == BasicPublish
+}
+
 // String returns a human-readable string representation of the broadcast type
 func (bt BroadcastType) String() string {
 	switch bt.Type {
@@ -31,7 +50,54 @@ func (bt BroadcastType) String() string {
 	case WavePublish:
 		// Include the level parameter in the string for WavePublish
 		return fmt.Sprintf("WavePublish-%d", bt.Level)
+	case Dandelion:
+		// The timeout is only included if it differs from the default
+		if bt.FailSafe() != DefaultDandelionTimeout {
+			return fmt.Sprintf("Dandelion-%d-%d", bt.Level, bt.Timeout)
+		}
+		return fmt.Sprintf("Dandelion-%d", bt.Level)
 	default:
 		return "Unknown"
 	}
 }
+
+// ParseBroadcastType parses the string form produced by BroadcastType.String,
+// e.g. "BasicPublish", "WavePublish-30", "Dandelion-10" or "Dandelion-10-500"
+func ParseBroadcastType(s string) (BroadcastType, error) {
+	if s == BasicPublish {
+		return BroadcastType{Type: BasicPublish}, nil
+	}
+
+	var level int
+	if _, err := fmt.Sscanf(s, WavePublish+"-%d", &level); err == nil {
+		return BroadcastType{Type: WavePublish, Level: level}, nil
+	}
+
+	var timeout Delay
+	if _, err := fmt.Sscanf(s, Dandelion+"-%d-%d", &level, &timeout); err == nil {
+		return BroadcastType{Type: Dandelion, Level: level, Timeout: timeout}, nil
+	}
+	if _, err := fmt.Sscanf(s, Dandelion+"-%d", &level); err == nil {
+		return BroadcastType{Type: Dandelion, Level: level}, nil
+	}
+
+	return BroadcastType{}, fmt.Errorf("unknown broadcast type %q", s)
+}
+
+// SortKey orders broadcast type names: BasicPublish first, then WavePublish by descending level,
+// then Dandelion by descending fluff probability; unknown names sort last
+func SortKey(name string) int {
+	bt, err := ParseBroadcastType(name)
+	if err != nil {
+		return 999
+	}
+
+	switch bt.Type {
+	case BasicPublish:
+		return -1
+	case Dandelion:
+		return 200 - bt.Level
+	default:
+		return 100 - bt.Level
+	}
+}
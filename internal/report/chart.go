@@ -0,0 +1,195 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+)
+
+// Chart geometry in SVG user units
+const (
+	chartWidth   = 760
+	chartHeight  = 420
+	marginLeft   = 70
+	marginRight  = 190 // Room for the legend
+	marginTop    = 36
+	marginBottom = 90 // Room for rotated category labels
+	maxLegend    = 24 // Series listed in the legend before it is truncated
+)
+
+// palette matches matplotlib's tab10 colors used by the Python analyzer
+var palette = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+// Point is a single data point of a series
+type Point struct {
+	X, Y float64
+}
+
+// Series is a named line of a chart
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// Chart is a line chart rendered as inline SVG
+type Chart struct {
+	Title      string
+	XLabel     string
+	YLabel     string
+	Categories []string // If set, X values are indices into the categories
+	Step       bool     // Draw lines as step functions (for CDFs)
+	Series     []Series
+}
+
+// SVG renders the chart as a standalone <svg> element
+func (c *Chart) SVG() string {
+	minX, maxX, minY, maxY := c.bounds()
+
+	plotW := float64(chartWidth - marginLeft - marginRight)
+	plotH := float64(chartHeight - marginTop - marginBottom)
+
+	sx := func(x float64) float64 {
+		return marginLeft + (x-minX)/(maxX-minX)*plotW
+	}
+	sy := func(y float64) float64 {
+		return marginTop + plotH - (y-minY)/(maxY-minY)*plotH
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" font-family="sans-serif" font-size="11">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<text x="%d" y="20" font-size="14" font-weight="bold">%s</text>`, marginLeft, html.EscapeString(c.Title))
+
+	// Horizontal grid lines and y tick labels
+	for _, y := range ticks(minY, maxY) {
+		py := sy(y)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#ddd"/>`, marginLeft, py, marginLeft+plotW, py)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`, marginLeft-6, py, formatTick(y))
+	}
+
+	// X tick labels
+	if c.Categories != nil {
+		for i, name := range c.Categories {
+			px := sx(float64(i))
+			fmt.Fprintf(&b, `<text transform="translate(%.1f,%.1f) rotate(-45)" text-anchor="end" font-size="9">%s</text>`,
+				px, marginTop+plotH+12, html.EscapeString(name))
+		}
+	} else {
+		for _, x := range ticks(minX, maxX) {
+			px := sx(x)
+			fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#999"/>`, px, marginTop+plotH, px, marginTop+plotH+4)
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, px, marginTop+plotH+16, formatTick(x))
+		}
+	}
+
+	// Axes and labels
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%.1f" fill="none" stroke="#666"/>`, marginLeft, marginTop, plotW, plotH)
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, marginLeft+plotW/2, chartHeight-8, html.EscapeString(c.XLabel))
+	fmt.Fprintf(&b, `<text transform="translate(16,%.1f) rotate(-90)" text-anchor="middle">%s</text>`, marginTop+plotH/2, html.EscapeString(c.YLabel))
+
+	// Series
+	for i, s := range c.Series {
+		color := palette[i%len(palette)]
+		var path strings.Builder
+
+		for j, p := range s.Points {
+			switch {
+			case j == 0:
+				fmt.Fprintf(&path, "M%.1f,%.1f", sx(p.X), sy(p.Y))
+			case c.Step:
+				fmt.Fprintf(&path, " H%.1f V%.1f", sx(p.X), sy(p.Y))
+			default:
+				fmt.Fprintf(&path, " L%.1f,%.1f", sx(p.X), sy(p.Y))
+			}
+		}
+
+		fmt.Fprintf(&b, `<g><title>%s</title><path d="%s" fill="none" stroke="%s" stroke-width="1.8" stroke-opacity="0.85"/>`,
+			html.EscapeString(s.Name), path.String(), color)
+		if !c.Step {
+			for _, p := range s.Points {
+				fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="%s"><title>%s: %s</title></circle>`,
+					sx(p.X), sy(p.Y), color, html.EscapeString(s.Name), formatTick(p.Y))
+			}
+		}
+		b.WriteString(`</g>`)
+
+		// Legend entry
+		if i < maxLegend {
+			ly := marginTop + 6 + i*14
+			fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="%s" stroke-width="2"/>`,
+				marginLeft+plotW+12, ly, marginLeft+plotW+28, ly, color)
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" dominant-baseline="middle">%s</text>`, marginLeft+plotW+32, ly, html.EscapeString(s.Name))
+		} else if i == maxLegend {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d">+%d more</text>`, marginLeft+plotW+12, marginTop+10+i*14, len(c.Series)-maxLegend)
+		}
+	}
+
+	b.WriteString(`</svg>`)
+
+	return b.String()
+}
+
+// bounds returns the data range with a margin around y, never empty
+func (c *Chart) bounds() (minX, maxX, minY, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+
+	if c.Categories != nil {
+		minX, maxX = -0.5, float64(len(c.Categories))-0.5
+	}
+	if math.IsInf(minX, 0) {
+		minX, maxX, minY, maxY = 0, 1, 0, 1
+	}
+	if maxX == minX {
+		minX, maxX = minX-1, maxX+1
+	}
+
+	// Dynamic y limits with a 10% margin, as in the Python analyzer
+	margin := (maxY - minY) * 0.1
+	if margin == 0 {
+		margin = math.Max(math.Abs(maxY)*0.01, 0.1)
+	}
+
+	return minX, maxX, minY - margin, maxY + margin
+}
+
+// ticks returns round tick positions covering [lo, hi]
+func ticks(lo, hi float64) []float64 {
+	step := math.Pow(10, math.Floor(math.Log10((hi-lo)/5)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if (hi-lo)/(step*m) <= 6 {
+			step *= m
+			break
+		}
+	}
+
+	var result []float64
+	for t := math.Ceil(lo/step) * step; t <= hi; t += step {
+		result = append(result, t)
+	}
+
+	return result
+}
+
+// formatTick renders a number compactly
+func formatTick(v float64) string {
+	if math.Abs(v) < 1e-9 {
+		return "0"
+	}
+	if math.Abs(v) >= 10000 {
+		return fmt.Sprintf("%.0f", v)
+	}
+
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.4f", v), "0"), ".")
+}
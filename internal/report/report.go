@@ -0,0 +1,362 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/stats"
+)
+
+// Input is everything a report is built from
+type Input struct {
+	Title   string
+	Sources []string            // Files the data was read from
+	Results []experiment.Result // Result rows
+	Traces  []Trace             // Receipt latencies recovered from trace files
+}
+
+// Trace holds the first receipt latencies of one traced message
+type Trace struct {
+	Name      string
+	Latencies []float64 // Milliseconds, ascending
+}
+
+// broadcastSummary is a row of the overview table
+type broadcastSummary struct {
+	Broadcast     string
+	Runs          int
+	DuplicateRate float64
+	ReceivingRate float64
+	LatencyP99    float64
+	LatencyRuns   int // Runs that reported latency
+}
+
+// section is a titled group of charts
+type section struct {
+	Title  string
+	Note   string
+	Charts []template.HTML
+}
+
+// Write renders a self-contained HTML report with inline SVG charts
+func Write(w io.Writer, in Input) error {
+	if in.Title == "" {
+		in.Title = "Broadcast report"
+	}
+
+	data := struct {
+		Input
+		Overview []broadcastSummary
+		Sections []section
+	}{Input: in, Overview: overview(in.Results)}
+
+	data.Sections = append(data.Sections,
+		byBroadcast(in.Results),
+		byNodeCount(in.Results),
+		byLevel(in.Results),
+		latency(in.Results, in.Traces),
+	)
+
+	return page.Execute(w, data)
+}
+
+// broadcasts returns the distinct broadcast names of rows in sort_key order
+func broadcasts(rows []experiment.Result) []string {
+	seen := make(map[string]bool)
+	var names []string
+
+	for _, row := range rows {
+		if !seen[row.Broadcast] {
+			seen[row.Broadcast] = true
+			names = append(names, row.Broadcast)
+		}
+	}
+
+	sort.Slice(names, func(a, b int) bool {
+		if ka, kb := p2p.SortKey(names[a]), p2p.SortKey(names[b]); ka != kb {
+			return ka < kb
+		}
+		return names[a] < names[b]
+	})
+
+	return names
+}
+
+// overview averages every metric per broadcast type
+func overview(rows []experiment.Result) []broadcastSummary {
+	var summaries []broadcastSummary
+
+	for _, name := range broadcasts(rows) {
+		s := broadcastSummary{Broadcast: name}
+		for _, row := range rows {
+			if row.Broadcast == name {
+				s.Runs++
+				s.DuplicateRate += row.DuplicateRate
+				s.ReceivingRate += row.ReceivingRate * 100
+				if row.LatencyP99 > 0 {
+					s.LatencyP99 += row.LatencyP99
+					s.LatencyRuns++
+				}
+			}
+		}
+
+		s.DuplicateRate /= float64(s.Runs)
+		s.ReceivingRate /= float64(s.Runs)
+		if s.LatencyRuns > 0 {
+			s.LatencyP99 /= float64(s.LatencyRuns)
+		}
+		summaries = append(summaries, s)
+	}
+
+	return summaries
+}
+
+// meanBy averages a metric over rows grouped by a series key and an x value
+func meanBy(rows []experiment.Result, series func(experiment.Result) (string, bool), x func(experiment.Result) float64, y func(experiment.Result) float64) map[string][]Point {
+	type cell struct {
+		sum   float64
+		count int
+	}
+	cells := make(map[string]map[float64]*cell)
+
+	for _, row := range rows {
+		name, ok := series(row)
+		if !ok {
+			continue
+		}
+
+		if cells[name] == nil {
+			cells[name] = make(map[float64]*cell)
+		}
+		c := cells[name][x(row)]
+		if c == nil {
+			c = &cell{}
+			cells[name][x(row)] = c
+		}
+
+		c.sum += y(row)
+		c.count++
+	}
+
+	points := make(map[string][]Point, len(cells))
+	for name, byX := range cells {
+		for xv, c := range byX {
+			points[name] = append(points[name], Point{X: xv, Y: c.sum / float64(c.count)})
+		}
+
+		sort.Slice(points[name], func(a, b int) bool {
+			return points[name][a].X < points[name][b].X
+		})
+	}
+
+	return points
+}
+
+// duplicateRate and receivingRate extract the plotted metrics (receiving rate in percent)
+func duplicateRate(r experiment.Result) float64 { return r.DuplicateRate }
+func receivingRate(r experiment.Result) float64 { return r.ReceivingRate * 100 }
+
+// byBroadcast reproduces the Python analyzer's charts: metrics per broadcast method, one line per delay
+func byBroadcast(rows []experiment.Result) section {
+	names := broadcasts(rows)
+	index := make(map[string]float64, len(names))
+	for i, name := range names {
+		index[name] = float64(i)
+	}
+
+	series := func(r experiment.Result) (string, bool) { return fmt.Sprintf("Delay %dms", r.Delay), true }
+	x := func(r experiment.Result) float64 { return index[r.Broadcast] }
+
+	chart := func(title, label string, y func(experiment.Result) float64) *Chart {
+		c := &Chart{Title: title, XLabel: "Broadcast Method", YLabel: label, Categories: names}
+		c.Series = sortedSeries(meanBy(rows, series, x, y), delayOrder)
+		return c
+	}
+
+	return section{
+		Title: "By broadcast method",
+		Note:  "The duplicate rate is the number of duplicate receptions per reached node.",
+		Charts: render(
+			chart("Average Duplicate Rate by Broadcast Method", "Average Duplicate Rate", duplicateRate),
+			chart("Average Receiving Rate by Broadcast Method", "Average Receiving Rate (%)", receivingRate),
+		),
+	}
+}
+
+// byNodeCount plots metrics against the network size, one line per broadcast type
+func byNodeCount(rows []experiment.Result) section {
+	series := func(r experiment.Result) (string, bool) { return r.Broadcast, true }
+	x := func(r experiment.Result) float64 { return float64(r.NodeCount) }
+
+	chart := func(title, label string, y func(experiment.Result) float64) *Chart {
+		c := &Chart{Title: title, XLabel: "Node Count", YLabel: label}
+		c.Series = sortedSeries(meanBy(rows, series, x, y), p2p.SortKey)
+		return c
+	}
+
+	return section{
+		Title: "By node count",
+		Charts: render(
+			chart("Duplicate Rate vs Node Count", "Average Duplicate Rate", duplicateRate),
+			chart("Receiving Rate vs Node Count", "Average Receiving Rate (%)", receivingRate),
+		),
+	}
+}
+
+// byLevel plots WavePublish metrics against the level, one line per node count
+// BasicPublish behaves like WavePublish-100 and is not shown
+func byLevel(rows []experiment.Result) section {
+	series := func(r experiment.Result) (string, bool) {
+		bt, err := p2p.ParseBroadcastType(r.Broadcast)
+		return fmt.Sprintf("%d nodes", r.NodeCount), err == nil && bt.Type == p2p.WavePublish
+	}
+	x := func(r experiment.Result) float64 {
+		bt, _ := p2p.ParseBroadcastType(r.Broadcast)
+		return float64(bt.Level)
+	}
+
+	chart := func(title, label string, y func(experiment.Result) float64) *Chart {
+		c := &Chart{Title: title, XLabel: "WavePublish Level", YLabel: label}
+		c.Series = sortedSeries(meanBy(rows, series, x, y), nodeCountOrder)
+		return c
+	}
+
+	return section{
+		Title: "By WavePublish level",
+		Charts: render(
+			chart("Duplicate Rate vs Level", "Average Duplicate Rate", duplicateRate),
+			chart("Receiving Rate vs Level", "Average Receiving Rate (%)", receivingRate),
+		),
+	}
+}
+
+// latency plots the distribution of p99 latency across runs and the receipt latency CDF of traced messages
+func latency(rows []experiment.Result, traces []Trace) section {
+	s := section{Title: "Latency"}
+
+	runs := &Chart{Title: "CDF of p99 Latency across Runs", XLabel: "p99 Latency (ms)", YLabel: "Fraction of Runs", Step: true}
+	for _, name := range broadcasts(rows) {
+		var values []float64
+		for _, row := range rows {
+			if row.Broadcast == name && row.LatencyP99 > 0 {
+				values = append(values, row.LatencyP99)
+			}
+		}
+
+		if len(values) > 0 {
+			runs.Series = append(runs.Series, Series{Name: name, Points: cdf(stats.Sorted(values))})
+		}
+	}
+
+	if len(rows) > 0 && len(runs.Series) == 0 {
+		s.Note = "The result rows carry no latency metrics; they were written by an older version."
+	}
+
+	receipts := &Chart{Title: "CDF of First Receipt Latency", XLabel: "Latency (ms)", YLabel: "Fraction of Reached Nodes", Step: true}
+	for _, t := range traces {
+		receipts.Series = append(receipts.Series, Series{Name: t.Name, Points: cdf(t.Latencies)})
+	}
+
+	s.Charts = render(runs, receipts)
+
+	return s
+}
+
+// render converts charts that have data to inline SVG
+func render(charts ...*Chart) []template.HTML {
+	var rendered []template.HTML
+	for _, c := range charts {
+		if len(c.Series) > 0 {
+			rendered = append(rendered, template.HTML(c.SVG()))
+		}
+	}
+
+	return rendered
+}
+
+// cdf converts sorted values into the points of an empirical CDF
+func cdf(sorted []float64) []Point {
+	points := make([]Point, 0, len(sorted)+1)
+	if len(sorted) > 0 {
+		points = append(points, Point{X: sorted[0], Y: 0})
+	}
+
+	for i, v := range sorted {
+		points = append(points, Point{X: v, Y: float64(i+1) / float64(len(sorted))})
+	}
+
+	return points
+}
+
+// sortedSeries orders series by a key derived from their names
+func sortedSeries(points map[string][]Point, key func(string) int) []Series {
+	series := make([]Series, 0, len(points))
+	for name, p := range points {
+		series = append(series, Series{Name: name, Points: p})
+	}
+
+	sort.Slice(series, func(a, b int) bool {
+		if ka, kb := key(series[a].Name), key(series[b].Name); ka != kb {
+			return ka < kb
+		}
+		return series[a].Name < series[b].Name
+	})
+
+	return series
+}
+
+// delayOrder and nodeCountOrder sort series labelled "Delay Nms" and "N nodes" numerically
+func delayOrder(name string) int {
+	var v int
+	fmt.Sscanf(name, "Delay %dms", &v)
+	return v
+}
+
+func nodeCountOrder(name string) int {
+	var v int
+	fmt.Sscanf(name, "%d nodes", &v)
+	return v
+}
+
+// page is the report layout; charts are inline so the file has no external dependencies
+var page = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.charts { display: flex; flex-wrap: wrap; gap: 1em; }
+.note { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="note">{{len .Results}} result rows{{if .Traces}}, {{len .Traces}} traced messages{{end}} from {{range $i, $s := .Sources}}{{if $i}}, {{end}}{{$s}}{{end}}</p>
+{{if .Overview}}
+<h2>Overview</h2>
+<table>
+<tr><th>Broadcast</th><th>Runs</th><th>Avg Duplicate Rate</th><th>Avg Receiving Rate</th><th>Avg p99 Latency</th></tr>
+{{range .Overview}}<tr><td>{{.Broadcast}}</td><td>{{.Runs}}</td><td>{{printf "%.2f" .DuplicateRate}}</td><td>{{printf "%.4f" .ReceivingRate}}%</td><td>{{if .LatencyRuns}}{{printf "%.1f" .LatencyP99}} ms{{else}}-{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+{{range .Sections}}{{if .Charts}}
+<h2>{{.Title}}</h2>
+{{if .Note}}<p class="note">{{.Note}}</p>{{end}}
+<div class="charts">
+{{range .Charts}}{{.}}
+{{end}}</div>
+{{else if .Note}}
+<h2>{{.Title}}</h2>
+<p class="note">{{.Note}}</p>
+{{end}}{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,295 @@
+package score
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// Status is what a node does with a peer according to its score, from least to most restrictive
+type Status uint8
+
+// Peer statuses, ordered by the thresholds that select them
+const (
+	Normal        Status = iota // Treated like any other peer
+	Deprioritized               // Picked last by protocols that forward to a subset of peers
+	Pruned                      // No longer sent messages
+	Graylisted                  // No longer sent messages, and messages from it are ignored
+)
+
+// String returns the lower-case name of the status
+func (s Status) String() string {
+	switch s {
+	case Normal:
+		return "normal"
+	case Deprioritized:
+		return "deprioritized"
+	case Pruned:
+		return "pruned"
+	case Graylisted:
+		return "graylisted"
+	default:
+		return "unknown"
+	}
+}
+
+// Function computes the score of a peer from the counters a node keeps about it
+type Function func(c Counters) float64
+
+// Params configure the score function and the thresholds protocols act on
+// The weighted score follows GossipSub v1.1: a capped reward for first deliveries and squared
+// penalties for a delivery deficit, invalid messages and duplicate excess
+type Params struct {
+	FirstWeight       float64   `json:"first_weight"`       // Reward per message the peer delivered first
+	FirstCap          float64   `json:"first_cap"`          // Cap on the first deliveries rewarded
+	DeliveryThreshold float64   `json:"delivery_threshold"` // Share of messages a peer is expected to deliver in time
+	DeliveryWeight    float64   `json:"delivery_weight"`    // Weight of the squared relative deficit below the threshold
+	DeliveryWindow    p2p.Delay `json:"delivery_window_ms"` // Copies arriving later after the node's first receipt are not in time
+	Warmup            int       `json:"warmup"`             // Messages observed on a link before its deficit counts
+	InvalidWeight     float64   `json:"invalid_weight"`     // Weight of the squared invalid messages
+	DuplicateWeight   float64   `json:"duplicate_weight"`   // Weight of the squared duplicate excess
+	Decay             float64   `json:"decay"`              // Factor applied to every counter after each message
+
+	DeprioritizeThreshold float64 `json:"deprioritize_threshold"` // Peers scoring below are deprioritized
+	PruneThreshold        float64 `json:"prune_threshold"`        // Peers scoring below are pruned
+	GraylistThreshold     float64 `json:"graylist_threshold"`     // Peers scoring below are graylisted
+
+	Function Function `json:"-"` // Custom score function (nil uses Weighted)
+}
+
+// DefaultParams returns the score parameters used when an experiment does not configure them
+func DefaultParams() Params {
+	return Params{
+		FirstWeight:       1,
+		FirstCap:          10,
+		DeliveryThreshold: 0.1,
+		DeliveryWeight:    -20,
+		DeliveryWindow:    200,
+		Warmup:            5,
+		InvalidWeight:     -10,
+		DuplicateWeight:   -1,
+		Decay:             0.9,
+
+		DeprioritizeThreshold: 0,
+		PruneThreshold:        -5,
+		GraylistThreshold:     -50,
+	}
+}
+
+// UnmarshalJSON fills the parameters missing from data with DefaultParams
+func (p *Params) UnmarshalJSON(data []byte) error {
+	type plain Params
+	params := plain(DefaultParams())
+
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+
+	*p = Params(params)
+	return nil
+}
+
+// Validate checks the decay and the order of the thresholds
+func (p Params) Validate() error {
+	if p.Decay < 0 || p.Decay > 1 {
+		return fmt.Errorf("score decay %v must be between 0 and 1", p.Decay)
+	}
+	if p.GraylistThreshold > p.PruneThreshold || p.PruneThreshold > p.DeprioritizeThreshold {
+		return fmt.Errorf("score thresholds must satisfy graylist <= prune <= deprioritize")
+	}
+
+	return nil
+}
+
+// Counters is what a node knows about one of its peers
+// All counters but Observed decay after every message, so past behavior is gradually forgiven
+type Counters struct {
+	Observed   int     // Messages observed on the link
+	Messages   float64 // Messages the protocol had the peer send the node
+	Deliveries float64 // Messages the peer delivered within the delivery window
+	First      float64 // Messages the peer delivered first
+	Invalid    float64 // Invalid copies received from the peer
+	Duplicates float64 // Copies of a message beyond the first from the peer
+}
+
+// Delivery is what a node saw from one of its peers during a single message
+type Delivery struct {
+	Received bool   // The node received the message from anyone
+	Expected bool   // The protocol had the peer send the node a copy, so a missing or late one is a deficit
+	Copies   int    // Valid copies received from the peer
+	First    bool   // The peer delivered the node's first copy
+	Lag      uint64 // Time from the node's first receipt to the peer's first copy in milliseconds
+	Invalid  int    // Invalid copies received from the peer
+}
+
+// Weighted is the default score function
+func (p Params) Weighted(c Counters) float64 {
+	score := p.FirstWeight * math.Min(c.First, p.FirstCap)
+
+	if c.Observed >= p.Warmup && c.Messages > 0 && p.DeliveryThreshold > 0 {
+		if rate := c.Deliveries / c.Messages; rate < p.DeliveryThreshold {
+			deficit := (p.DeliveryThreshold - rate) / p.DeliveryThreshold
+			score += p.DeliveryWeight * deficit * deficit
+		}
+	}
+
+	score += p.InvalidWeight * c.Invalid * c.Invalid
+	score += p.DuplicateWeight * c.Duplicates * c.Duplicates
+
+	return score
+}
+
+// Score computes the score of a peer with the configured function
+func (p Params) Score(c Counters) float64 {
+	if p.Function != nil {
+		return p.Function(c)
+	}
+
+	return p.Weighted(c)
+}
+
+// Status returns the status a score selects
+func (p Params) Status(score float64) Status {
+	switch {
+	case score < p.GraylistThreshold:
+		return Graylisted
+	case score < p.PruneThreshold:
+		return Pruned
+	case score < p.DeprioritizeThreshold:
+		return Deprioritized
+	default:
+		return Normal
+	}
+}
+
+// Table holds the scores every node keeps about its peers, one entry per arc of a CSR adjacency
+// The entry of arc i -> j is node i's view of peer j. Statuses only change in Update, between
+// messages, so protocols may read them concurrently while a message propagates
+type Table struct {
+	Params   Params
+	offsets  []int64 // Start of each node's arcs
+	peers    []int32 // Peer of each arc, sorted within every node
+	counters []Counters
+	scores   []float64
+	status   []Status
+}
+
+// NewTable creates a table with neutral scores for the arcs of a CSR adjacency
+// The slices are shared, not copied
+func NewTable(offsets []int64, peers []int32, params Params) *Table {
+	t := &Table{
+		Params:   params,
+		offsets:  offsets,
+		peers:    peers,
+		counters: make([]Counters, len(peers)),
+		scores:   make([]float64, len(peers)),
+		status:   make([]Status, len(peers)),
+	}
+	t.Update()
+
+	return t
+}
+
+// Arc returns the arc of node i to peer j, or -1 if they are not connected
+func (t *Table) Arc(i, j int32) int64 {
+	start, end := t.offsets[i], t.offsets[i+1]
+	row := t.peers[start:end]
+
+	k := sort.Search(len(row), func(k int) bool {
+		return row[k] >= j
+	})
+	if k < len(row) && row[k] == j {
+		return start + int64(k)
+	}
+
+	return -1
+}
+
+// At returns the status of the peer at an arc
+func (t *Table) At(arc int64) Status {
+	if t == nil {
+		return Normal
+	}
+
+	return t.status[arc]
+}
+
+// Status returns how node i treats peer j (Normal if they are not connected)
+func (t *Table) Status(i, j int32) Status {
+	if t == nil {
+		return Normal
+	}
+
+	arc := t.Arc(i, j)
+	if arc < 0 {
+		return Normal
+	}
+
+	return t.status[arc]
+}
+
+// Score returns the current score of the peer at an arc
+func (t *Table) Score(arc int64) float64 {
+	return t.scores[arc]
+}
+
+// Counters returns the counters of the peer at an arc
+func (t *Table) Counters(arc int64) Counters {
+	return t.counters[arc]
+}
+
+// Observe records what a node saw from the peer at an arc during one message
+func (t *Table) Observe(arc int64, d Delivery) {
+	c := &t.counters[arc]
+
+	c.Invalid += float64(d.Invalid)
+	if !d.Received {
+		return
+	}
+
+	c.Observed++
+	if d.Expected {
+		c.Messages++
+	}
+	if d.Copies == 0 {
+		return
+	}
+
+	if d.First {
+		c.First++
+	}
+	if d.Expected && d.Lag <= uint64(t.Params.DeliveryWindow) {
+		c.Deliveries++
+	}
+	c.Duplicates += float64(d.Copies - 1)
+}
+
+// Update recomputes scores and statuses from the observed counters, then decays the counters
+// It is called once after every message
+func (t *Table) Update() {
+	for k := range t.counters {
+		t.scores[k] = t.Params.Score(t.counters[k])
+		t.status[k] = t.Params.Status(t.scores[k])
+
+		c := &t.counters[k]
+		c.Messages *= t.Params.Decay
+		c.Deliveries *= t.Params.Decay
+		c.First *= t.Params.Decay
+		c.Invalid *= t.Params.Decay
+		c.Duplicates *= t.Params.Decay
+	}
+}
+
+// Each calls fn with every arc whose status is at least min
+func (t *Table) Each(min Status, fn func(i, j int32, status Status)) {
+	for i := 0; i+1 < len(t.offsets); i++ {
+		for k := t.offsets[i]; k < t.offsets[i+1]; k++ {
+			if t.status[k] >= min {
+				fn(int32(i), t.peers[k], t.status[k])
+			}
+		}
+	}
+}
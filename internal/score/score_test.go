@@ -0,0 +1,209 @@
+package score
+
+import (
+	"math"
+	"testing"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// pair returns a table for two connected nodes; arc 0 is node 0's view of node 1
+func pair(params Params) *Table {
+	return NewTable([]int64{0, 1, 2}, []int32{1, 0}, params)
+}
+
+func TestWeighted(t *testing.T) {
+	p := DefaultParams()
+
+	tests := []struct {
+		name string
+		c    Counters
+		want float64
+	}{
+		{"neutral", Counters{}, 0},
+		{"first deliveries", Counters{First: 4}, 4},
+		{"first deliveries capped", Counters{First: 15}, 10},
+		{"deficit during warmup", Counters{Observed: 4, Messages: 4}, 0},
+		{"full deficit", Counters{Observed: 5, Messages: 5}, -20},
+		{"half deficit", Counters{Observed: 5, Messages: 5, Deliveries: 0.25}, -5},
+		{"at the delivery threshold", Counters{Observed: 5, Messages: 5, Deliveries: 0.5}, 0},
+		{"nothing expected", Counters{Observed: 10}, 0},
+		{"invalid", Counters{Invalid: 2}, -40},
+		{"duplicates", Counters{Duplicates: 3}, -9},
+		{"combined", Counters{Observed: 8, Messages: 8, Deliveries: 8, First: 3, Duplicates: 2}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Weighted(tt.c); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	p.Function = func(c Counters) float64 { return -c.First }
+	if got := p.Score(Counters{First: 2}); got != -2 {
+		t.Errorf("custom function: got %v, want -2", got)
+	}
+}
+
+func TestStatusThresholds(t *testing.T) {
+	p := DefaultParams()
+
+	tests := []struct {
+		score float64
+		want  Status
+	}{
+		{5, Normal},
+		{0, Normal},
+		{-0.1, Deprioritized},
+		{-5, Deprioritized},
+		{-5.1, Pruned},
+		{-50, Pruned},
+		{-50.1, Graylisted},
+	}
+	for _, tt := range tests {
+		if got := p.Status(tt.score); got != tt.want {
+			t.Errorf("score %v: got %v, want %v", tt.score, got, tt.want)
+		}
+	}
+
+	if err := p.Validate(); err != nil {
+		t.Errorf("default parameters rejected: %v", err)
+	}
+
+	invalid := map[string]func(*Params){
+		"prune above deprioritize": func(p *Params) { p.PruneThreshold = 1 },
+		"graylist above prune":     func(p *Params) { p.GraylistThreshold = -1 },
+		"negative decay":           func(p *Params) { p.Decay = -0.1 },
+		"decay above 1":            func(p *Params) { p.Decay = 1.5 },
+	}
+	for name, change := range invalid {
+		p := DefaultParams()
+		change(&p)
+		if p.Validate() == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestObserve(t *testing.T) {
+	tests := []struct {
+		name string
+		d    Delivery
+		want Counters
+	}{
+		{"unreached", Delivery{Invalid: 2}, Counters{Invalid: 2}},
+		{"not expected", Delivery{Received: true}, Counters{Observed: 1}},
+		{"expected but missing", Delivery{Received: true, Expected: true}, Counters{Observed: 1, Messages: 1}},
+		{"in time", Delivery{Received: true, Expected: true, Copies: 1, Lag: 200}, Counters{Observed: 1, Messages: 1, Deliveries: 1}},
+		{"late", Delivery{Received: true, Expected: true, Copies: 1, Lag: 201}, Counters{Observed: 1, Messages: 1}},
+		{"first with duplicates", Delivery{Received: true, Expected: true, Copies: 3, First: true}, Counters{Observed: 1, Messages: 1, Deliveries: 1, First: 1, Duplicates: 2}},
+		{"unexpected copy", Delivery{Received: true, Copies: 1, First: true}, Counters{Observed: 1, First: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := pair(DefaultParams())
+			table.Observe(0, tt.d)
+
+			if got := table.Counters(0); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if got := table.Counters(1); got != (Counters{}) {
+				t.Errorf("other arc changed to %+v", got)
+			}
+		})
+	}
+}
+
+func TestUpdateDecay(t *testing.T) {
+	table := pair(DefaultParams())
+
+	table.Observe(0, Delivery{Received: true, Expected: true, Copies: 2, First: true, Invalid: 1})
+	table.Update()
+
+	// The score uses the counters before they decay
+	if got := table.Score(0); got != 1-10-1 {
+		t.Errorf("got score %v, want -10", got)
+	}
+	if got := table.At(0); got != Pruned {
+		t.Errorf("got status %v, want %v", got, Pruned)
+	}
+
+	want := Counters{Observed: 1, Messages: 0.9, Deliveries: 0.9, First: 0.9, Invalid: 0.9, Duplicates: 0.9}
+	if got := table.Counters(0); got != want {
+		t.Errorf("got counters %+v, want %+v", got, want)
+	}
+
+	// Past misbehavior is forgiven as the counters decay: the score after update n is
+	// f - 11f^2 with f = 0.9^(n-1), which rises above the prune threshold at update 5
+	updates := 1
+	for ; table.At(0) == Pruned; updates++ {
+		table.Update()
+	}
+	if updates != 5 {
+		t.Errorf("deprioritized after %d updates, want 5", updates)
+	}
+	if table.Score(0) >= 0 || table.At(0) != Deprioritized {
+		t.Errorf("got score %v and status %v, want a negative score and %v", table.Score(0), table.At(0), Deprioritized)
+	}
+
+	if got := table.Status(0, 1); got != table.At(0) {
+		t.Errorf("Status(0, 1) = %v, want %v", got, table.At(0))
+	}
+	if got := table.Status(1, 0); got != Normal {
+		t.Errorf("Status(1, 0) = %v, want %v", got, Normal)
+	}
+}
+
+func TestAdversaryRoles(t *testing.T) {
+	// Each role is modelled by what a node sees from it for message k, as the simulator observes it
+	tests := []struct {
+		role     p2p.Role
+		delivery func(k int) Delivery
+		sign     float64
+		want     Status
+	}{
+		{p2p.Honest, func(k int) Delivery {
+			return Delivery{Received: true, Expected: true, Copies: 1, First: k%3 == 0, Lag: 20}
+		}, 1, Normal},
+		// A censor of one origin among ten still delivers above the threshold and goes unnoticed
+		{p2p.Censor, func(k int) Delivery {
+			if k%10 == 0 {
+				return Delivery{Received: true, Expected: true}
+			}
+			return Delivery{Received: true, Expected: true, Copies: 1, First: k%3 == 0, Lag: 20}
+		}, 1, Normal},
+		{p2p.Dropper, func(k int) Delivery {
+			return Delivery{Received: true, Expected: true}
+		}, -1, Pruned},
+		{p2p.Delayer, func(k int) Delivery {
+			return Delivery{Received: true, Expected: true, Copies: 1, Lag: 1000}
+		}, -1, Pruned},
+		{p2p.Spammer, func(k int) Delivery {
+			return Delivery{Received: true, Expected: true, Copies: 2, First: true}
+		}, -1, Graylisted},
+		{p2p.Forger, func(k int) Delivery {
+			return Delivery{Received: true, Expected: true, Invalid: 1}
+		}, -1, Graylisted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role.String(), func(t *testing.T) {
+			table := pair(DefaultParams())
+
+			for k := range 30 {
+				table.Observe(0, tt.delivery(k))
+				table.Update()
+			}
+
+			if got := table.Score(0); got*tt.sign <= 0 {
+				t.Errorf("got score %v, want its sign to be %v", got, tt.sign)
+			}
+			if got := table.At(0); got != tt.want {
+				t.Errorf("got status %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
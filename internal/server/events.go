@@ -0,0 +1,160 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/sim"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
+)
+
+// maxEvents bounds the events kept per run; later ones are counted but not kept
+const maxEvents = 1 << 20
+
+// eventLog holds the broadcast events of a run, replayed to streams and followed live
+// Real-time engines append events as the nodes record them, timed from the start of the run;
+// the virtual-time engines append them all at the end, timed in virtual time
+type eventLog struct {
+	mu      sync.Mutex
+	start   time.Time
+	events  []trace.Event
+	dropped int           // Events beyond maxEvents
+	closed  bool          // No more events will be appended
+	wake    chan struct{} // Closed on the next append or close, created by waiters
+}
+
+// begin sets the time events are timed from
+func (l *eventLog) begin(start time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.start = start
+}
+
+// Record timestamps and appends an event
+func (l *eventLog) Record(e trace.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Time = int64(time.Since(l.start))
+	l.append(e)
+}
+
+// append adds an event, waking up waiting streams; the caller holds the lock
+func (l *eventLog) append(e trace.Event) {
+	if l.closed {
+		return
+	}
+	if len(l.events) >= maxEvents {
+		l.dropped++
+		return
+	}
+
+	l.events = append(l.events, e)
+	l.notify()
+}
+
+// add appends events that are already timed
+func (l *eventLog) add(events []trace.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, e := range events {
+		l.append(e)
+	}
+}
+
+// count returns the number of events kept and of those beyond maxEvents
+func (l *eventLog) count() (int, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.events), l.dropped
+}
+
+// close marks the log complete
+func (l *eventLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	l.notify()
+}
+
+// notify wakes up waiting streams; the caller holds the lock
+func (l *eventLog) notify() {
+	if l.wake != nil {
+		close(l.wake)
+		l.wake = nil
+	}
+}
+
+// since returns the events from index i on, whether the log is complete with them,
+// and a channel closed once there is more to read
+func (l *eventLog) since(i int) ([]trace.Event, bool, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.wake == nil {
+		l.wake = make(chan struct{})
+	}
+
+	// Appends never touch the elements already in the log, so the slice is read without the lock
+	return l.events[min(i, len(l.events)):], l.closed, l.wake
+}
+
+// Record dispatches an event recorded by a node of the network to the run broadcasting its message
+func (e *entry) Record(ev trace.Event) {
+	e.logsMu.RLock()
+	rec := e.logs[ev.Message]
+	e.logsMu.RUnlock()
+
+	if rec != nil {
+		rec.Record(ev)
+	}
+}
+
+// track routes the events of a message to a run's recorder until untracked
+func (e *entry) track(mid p2p.MessageID, rec trace.Recorder) {
+	e.logsMu.Lock()
+	defer e.logsMu.Unlock()
+
+	e.logs[mid] = rec
+}
+
+// untrack stops routing the events of a message
+func (e *entry) untrack(mid p2p.MessageID) {
+	e.logsMu.Lock()
+	defer e.logsMu.Unlock()
+
+	delete(e.logs, mid)
+}
+
+// simEvents rebuilds the publication and first receipts of a simulated broadcast as events in virtual time
+// The simulation does not keep its transmissions and duplicates, so they are missing
+func simEvents(r *sim.Result, mid p2p.MessageID) []trace.Event {
+	events := []trace.Event{{Kind: trace.Publish, Message: mid, From: p2p.NodeID(r.Origin), To: p2p.NodeID(r.Origin)}}
+
+	for i, t := range r.FirstReceipt {
+		if int32(i) == r.Origin || r.Parent[i] < 0 {
+			continue // The origin, or unreached
+		}
+
+		events = append(events, trace.Event{
+			Kind:    trace.Receive,
+			Time:    int64(t) * int64(time.Millisecond),
+			Message: mid,
+			From:    p2p.NodeID(r.Parent[i]),
+			To:      p2p.NodeID(i),
+			Hop:     int(r.Hop[i]),
+		})
+	}
+
+	sort.SliceStable(events, func(a, b int) bool {
+		return events[a].Time < events[b].Time
+	})
+
+	return events
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/emulate"
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/monitor"
+	"github.com/elecbug/p2p-broadcast-tester/internal/node"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/sim"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
+)
+
+// checkEngine rejects unknown engines and transports
+func checkEngine(engine experiment.Engine) error {
+	switch engine.Type {
+	case "", experiment.EngineRealtime, experiment.EnginePool, experiment.EngineSim, experiment.EngineParallel:
+	case experiment.EngineSocket:
+		switch engine.Transport {
+		case "", emulate.TCP, emulate.UDP:
+		default:
+			return fmt.Errorf("unknown transport %q", engine.Transport)
+		}
+	default:
+		return fmt.Errorf("unknown engine %q", engine.Type)
+	}
+
+	return nil
+}
+
+// execute waits for a free slot, broadcasts and records the outcome of a run
+func (r *Registry) execute(ru *run) {
+	defer r.wg.Done()
+
+	r.slots <- struct{}{}
+	defer func() { <-r.slots }()
+
+	tracker := r.monitor.Start()
+	start := time.Now()
+	ru.events.begin(start)
+	ru.mu.Lock()
+	ru.info.Status = Running
+	ru.info.StartedAt = timestamp(start)
+	ru.mu.Unlock()
+
+	metric, virtual, err := ru.broadcast(tracker)
+	end := time.Now()
+
+	events, lost := ru.events.count()
+
+	ru.mu.Lock()
+	ru.info.FinishedAt = timestamp(end)
+	ru.info.Events, ru.info.EventsLost = events, lost
+	ru.info.WallMs = float64(end.Sub(start)) / float64(time.Millisecond)
+	if err != nil {
+		ru.info.Status = Failed
+		ru.info.Error = err.Error()
+	} else {
+		ru.info.Status = Done
+		ru.info.Reached = metric.Reached
+		ru.info.VirtualMs = float64(virtual)
+		ru.info.Metric = &metric
+	}
+	ru.mu.Unlock()
+	ru.events.close() // After the outcome is recorded, which streams send last
+
+	if err != nil {
+		tracker.Finish(nil, end.Sub(start))
+	} else {
+		tracker.Finish(&metric, end.Sub(start))
+	}
+
+	r.mu.Lock()
+	ru.entry.active--
+	r.mu.Unlock()
+}
+
+// broadcast runs the broadcast of a run with its engine and returns its metric
+// It also returns the virtual duration of the virtual-time engines in milliseconds;
+// the events of the real-time engines go to the run's log and to the tracker
+func (ru *run) broadcast(tracker *monitor.Run) (p2p.NetworkMetric, uint64, error) {
+	e, engine := ru.entry, ru.info.Engine
+
+	if !ru.pointerBased() {
+		g, err := e.csr()
+		if err != nil {
+			return p2p.NetworkMetric{}, 0, err
+		}
+
+		origin := int32(ru.info.Origin)
+		opts := sim.Options{Adversary: e.adversary, Payload: ru.info.PayloadBytes}
+
+		var res *sim.Result
+		if engine.Type == experiment.EngineParallel {
+			res, err = sim.RunParallel(g, origin, ru.bt, g.Config.Seed, sim.ParallelConfig{
+				Workers:   engine.Workers,
+				Lookahead: uint64(g.Config.MinLinkDelay),
+				Options:   opts,
+			})
+			if err != nil {
+				return p2p.NetworkMetric{}, 0, err
+			}
+		} else {
+			res = sim.RunWith(g, origin, ru.bt, g.Config.Seed, opts)
+		}
+		ru.events.add(simEvents(res, ru.mid))
+
+		return res.Metric(g, ru.bt, e.delay), res.Duration, nil
+	}
+
+	origin := &e.network.Nodes[ru.info.Origin]
+	msg := node.Message{ID: ru.mid, Type: ru.bt, Payload: make([]byte, ru.info.PayloadBytes)}
+	lost := int64(0)
+
+	// Every run is a new Dandelion epoch, so it cannot share the nodes with another run
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+	e.network.ResetEpoch()
+
+	e.track(ru.mid, trace.Tee(ru.events, tracker))
+	defer e.untrack(ru.mid)
+
+	switch engine.Type {
+	case experiment.EngineSocket:
+		emu, err := emulate.Start(e.network, emulate.Config{Transport: engine.Transport})
+		if err != nil {
+			return p2p.NetworkMetric{}, 0, err
+		}
+		defer emu.Close()
+
+		lost = emu.Broadcast(origin, msg)
+	case experiment.EnginePool:
+		pool := node.NewPool(engine.Workers, engine.MaxInFlight)
+		origin.PublishWith(pool, msg)
+
+		pool.Close() // Wait for broadcast to complete and stop workers
+	default:
+		wg := &sync.WaitGroup{}
+		origin.PublishWith(&node.GoroutineExecutor{WG: wg}, msg)
+
+		wg.Wait() // Wait for broadcast to complete
+	}
+
+	metric := e.network.Metric(ru.mid, ru.bt, e.delay)
+	metric.Lost = int(lost)
+
+	return metric, 0, nil
+}
+
+// timestamp returns a time in UTC for the optional time fields of RunInfo
+func timestamp(t time.Time) *time.Time {
+	t = t.UTC()
+	return &t
+}
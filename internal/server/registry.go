@@ -0,0 +1,410 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/csr"
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/monitor"
+	"github.com/elecbug/p2p-broadcast-tester/internal/network"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/trace"
+)
+
+// Registry errors, mapped to HTTP status codes by the server
+var (
+	ErrNotFound = errors.New("not found")
+	ErrBusy     = errors.New("network has unfinished runs")
+)
+
+// Run statuses
+const (
+	Queued  = "queued"  // Waiting for a free slot
+	Running = "running" // Broadcasting
+	Done    = "done"    // Finished, with a metric
+	Failed  = "failed"  // Finished with an error
+)
+
+// unsupportedKeys are experiment grid keys the registry does not accept in network parameters
+var unsupportedKeys = map[string]string{
+	"messages":      "peer scoring is not supported by the control API",
+	"scoring":       "peer scoring is not supported by the control API",
+	"payload_bytes": "payload_bytes is set per run",
+}
+
+// NetworkSpec describes a network to create
+type NetworkSpec struct {
+	Generator string             `json:"generator"` // experiment.Generator* (default limit-degree)
+	Path      string             `json:"path"`      // Input file for the file generator, read by the server
+	Format    string             `json:"format"`    // Format of the input file (default snapshot)
+	Params    map[string]float64 `json:"params"`    // Topology, adversary and eclipse keys of experiment grids, one value each
+	Seed      int64              `json:"seed"`      // 0 picks a time-based seed
+}
+
+// NetworkInfo describes a network held by the registry
+type NetworkInfo struct {
+	ID          string             `json:"id"`
+	Generator   string             `json:"generator"`
+	Params      map[string]float64 `json:"params"` // Resolved parameters, defaults included
+	Seed        int64              `json:"seed"`   // Seed the topology was generated with
+	NodeCount   int                `json:"node_count"`
+	AvgDegree   float64            `json:"avg_degree"`
+	Adversaries int                `json:"adversaries,omitempty"` // Nodes with a malicious role
+	Runs        int                `json:"runs"`                  // Runs started on the network
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// RunSpec describes a broadcast to start on a network
+type RunSpec struct {
+	Protocol     string            `json:"protocol"`      // Broadcast type, e.g. BasicPublish, WavePublish-30 or Dandelion-10
+	Origin       *int              `json:"origin"`        // Publishing node (default the network's origin parameter)
+	Engine       experiment.Engine `json:"engine"`        // Execution engine (default realtime)
+	PayloadBytes int               `json:"payload_bytes"` // Payload size of the message
+}
+
+// RunInfo describes a run held by the registry
+type RunInfo struct {
+	ID           string             `json:"id"`
+	Network      string             `json:"network"`
+	Broadcast    string             `json:"broadcast"`
+	Origin       int                `json:"origin"`
+	Engine       experiment.Engine  `json:"engine"`
+	PayloadBytes int                `json:"payload_bytes,omitempty"`
+	Status       string             `json:"status"`
+	Reached      int                `json:"reached"` // Nodes holding the message so far, counted as by the metric's reached
+	NodeCount    int                `json:"node_count"`
+	CreatedAt    time.Time          `json:"created_at"`
+	StartedAt    *time.Time         `json:"started_at,omitempty"`
+	FinishedAt   *time.Time         `json:"finished_at,omitempty"`
+	WallMs       float64            `json:"wall_ms,omitempty"`     // Duration of the broadcast
+	VirtualMs    float64            `json:"virtual_ms,omitempty"`  // Virtual duration (sim and parallel engines only)
+	Metric       *p2p.NetworkMetric `json:"metric,omitempty"`      // Set once done
+	Error        string             `json:"error,omitempty"`       // Set once failed
+	Events       int                `json:"events"`                // Broadcast events recorded for the stream, set once finished
+	EventsLost   int                `json:"events_lost,omitempty"` // Events beyond the per-run limit, missing from the stream
+}
+
+// entry is a network held by the registry
+type entry struct {
+	info      NetworkInfo
+	network   *network.Network
+	adversary *p2p.Adversary
+	delay     int          // Maximum node processing delay, reported in metrics
+	origin    int          // Default origin of runs
+	messages  atomic.Int64 // Last message ID broadcast on the network
+	active    int          // Queued and running runs, guarded by the registry's mutex
+
+	nodesMu sync.Mutex // Held by broadcasts on the pointer-based network, which take turns
+
+	graphOnce sync.Once
+	graph     *csr.Graph // CSR copy for the virtual-time engines, built on first use
+	graphErr  error
+
+	logsMu sync.RWMutex
+	logs   map[p2p.MessageID]trace.Recorder // Recorders of the running broadcasts by message
+}
+
+// run is a broadcast held by the registry
+type run struct {
+	mu     sync.Mutex
+	info   RunInfo
+	entry  *entry
+	mid    p2p.MessageID
+	bt     p2p.BroadcastType
+	events *eventLog
+}
+
+// Registry holds networks and runs in memory and executes runs in the background
+type Registry struct {
+	mu       sync.RWMutex
+	networks map[string]*entry
+	runs     map[string]*run
+	netOrder []string // Network IDs in creation order
+	runOrder []string // Run IDs in creation order
+	lastNet  int
+	lastRun  int
+	slots    chan struct{}    // Concurrent run slots
+	monitor  *monitor.Monitor // Progress and results served at /metrics
+	wg       sync.WaitGroup
+}
+
+// NewRegistry creates an empty registry running up to concurrency broadcasts at a time
+func NewRegistry(concurrency int) *Registry {
+	return &Registry{
+		networks: make(map[string]*entry),
+		runs:     make(map[string]*run),
+		slots:    make(chan struct{}, max(concurrency, 1)),
+		monitor:  monitor.New(),
+	}
+}
+
+// CreateNetwork generates or loads a network from a spec and adds it to the registry
+func (r *Registry) CreateNetwork(spec NetworkSpec) (NetworkInfo, error) {
+	grid := make(map[string]experiment.Values, len(spec.Params))
+	for k, v := range spec.Params {
+		if reason, ok := unsupportedKeys[k]; ok {
+			return NetworkInfo{}, fmt.Errorf("parameter %q: %s", k, reason)
+		}
+		grid[k] = experiment.Values{v}
+	}
+
+	exp := &experiment.Experiment{
+		Name: "serve",
+		Seed: spec.Seed,
+		Topology: experiment.Topology{
+			Generator: spec.Generator,
+			Path:      spec.Path,
+			Format:    spec.Format,
+			Grid:      grid,
+		},
+		Protocols: []experiment.Protocol{{Type: p2p.BasicPublish}},
+	}
+	if err := exp.Validate(); err != nil {
+		return NetworkInfo{}, err
+	}
+
+	base := exp.Expand()[0]
+	base.Seed = exp.Seed // An explicit seed is used for the topology as is
+
+	n, err := exp.BuildNetwork(base)
+	if err != nil {
+		return NetworkInfo{}, err
+	}
+
+	origin := int(base.Params["origin"])
+	if origin < 0 || origin >= len(n.Nodes) {
+		return NetworkInfo{}, fmt.Errorf("origin %d out of range", origin)
+	}
+
+	n, adversary, err := base.AttackNetwork(n)
+	if err != nil {
+		return NetworkInfo{}, err
+	}
+	if adversary != nil {
+		n.SetAdversary(adversary)
+	}
+
+	e := &entry{
+		network:   n,
+		adversary: adversary,
+		delay:     int(base.Params["max_node_delay"]),
+		origin:    origin,
+		logs:      make(map[p2p.MessageID]trace.Recorder),
+		info: NetworkInfo{
+			Generator: exp.Topology.Generator,
+			Params:    base.Params,
+			Seed:      n.Config.Seed,
+			NodeCount: len(n.Nodes),
+			AvgDegree: n.AvgDegree(),
+			CreatedAt: time.Now().UTC(),
+		},
+	}
+	if adversary != nil {
+		e.info.Adversaries = adversary.Count()
+	}
+	n.SetRecorder(e)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastNet++
+	e.info.ID = fmt.Sprintf("n%d", r.lastNet)
+	r.networks[e.info.ID] = e
+	r.netOrder = append(r.netOrder, e.info.ID)
+
+	return e.info, nil
+}
+
+// Network returns a network by ID
+func (r *Registry) Network(id string) (NetworkInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.networks[id]
+	if !ok {
+		return NetworkInfo{}, fmt.Errorf("network %q: %w", id, ErrNotFound)
+	}
+
+	return e.info, nil
+}
+
+// Networks returns every network in creation order
+func (r *Registry) Networks() []NetworkInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]NetworkInfo, 0, len(r.netOrder))
+	for _, id := range r.netOrder {
+		infos = append(infos, r.networks[id].info)
+	}
+
+	return infos
+}
+
+// DeleteNetwork removes a network without unfinished runs; its runs stay listed
+func (r *Registry) DeleteNetwork(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.networks[id]
+	if !ok {
+		return fmt.Errorf("network %q: %w", id, ErrNotFound)
+	}
+	if e.active > 0 {
+		return fmt.Errorf("network %q: %w", id, ErrBusy)
+	}
+
+	delete(r.networks, id)
+	r.netOrder = slices.DeleteFunc(r.netOrder, func(other string) bool { return other == id })
+
+	return nil
+}
+
+// StartRun queues a broadcast on a network and returns immediately
+func (r *Registry) StartRun(networkID string, spec RunSpec) (RunInfo, error) {
+	bt, err := p2p.ParseBroadcastType(spec.Protocol)
+	if err != nil {
+		return RunInfo{}, err
+	}
+	if err := checkEngine(spec.Engine); err != nil {
+		return RunInfo{}, err
+	}
+	if spec.Engine.Type == "" {
+		spec.Engine.Type = experiment.EngineRealtime
+	}
+	if spec.PayloadBytes < 0 || spec.PayloadBytes > experiment.MaxPayload {
+		return RunInfo{}, fmt.Errorf("payload_bytes %d out of range [0, %d]", spec.PayloadBytes, experiment.MaxPayload)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.networks[networkID]
+	if !ok {
+		return RunInfo{}, fmt.Errorf("network %q: %w", networkID, ErrNotFound)
+	}
+
+	origin := e.origin
+	if spec.Origin != nil {
+		origin = *spec.Origin
+	}
+	if origin < 0 || origin >= e.info.NodeCount {
+		return RunInfo{}, fmt.Errorf("origin %d out of range", origin)
+	}
+
+	r.lastRun++
+	ru := &run{
+		entry:  e,
+		mid:    p2p.MessageID(e.messages.Add(1)),
+		bt:     bt,
+		events: &eventLog{},
+		info: RunInfo{
+			ID:           fmt.Sprintf("r%d", r.lastRun),
+			Network:      networkID,
+			Broadcast:    bt.String(),
+			Origin:       origin,
+			Engine:       spec.Engine,
+			PayloadBytes: spec.PayloadBytes,
+			Status:       Queued,
+			NodeCount:    e.info.NodeCount,
+			CreatedAt:    time.Now().UTC(),
+		},
+	}
+
+	r.runs[ru.info.ID] = ru
+	r.runOrder = append(r.runOrder, ru.info.ID)
+	e.active++
+	e.info.Runs++
+	r.monitor.Queue(1)
+
+	info := ru.info // Copied before the run starts changing it
+
+	r.wg.Add(1)
+	go r.execute(ru)
+
+	return info, nil
+}
+
+// Run returns a run by ID, with the progress of a running broadcast
+func (r *Registry) Run(id string) (RunInfo, error) {
+	r.mu.RLock()
+	ru, ok := r.runs[id]
+	r.mu.RUnlock()
+
+	if !ok {
+		return RunInfo{}, fmt.Errorf("run %q: %w", id, ErrNotFound)
+	}
+
+	return ru.snapshot(), nil
+}
+
+// Runs returns the runs of a network, or of all networks if networkID is empty, in creation order
+// A non-empty status keeps only the runs in that status
+func (r *Registry) Runs(networkID, status string) []RunInfo {
+	r.mu.RLock()
+	runs := make([]*run, 0, len(r.runOrder))
+	for _, id := range r.runOrder {
+		runs = append(runs, r.runs[id])
+	}
+	r.mu.RUnlock()
+
+	infos := make([]RunInfo, 0, len(runs))
+	for _, ru := range runs {
+		info := ru.snapshot()
+		if (networkID == "" || info.Network == networkID) && (status == "" || info.Status == status) {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos
+}
+
+// Wait blocks until every queued and running broadcast has finished
+func (r *Registry) Wait() {
+	r.wg.Wait()
+}
+
+// snapshot returns a copy of the run's info, counting the nodes reached so far while it runs
+func (ru *run) snapshot() RunInfo {
+	ru.mu.Lock()
+	info := ru.info
+	ru.mu.Unlock()
+
+	if info.Status == Running && ru.pointerBased() {
+		info.Reached = ru.reached()
+	}
+
+	return info
+}
+
+// reached counts the nodes of a pointer-based network other than the origin that hold the run's message
+func (ru *run) reached() int {
+	nodes := ru.entry.network.Nodes
+	count := 0
+	for i := range nodes {
+		if _, ok := nodes[i].RelayTime(ru.mid); ok && i != ru.info.Origin {
+			count++
+		}
+	}
+
+	return count
+}
+
+// pointerBased reports whether the run broadcasts on the pointer-based network rather than its CSR copy
+func (ru *run) pointerBased() bool {
+	return ru.info.Engine.Type != experiment.EngineSim && ru.info.Engine.Type != experiment.EngineParallel
+}
+
+// csr returns the CSR copy of a network, building it on first use
+func (e *entry) csr() (*csr.Graph, error) {
+	e.graphOnce.Do(func() {
+		e.graph, e.graphErr = csr.FromNetwork(e.network)
+	})
+
+	return e.graph, e.graphErr
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+)
+
+// web holds the visualizer served at /
+//
+//go:embed web
+var web embed.FS
+
+// maxBody bounds the size of request bodies
+const maxBody = 1 << 20
+
+// Server serves the HTTP/JSON control API of a registry
+//
+//	GET    /networks               list networks
+//	POST   /networks               create a network from a NetworkSpec
+//	GET    /networks/{id}          describe a network
+//	DELETE /networks/{id}          remove a network without unfinished runs
+//	GET    /networks/{id}/topology nodes and links of a network
+//	GET    /networks/{id}/runs     list the runs of a network
+//	POST   /networks/{id}/runs     start a broadcast from a RunSpec
+//	GET    /runs                   list all runs (?network= and ?status= filter them)
+//	GET    /runs/{id}              progress and metric of a run
+//	GET    /runs/{id}/events       events of a run as Server-Sent Events
+//	GET    /                       visualizer animating the events of a run
+type Server struct {
+	registry *Registry
+	mux      *http.ServeMux
+}
+
+// New creates a server for a registry
+func New(registry *Registry) *Server {
+	s := &Server{registry: registry, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("GET /networks", s.listNetworks)
+	s.mux.HandleFunc("POST /networks", s.createNetwork)
+	s.mux.HandleFunc("GET /networks/{id}", s.getNetwork)
+	s.mux.HandleFunc("DELETE /networks/{id}", s.deleteNetwork)
+	s.mux.HandleFunc("GET /networks/{id}/topology", s.getTopology)
+	s.mux.HandleFunc("GET /networks/{id}/runs", s.listNetworkRuns)
+	s.mux.HandleFunc("POST /networks/{id}/runs", s.startRun)
+	s.mux.HandleFunc("GET /runs", s.listRuns)
+	s.mux.HandleFunc("GET /runs/{id}", s.getRun)
+	s.mux.HandleFunc("GET /runs/{id}/events", s.streamEvents)
+	s.mux.Handle("GET /metrics", registry.monitor)
+
+	static, _ := fs.Sub(web, "web")
+	s.mux.Handle("GET /", http.FileServerFS(static))
+
+	return s
+}
+
+// ServeHTTP dispatches a request to its handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) listNetworks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.registry.Networks())
+}
+
+func (s *Server) createNetwork(w http.ResponseWriter, r *http.Request) {
+	var spec NetworkSpec
+	if err := decode(w, r, &spec); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	info, err := s.registry.CreateNetwork(spec)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/networks/"+info.ID)
+	writeJSON(w, http.StatusCreated, info)
+}
+
+func (s *Server) getNetwork(w http.ResponseWriter, r *http.Request) {
+	info, err := s.registry.Network(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) deleteNetwork(w http.ResponseWriter, r *http.Request) {
+	if err := s.registry.DeleteNetwork(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listNetworkRuns(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.registry.Network(id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.registry.Runs(id, r.URL.Query().Get("status")))
+}
+
+func (s *Server) startRun(w http.ResponseWriter, r *http.Request) {
+	var spec RunSpec
+	if err := decode(w, r, &spec); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	info, err := s.registry.StartRun(r.PathValue("id"), spec)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/runs/"+info.ID)
+	writeJSON(w, http.StatusAccepted, info)
+}
+
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	writeJSON(w, http.StatusOK, s.registry.Runs(query.Get("network"), query.Get("status")))
+}
+
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	info, err := s.registry.Run(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// decode reads a JSON request body, rejecting unknown fields
+func decode(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("decoding request: %w", err)
+	}
+
+	return nil
+}
+
+// writeJSON writes a value as an indented JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeError writes an error as {"error": "..."} with the status code matching its kind
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrBusy):
+		status = http.StatusConflict
+	}
+
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
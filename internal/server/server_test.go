@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateNetworkSpecs(t *testing.T) {
+	srv := httptest.NewServer(New(NewRegistry(1)))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"limit-degree", `{"params":{"node_count":50,"mean_degree":4},"seed":1}`, http.StatusCreated},
+		{"random", `{"generator":"random","params":{"node_count":50,"mean_degree":4},"seed":1}`, http.StatusCreated},
+		{"negative nodes", `{"params":{"node_count":-5}}`, http.StatusBadRequest},
+		{"single node", `{"params":{"node_count":1}}`, http.StatusBadRequest},
+		{"degree beyond nodes", `{"generator":"random","params":{"node_count":5,"mean_degree":10}}`, http.StatusBadRequest},
+		{"inverted link delays", `{"params":{"node_count":50,"mean_degree":4,"min_link_delay":9,"max_link_delay":1}}`, http.StatusBadRequest},
+		{"fractions above 1", `{"params":{"node_count":50,"mean_degree":4,"droppers":0.7,"censors":0.7}}`, http.StatusBadRequest},
+		{"victim beyond nodes", `{"params":{"node_count":20,"mean_degree":4,"victims":1,"victim":500}}`, http.StatusBadRequest},
+		{"unknown generator", `{"generator":"ring"}`, http.StatusBadRequest},
+		{"unknown parameter", `{"params":{"nodes":50}}`, http.StatusBadRequest},
+		{"missing file", `{"generator":"file","path":"does/not/exist.snapshot"}`, http.StatusBadRequest},
+		{"invalid JSON", `{"params":`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+"/networks", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.want {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want != http.StatusBadRequest {
+				return
+			}
+
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+				t.Errorf("error body missing (%v)", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// Pacing of event streams
+const (
+	streamInterval  = 20 * time.Millisecond // Events arriving within this interval are sent together
+	streamKeepalive = 15 * time.Second      // Comment sent while a run has no events, so proxies keep the stream open
+)
+
+// Topology is the graph of a network as drawn by the visualizer
+type Topology struct {
+	Nodes []TopologyNode  `json:"nodes"`
+	Links [][2]p2p.NodeID `json:"links"` // Each link once, from the lower node ID
+}
+
+// TopologyNode is a node of a Topology
+type TopologyNode struct {
+	ID   p2p.NodeID `json:"id"`
+	Role string     `json:"role,omitempty"` // Malicious role, empty for honest nodes
+}
+
+// Topology returns the graph of a network
+func (r *Registry) Topology(id string) (Topology, error) {
+	r.mu.RLock()
+	e, ok := r.networks[id]
+	r.mu.RUnlock()
+
+	if !ok {
+		return Topology{}, fmt.Errorf("network %q: %w", id, ErrNotFound)
+	}
+
+	nodes := e.network.Nodes
+	t := Topology{Nodes: make([]TopologyNode, len(nodes))}
+	for i := range nodes {
+		t.Nodes[i].ID = nodes[i].ID()
+		if role := e.adversary.Role(i); role != p2p.Honest {
+			t.Nodes[i].Role = role.String()
+		}
+
+		for conn := range nodes[i].Connections() {
+			if conn.ID() > nodes[i].ID() {
+				t.Links = append(t.Links, [2]p2p.NodeID{nodes[i].ID(), conn.ID()})
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// events returns the event log of a run
+func (r *Registry) events(id string) (*eventLog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ru, ok := r.runs[id]
+	if !ok {
+		return nil, fmt.Errorf("run %q: %w", id, ErrNotFound)
+	}
+
+	return ru.events, nil
+}
+
+func (s *Server) getTopology(w http.ResponseWriter, r *http.Request) {
+	t, err := s.registry.Topology(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+// streamEvents sends the events of a run as Server-Sent Events, from the start or after
+// Last-Event-ID, and follows them live until the run finishes. Each event is named by its
+// kind with the trace event as data; a final "end" event carries the run with its metric
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	log, err := s.registry.events(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	next := 0
+	if last, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil {
+		next = last + 1
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		events, closed, wake := log.since(next)
+		for _, e := range events {
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(bw, "id: %d\nevent: %s\ndata: %s\n\n", next, e.Kind, data)
+			next++
+		}
+
+		if closed {
+			info, _ := s.registry.Run(id)
+			data, _ := json.Marshal(info)
+			fmt.Fprintf(bw, "event: end\ndata: %s\n\n", data)
+		}
+
+		if err := bw.Flush(); err != nil {
+			return // Client gone
+		}
+		flusher.Flush()
+
+		if closed {
+			return
+		}
+
+		select {
+		case <-wake:
+		case <-keepalive.C:
+			bw.WriteString(": keepalive\n\n")
+			continue
+		case <-r.Context().Done():
+			return
+		}
+
+		select {
+		case <-time.After(streamInterval):
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,480 @@
+"use strict";
+
+// Visualizer for the control API: lays out a network with a force-directed simulation and
+// replays the events of a run from /runs/{id}/events, following running broadcasts live
+
+const canvas = document.getElementById("canvas");
+const ctx = canvas.getContext("2d");
+const networkSelect = document.getElementById("network");
+const runSelect = document.getElementById("run");
+const speedInput = document.getElementById("speed");
+
+const WORLD = 1000;       // Side of the square the layout is computed in
+const FLASH_MS = 400;     // How long a transmission stays drawn
+const MAX_LINKS = 30000;  // Larger networks are drawn without their links
+
+const view = {
+  network: null,  // Network info
+  nodes: [],      // {id, role, x, y, dx, dy, hop, origin}
+  links: [],      // [a, b] node indices
+  index: new Map(), // Node ID -> index
+  heat: 0,        // Layout temperature, 0 once settled
+  source: null,   // EventSource of the followed run
+  queue: [],      // Events not yet shown, in arrival order
+  flashes: [],    // Transmissions being drawn {a, b, kind, until}
+  clock: null,    // {wall, trace}: the trace time shown at a wall time, in ms
+  speed: 1,
+  counts: {},
+  traceMs: 0,     // Trace time of the last shown event
+  run: null,      // Run info, updated when the stream ends
+  hover: -1,
+};
+
+// api sends a request to the control API and returns the decoded response
+async function api(path, body) {
+  const init = body === undefined ? {} : {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(body),
+  };
+
+  const res = await fetch(path, init);
+  const data = await res.json();
+  if (!res.ok) {
+    throw new Error(data.error || res.statusText);
+  }
+
+  return data;
+}
+
+function showError(err) {
+  document.getElementById("error").textContent = err ? err.message : "";
+}
+
+// Networks and runs
+
+async function refreshNetworks(select) {
+  const networks = await api("/networks");
+  networkSelect.replaceChildren(...networks.map((n) =>
+    new Option(`${n.id}: ${n.node_count} nodes, degree ${n.avg_degree.toFixed(1)}`, n.id)));
+
+  if (select) {
+    networkSelect.value = select;
+  }
+  if (networkSelect.value && networkSelect.value !== (view.network && view.network.id)) {
+    await loadNetwork(networkSelect.value);
+  }
+}
+
+async function refreshRuns(select) {
+  if (!view.network) {
+    runSelect.replaceChildren();
+    return;
+  }
+
+  const runs = await api(`/runs?network=${view.network.id}`);
+  runSelect.replaceChildren(new Option("", ""), ...runs.map((r) =>
+    new Option(`${r.id}: ${r.broadcast} on ${r.engine.type} (${r.status})`, r.id)));
+
+  if (select) {
+    runSelect.value = select;
+  }
+}
+
+async function loadNetwork(id) {
+  follow(null);
+
+  const [network, topology] = await Promise.all([api(`/networks/${id}`), api(`/networks/${id}/topology`)]);
+  view.network = network;
+  view.index = new Map(topology.nodes.map((n, i) => [n.id, i]));
+  view.nodes = topology.nodes.map((n, i) => {
+    // Start on a spiral so the layout unfolds evenly
+    const angle = i * 2.399963;
+    const radius = WORLD / 2 * Math.sqrt((i + 0.5) / topology.nodes.length);
+    return { id: n.id, role: n.role, x: radius * Math.cos(angle), y: radius * Math.sin(angle), dx: 0, dy: 0 };
+  });
+  view.links = (topology.links || []).map(([a, b]) => [view.index.get(a), view.index.get(b)]);
+  view.heat = WORLD / 10;
+
+  resetRun();
+  await refreshRuns();
+}
+
+// Layout
+
+// layoutStep moves the nodes one step of a Fruchterman-Reingold layout; repulsion is
+// only computed between nodes in neighboring grid cells so large networks stay fast
+function layoutStep() {
+  const nodes = view.nodes;
+  if (view.heat < 0.5 || nodes.length === 0) {
+    return;
+  }
+
+  const k = WORLD / Math.sqrt(nodes.length); // Ideal link length
+  const cell = 2 * k;
+  const grid = new Map();
+  for (let i = 0; i < nodes.length; i++) {
+    const n = nodes[i];
+    n.dx = -n.x * 0.01; // Gravity keeps disconnected parts in view
+    n.dy = -n.y * 0.01;
+
+    const key = `${Math.floor(n.x / cell)},${Math.floor(n.y / cell)}`;
+    if (!grid.has(key)) {
+      grid.set(key, []);
+    }
+    grid.get(key).push(i);
+  }
+
+  for (let i = 0; i < nodes.length; i++) {
+    const n = nodes[i];
+    const cx = Math.floor(n.x / cell);
+    const cy = Math.floor(n.y / cell);
+    for (let gx = cx - 1; gx <= cx + 1; gx++) {
+      for (let gy = cy - 1; gy <= cy + 1; gy++) {
+        for (const j of grid.get(`${gx},${gy}`) || []) {
+          if (j === i) {
+            continue;
+          }
+          const dx = n.x - nodes[j].x;
+          const dy = n.y - nodes[j].y;
+          const d2 = Math.max(dx * dx + dy * dy, 0.01);
+          n.dx += dx * k * k / d2;
+          n.dy += dy * k * k / d2;
+        }
+      }
+    }
+  }
+
+  for (const [a, b] of view.links) {
+    const na = nodes[a];
+    const nb = nodes[b];
+    const dx = na.x - nb.x;
+    const dy = na.y - nb.y;
+    const d = Math.sqrt(dx * dx + dy * dy) || 0.1;
+    const f = d / k;
+    na.dx -= dx * f;
+    na.dy -= dy * f;
+    nb.dx += dx * f;
+    nb.dy += dy * f;
+  }
+
+  for (const n of nodes) {
+    const d = Math.sqrt(n.dx * n.dx + n.dy * n.dy) || 1;
+    const step = Math.min(d, view.heat);
+    n.x += n.dx / d * step;
+    n.y += n.dy / d * step;
+  }
+
+  view.heat *= 0.97;
+}
+
+// Events
+
+function resetRun() {
+  view.queue = [];
+  view.flashes = [];
+  view.clock = null;
+  view.counts = {};
+  view.traceMs = 0;
+  view.run = null;
+  for (const n of view.nodes) {
+    n.hop = undefined;
+    n.origin = false;
+  }
+}
+
+// follow replays the events of a run and follows it until it ends (null stops following)
+function follow(id) {
+  if (view.source) {
+    view.source.close();
+    view.source = null;
+  }
+  resetRun();
+  if (!id) {
+    return;
+  }
+
+  const source = new EventSource(`/runs/${id}/events`);
+  for (const kind of ["publish", "send", "receive", "duplicate", "drop"]) {
+    source.addEventListener(kind, (e) => view.queue.push(JSON.parse(e.data)));
+  }
+  source.addEventListener("end", (e) => {
+    view.run = JSON.parse(e.data);
+    source.close();
+    refreshRuns(id).catch(showError);
+  });
+  view.source = source;
+}
+
+// traceNow returns the trace time to show, in ms
+function traceNow(now) {
+  if (!view.clock) {
+    if (view.queue.length === 0) {
+      return 0;
+    }
+    view.clock = { wall: now, trace: view.queue[0].t / 1e6 };
+  }
+
+  return view.clock.trace + (now - view.clock.wall) * view.speed;
+}
+
+// play shows every queued event that is due
+function play(now) {
+  const due = traceNow(now);
+  let shown = 0;
+  while (shown < view.queue.length && view.queue[shown].t / 1e6 <= due) {
+    apply(view.queue[shown], now);
+    shown++;
+  }
+  view.queue.splice(0, shown);
+}
+
+function apply(e, now) {
+  view.counts[e.kind] = (view.counts[e.kind] || 0) + 1;
+  view.traceMs = Math.max(view.traceMs, e.t / 1e6);
+
+  const a = view.index.get(e.from);
+  const b = view.index.get(e.to);
+  if (a === undefined || b === undefined) {
+    return;
+  }
+
+  switch (e.kind) {
+    case "publish":
+      view.nodes[a].origin = true;
+      view.nodes[a].hop = 0;
+      break;
+    case "receive":
+      if (view.nodes[b].hop === undefined) {
+        view.nodes[b].hop = e.hop + 1;
+      }
+      break;
+  }
+
+  if (e.kind !== "publish") {
+    view.flashes.push({ a, b, kind: e.kind, until: now + FLASH_MS });
+  }
+}
+
+// Drawing
+
+function resize() {
+  const ratio = window.devicePixelRatio || 1;
+  canvas.width = canvas.clientWidth * ratio;
+  canvas.height = canvas.clientHeight * ratio;
+}
+
+// transform returns the scale and offsets fitting the layout into the canvas
+function transform() {
+  let minX = Infinity, minY = Infinity, maxX = -Infinity, maxY = -Infinity;
+  for (const n of view.nodes) {
+    minX = Math.min(minX, n.x);
+    minY = Math.min(minY, n.y);
+    maxX = Math.max(maxX, n.x);
+    maxY = Math.max(maxY, n.y);
+  }
+
+  const pad = 20 * (window.devicePixelRatio || 1);
+  const scale = Math.min((canvas.width - 2 * pad) / (maxX - minX || 1), (canvas.height - 2 * pad) / (maxY - minY || 1));
+  return {
+    scale,
+    x: pad + (canvas.width - 2 * pad - (maxX - minX) * scale) / 2 - minX * scale,
+    y: pad + (canvas.height - 2 * pad - (maxY - minY) * scale) / 2 - minY * scale,
+  };
+}
+
+const FLASH_COLORS = { send: "255, 170, 60", receive: "120, 220, 255", duplicate: "150, 150, 150", drop: "240, 90, 80" };
+
+function draw(now) {
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  if (view.nodes.length === 0) {
+    return;
+  }
+
+  const t = transform();
+  const px = (n) => t.x + n.x * t.scale;
+  const py = (n) => t.y + n.y * t.scale;
+  const ratio = window.devicePixelRatio || 1;
+
+  if (view.links.length <= MAX_LINKS) {
+    ctx.strokeStyle = "rgba(120, 130, 140, 0.15)";
+    ctx.lineWidth = ratio * 0.5;
+    ctx.beginPath();
+    for (const [a, b] of view.links) {
+      ctx.moveTo(px(view.nodes[a]), py(view.nodes[a]));
+      ctx.lineTo(px(view.nodes[b]), py(view.nodes[b]));
+    }
+    ctx.stroke();
+  }
+
+  view.flashes = view.flashes.filter((f) => f.until > now);
+  ctx.lineWidth = ratio * 1.5;
+  for (const f of view.flashes) {
+    const alpha = (f.until - now) / FLASH_MS;
+    ctx.strokeStyle = `rgba(${FLASH_COLORS[f.kind]}, ${alpha})`;
+    ctx.beginPath();
+    ctx.moveTo(px(view.nodes[f.a]), py(view.nodes[f.a]));
+    ctx.lineTo(px(view.nodes[f.b]), py(view.nodes[f.b]));
+    ctx.stroke();
+  }
+
+  const radius = ratio * Math.max(1.5, Math.min(5, 60 / Math.sqrt(view.nodes.length)));
+  for (let i = 0; i < view.nodes.length; i++) {
+    const n = view.nodes[i];
+    ctx.fillStyle = n.hop === undefined ? "#3a424c" : `hsl(${(200 + n.hop * 35) % 360}, 80%, 60%)`;
+    ctx.beginPath();
+    ctx.arc(px(n), py(n), n.origin ? radius * 2 : radius, 0, 2 * Math.PI);
+    ctx.fill();
+
+    if (n.role || n.origin || i === view.hover) {
+      ctx.strokeStyle = i === view.hover ? "#ffffff" : n.origin ? "#ffffff" : "#f47067";
+      ctx.lineWidth = ratio;
+      ctx.stroke();
+    }
+  }
+}
+
+function renderStats() {
+  const rows = [];
+  const add = (name, value) => rows.push([name, value]);
+
+  if (view.network) {
+    add("Network", view.network.id);
+    add("Nodes", view.network.node_count);
+    add("Links", view.links.length);
+    if (view.network.adversaries) {
+      add("Adversaries", view.network.adversaries);
+    }
+  }
+
+  const reached = view.nodes.filter((n) => n.hop !== undefined && !n.origin).length;
+  if (view.source || view.run) {
+    add("Trace time", `${view.traceMs.toFixed(1)} ms`);
+    add("Reached", `${reached} / ${view.nodes.length - 1}`);
+    for (const kind of ["send", "receive", "duplicate", "drop"]) {
+      if (view.counts[kind]) {
+        add(kind[0].toUpperCase() + kind.slice(1) + "s", view.counts[kind]);
+      }
+    }
+  }
+
+  if (view.run) {
+    add("Status", view.run.status);
+    const m = view.run.metric;
+    if (m) {
+      add("Receiving rate", m.receiving_rate.toFixed(3));
+      add("Duplicate rate", m.duplicate_rate.toFixed(2));
+      add("Latency p50", `${(m.latency_p50_ms || 0).toFixed(1)} ms`);
+      add("Latency p99", `${(m.latency_p99_ms || 0).toFixed(1)} ms`);
+      if (m.bytes) {
+        add("Bytes", m.bytes);
+      }
+    }
+    if (view.run.error) {
+      add("Error", view.run.error);
+    }
+  }
+
+  if (view.hover >= 0) {
+    const n = view.nodes[view.hover];
+    add("Node", n.id + (n.role ? ` (${n.role})` : ""));
+    add("Hop", n.hop === undefined ? "-" : n.hop);
+  }
+
+  document.getElementById("stats").replaceChildren(...rows.flatMap(([name, value]) => {
+    const dt = document.createElement("dt");
+    const dd = document.createElement("dd");
+    dt.textContent = name;
+    dd.textContent = value;
+    return [dt, dd];
+  }));
+}
+
+function frame(now) {
+  for (let i = 0; i < 3; i++) {
+    layoutStep();
+  }
+  play(now);
+  draw(now);
+  renderStats();
+  requestAnimationFrame(frame);
+}
+
+// Controls
+
+document.getElementById("network-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const form = new FormData(e.target);
+  try {
+    showError(null);
+    const network = await api("/networks", {
+      seed: Number(form.get("seed")),
+      params: {
+        node_count: Number(form.get("node_count")),
+        mean_degree: Number(form.get("mean_degree")),
+        max_link_delay: Number(form.get("max_link_delay")),
+      },
+    });
+    await refreshNetworks(network.id);
+  } catch (err) {
+    showError(err);
+  }
+});
+
+document.getElementById("run-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  if (!view.network) {
+    return;
+  }
+
+  const form = new FormData(e.target);
+  const spec = { protocol: form.get("protocol"), engine: { type: form.get("engine") } };
+  if (form.get("origin") !== "") {
+    spec.origin = Number(form.get("origin"));
+  }
+
+  try {
+    showError(null);
+    const run = await api(`/networks/${view.network.id}/runs`, spec);
+    await refreshRuns(run.id);
+    follow(run.id);
+  } catch (err) {
+    showError(err);
+  }
+});
+
+networkSelect.addEventListener("change", () => loadNetwork(networkSelect.value).catch(showError));
+runSelect.addEventListener("change", () => follow(runSelect.value));
+
+speedInput.addEventListener("input", () => {
+  const now = performance.now();
+  if (view.clock) {
+    view.clock = { wall: now, trace: traceNow(now) };
+  }
+  view.speed = Math.pow(4, Number(speedInput.value));
+  document.getElementById("speed-label").textContent =
+    view.speed >= 1 ? `${view.speed}×` : `1/${1 / view.speed}×`;
+});
+
+canvas.addEventListener("mousemove", (e) => {
+  const t = transform();
+  const ratio = window.devicePixelRatio || 1;
+  const x = (e.offsetX * ratio - t.x) / t.scale;
+  const y = (e.offsetY * ratio - t.y) / t.scale;
+
+  let best = -1;
+  let bestDist = (10 * ratio / t.scale) ** 2;
+  view.nodes.forEach((n, i) => {
+    const d = (n.x - x) ** 2 + (n.y - y) ** 2;
+    if (d < bestDist) {
+      best = i;
+      bestDist = d;
+    }
+  });
+  view.hover = best;
+});
+
+window.addEventListener("resize", resize);
+resize();
+refreshNetworks().catch(showError);
+requestAnimationFrame(frame);
@@ -0,0 +1,138 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/score"
+)
+
+// Seed salts keeping the per-node Dandelion choices independent of each other and of choose
+const (
+	saltDiffuser = 0xd1b54a32d192ed03
+	saltRelays   = 0x8cb92ba72f3d8dd7
+	saltInbound  = 0xa0761d6478bd642f
+	saltTimer    = 0xe7037ed1a0b428db
+)
+
+// stem forwards a stem-phase message to one of the node's two stem relays and arms its fail-safe
+// timer. It returns false if the node is a diffuser of this epoch and floods the message instead
+func (s *state) stem(e event, push func(event)) bool {
+	i := e.node
+	if s.fluffed[i-s.lo] {
+		return true // Already diffusing after receiving the fluff phase
+	}
+
+	// The origin always stems its own message
+	if i != s.result.Origin && s.diffuser(i) {
+		s.fluffed[i-s.lo] = true
+		return false
+	}
+
+	neighbors, delays := s.g.Row(i)
+	if len(neighbors) == 0 {
+		return true
+	}
+
+	k := s.relay(i, s.result.Parent[i], neighbors)
+	push(event{time: e.time + uint64(delays[k]), node: neighbors[k], from: i, hop: e.hop + 1, kind: evDeliver, stem: true})
+	push(event{time: e.time + s.failSafe(i), node: i, hop: e.hop, kind: evTimer})
+	s.sends++
+	s.bytes += s.size(i, e.hop+1, true)
+
+	return true
+}
+
+// lateFluff diffuses a message that a stem node receives again, either in the fluff phase or
+// because the stem looped back to it
+func (s *state) lateFluff(e event, push func(event)) {
+	i := e.node
+	if s.fluffed[i-s.lo] {
+		return
+	}
+
+	hold, ok := s.forwardDelay(i)
+	if !ok {
+		return
+	}
+
+	s.fluffed[i-s.lo] = true
+	push(event{time: e.time + hold, node: i, hop: e.hop, kind: evProcess})
+}
+
+// expire floods a message whose fail-safe timer ran out before the node saw it diffused
+func (s *state) expire(e event, push func(event)) {
+	s.fluffed[e.node-s.lo] = true
+	s.process(event{time: e.time, node: e.node, hop: e.hop, kind: evProcess}, push)
+}
+
+// diffuser reports whether a node diffuses stem messages in this epoch, with probability q
+func (s *state) diffuser(i int32) bool {
+	rng := splitmix(s.seed ^ (uint64(i)+1)*saltDiffuser)
+
+	return rng.next()%100 < uint64(s.bt.Level)
+}
+
+// relay returns the position of the neighbor a stem message from parent is forwarded to
+// Every node picks two relays per epoch; messages from the same inbound neighbor always take the
+// same one, and the node's own messages (parent -1) the first. The relay back to the sender
+// is avoided when the other one differs, and so are peers with a bad score
+func (s *state) relay(i, parent int32, neighbors []int32) int {
+	rng := splitmix(s.seed ^ (uint64(i)+1)*saltRelays)
+	first := int(rng.next() % uint64(len(neighbors)))
+	second := first
+	if len(neighbors) > 1 {
+		second = (first + 1 + int(rng.next()%uint64(len(neighbors)-1))) % len(neighbors)
+	}
+
+	if parent < 0 {
+		return first
+	}
+
+	inbound := splitmix(s.seed ^ (uint64(i)+1)*saltInbound ^ (uint64(parent)+1)*saltRelays)
+	if inbound.next()&1 == 1 {
+		first, second = second, first
+	}
+	if neighbors[first] == parent {
+		first, second = second, first
+	}
+
+	return s.trusted(i, first, second, len(neighbors))
+}
+
+// trusted returns the first of the chosen relays whose peer is not deprioritized, otherwise the
+// next such neighbor after them, falling back to a peer that is at least not pruned
+func (s *state) trusted(i int32, first, second, degree int) int {
+	if s.scores == nil {
+		return first
+	}
+
+	status := func(k int) score.Status {
+		return s.scores.At(s.g.Offsets[i] + int64(k))
+	}
+
+	for _, want := range []score.Status{score.Normal, score.Deprioritized} {
+		if status(first) <= want {
+			return first
+		}
+		if status(second) <= want {
+			return second
+		}
+		for d := 1; d < degree; d++ {
+			if k := (first + d) % degree; status(k) <= want {
+				return k
+			}
+		}
+	}
+
+	return first
+}
+
+// failSafe returns the fail-safe timer of a node: the timeout plus an exponential extra delay
+// with the same mean, so stem nodes rarely expire at the same time
+func (s *state) failSafe(i int32) uint64 {
+	rng := splitmix(s.seed ^ (uint64(i)+1)*saltTimer)
+	u := float64(rng.next()>>11) / (1 << 53)
+	timeout := float64(s.bt.FailSafe())
+
+	return uint64(timeout - math.Log1p(-u)*timeout)
+}
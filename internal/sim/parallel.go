@@ -0,0 +1,158 @@
+package sim
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/csr"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// ParallelConfig contains parameters for the parallel simulation engine
+type ParallelConfig struct {
+	Workers   int    // Number of partitions simulated concurrently (0 uses GOMAXPROCS)
+	Lookahead uint64 // Synchronization window in milliseconds (0 uses the minimum link delay)
+	Options
+}
+
+// ErrNoLookahead is returned by RunParallel for graphs with links without delay
+var ErrNoLookahead = errors.New("parallel engine needs min_link_delay > 0 as its lookahead")
+
+// partition is a contiguous range of nodes simulated by one worker
+type partition struct {
+	state  *state
+	queue  eventQueue
+	outbox [][]event // Events for other partitions, indexed by destination partition
+}
+
+// RunParallel simulates a single broadcast using conservative parallel discrete-event simulation
+// Nodes are split into contiguous partitions of similar arc count and every partition advances
+// through time windows of length lookahead. Since no message crosses a link faster than the
+// minimum link delay (NetworkConfig.MinLinkDelay for generated graphs), events sent to other
+// partitions always fall into a later window. The total event order is the same as in Run, so
+// results are identical to the sequential engine for the same seed. A graph with a link of
+// zero delay leaves no lookahead and fails with ErrNoLookahead
+func RunParallel(g *csr.Graph, origin int32, broadcastType p2p.BroadcastType, seed int64, config ParallelConfig) (*Result, error) {
+	if g.MinLinkDelay() == 0 {
+		return nil, ErrNoLookahead
+	}
+
+	lookahead := config.Lookahead
+	if lookahead == 0 {
+		lookahead = uint64(g.MinLinkDelay())
+	}
+	// A configured window larger than the actual minimum link delay would break causality
+	lookahead = min(lookahead, uint64(g.MinLinkDelay()))
+
+	workers := config.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, g.NodeCount())
+
+	if workers <= 1 {
+		return RunWith(g, origin, broadcastType, seed, config.Options), nil
+	}
+
+	r := newResult(g, origin, config.Options)
+	bounds := partitionBounds(g, workers)
+	parts := make([]*partition, len(bounds)-1)
+
+	for p := range parts {
+		parts[p] = &partition{
+			state:  newState(g, r, bounds[p], bounds[p+1], broadcastType, seed, config.Options),
+			outbox: make([][]event, len(parts)),
+		}
+	}
+
+	owner := func(i int32) int {
+		return sort.Search(len(parts), func(p int) bool {
+			return bounds[p+1] > i
+		})
+	}
+
+	op := parts[owner(origin)]
+	op.state.publish(&op.queue)
+
+	for {
+		// Find the earliest pending event across all partitions
+		next, pending := uint64(0), false
+		for _, part := range parts {
+			if len(part.queue) > 0 && (!pending || part.queue.peek().time < next) {
+				next = part.queue.peek().time
+				pending = true
+			}
+		}
+
+		if !pending {
+			break
+		}
+
+		end := next + lookahead
+
+		// Advance every partition to the end of the window
+		wg := sync.WaitGroup{}
+		for _, part := range parts {
+			wg.Add(1)
+
+			go func(part *partition) {
+				defer wg.Done()
+
+				push := func(e event) {
+					if e.node >= part.state.lo && e.node < part.state.hi {
+						part.queue.push(e)
+					} else {
+						dest := owner(e.node)
+						part.outbox[dest] = append(part.outbox[dest], e)
+					}
+				}
+
+				for len(part.queue) > 0 && part.queue.peek().time < end {
+					part.state.handle(part.queue.pop(), push)
+				}
+			}(part)
+		}
+		wg.Wait()
+
+		// Exchange cross-partition events; the queue order is total, so merge order does not matter
+		for _, src := range parts {
+			for dest, events := range src.outbox {
+				for _, e := range events {
+					parts[dest].queue.push(e)
+				}
+				src.outbox[dest] = events[:0]
+			}
+		}
+	}
+
+	for _, part := range parts {
+		r.Sends += part.state.sends
+		r.Bytes += part.state.bytes
+		r.Duration = max(r.Duration, part.state.last)
+	}
+
+	return r, nil
+}
+
+// partitionBounds splits the nodes into contiguous ranges with roughly equal arc counts
+// Returns parts+1 boundaries, the first being 0 and the last the node count
+func partitionBounds(g *csr.Graph, parts int) []int32 {
+	count := int32(g.NodeCount())
+	bounds := []int32{0}
+
+	for p := 1; p < parts; p++ {
+		target := int64(g.ArcCount()) * int64(p) / int64(parts)
+
+		b := int32(sort.Search(int(count), func(i int) bool {
+			return g.Offsets[i] >= target
+		}))
+		b = max(b, bounds[len(bounds)-1]+1) // Keep partitions non-empty
+		b = min(b, count-int32(parts-p))
+
+		bounds = append(bounds, b)
+	}
+
+	return append(bounds, count)
+}
@@ -0,0 +1,62 @@
+package sim
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/csr"
+	"github.com/elecbug/p2p-broadcast-tester/internal/network"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/score"
+)
+
+func TestRunParallelMatchesRun(t *testing.T) {
+	g := csr.GenerateLimitDegree(network.NetworkConfig{
+		NodeCount: 3000, D: 8, DLow: 6, DHigh: 12,
+		MinLinkDelay: 5, MaxLinkDelay: 50, MaxNodeDelay: 100, Seed: 11,
+	})
+
+	protocols := []p2p.BroadcastType{
+		{Type: p2p.BasicPublish},
+		{Type: p2p.WavePublish, Level: 30},
+		{Type: p2p.Dandelion, Level: 10, Timeout: 500},
+	}
+
+	for _, bt := range protocols {
+		for _, seed := range []int64{1, 42} {
+			opts := func() Options {
+				return Options{Scores: score.NewTable(g.Offsets, g.Neighbors, score.DefaultParams())}
+			}
+			want := RunWith(g, 17, bt, seed, opts())
+
+			for _, workers := range []int{2, 4, 8} {
+				got, err := RunParallel(g, 17, bt, seed, ParallelConfig{Workers: workers, Options: opts()})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(got.FirstReceipt, want.FirstReceipt) || !reflect.DeepEqual(got.Arrival, want.Arrival) {
+					t.Errorf("%s, seed %d, %d workers: receipt times differ from Run", bt, seed, workers)
+				}
+				if !reflect.DeepEqual(got.Parent, want.Parent) || !reflect.DeepEqual(got.Receipts, want.Receipts) || !reflect.DeepEqual(got.Copies, want.Copies) {
+					t.Errorf("%s, seed %d, %d workers: receipts differ from Run", bt, seed, workers)
+				}
+				if got.Sends != want.Sends || got.Bytes != want.Bytes || got.Duration != want.Duration {
+					t.Errorf("%s, seed %d, %d workers: %d sends in %d ms, want %d in %d ms", bt, seed, workers, got.Sends, got.Duration, want.Sends, want.Duration)
+				}
+			}
+		}
+	}
+}
+
+func TestRunParallelNoLookahead(t *testing.T) {
+	g := csr.GenerateLimitDegree(network.NetworkConfig{NodeCount: 100, D: 8, DLow: 6, DHigh: 12, MaxLinkDelay: 50, MaxNodeDelay: 100, Seed: 1})
+	if g.MinLinkDelay() != 0 {
+		t.Skip("graph has no link without delay")
+	}
+
+	if _, err := RunParallel(g, 0, p2p.BroadcastType{Type: p2p.BasicPublish}, 1, ParallelConfig{Workers: 4}); !errors.Is(err, ErrNoLookahead) {
+		t.Errorf("got %v, want %v", err, ErrNoLookahead)
+	}
+}
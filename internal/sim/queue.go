@@ -0,0 +1,94 @@
+package sim
+
+// Event kinds, ordered so deliveries are handled before processing at equal times
+const (
+	evDeliver uint8 = iota // Message arrives at a node
+	evProcess              // Node finishes processing and forwards the message
+	evTimer                // Dandelion fail-safe timer of a stem node expires
+)
+
+// event is a single entry of the virtual-time event queue
+type event struct {
+	time uint64 // Virtual time in milliseconds
+	node int32  // Node handling the event
+	from int32  // Sending node (deliveries only)
+	hop  int32  // Hop counter carried by the message
+	kind uint8
+	stem bool // Dandelion stem phase (deliveries and processing only)
+}
+
+// before defines a total order on events so runs are deterministic
+func (a *event) before(b *event) bool {
+	if a.time != b.time {
+		return a.time < b.time
+	}
+	if a.kind != b.kind {
+		return a.kind < b.kind
+	}
+	if a.node != b.node {
+		return a.node < b.node
+	}
+	if a.from != b.from {
+		return a.from < b.from
+	}
+
+	return a.stem && !b.stem
+}
+
+// eventQueue is a binary min-heap of events
+// It avoids container/heap so events are not boxed into interfaces
+type eventQueue []event
+
+// push adds an event to the queue
+func (q *eventQueue) push(e event) {
+	*q = append(*q, e)
+	h := *q
+
+	i := len(h) - 1
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h[i].before(&h[parent]) {
+			break
+		}
+		h[i], h[parent] = h[parent], h[i]
+		i = parent
+	}
+}
+
+// pop removes and returns the earliest event
+func (q *eventQueue) pop() event {
+	h := *q
+	top := h[0]
+
+	last := len(h) - 1
+	h[0] = h[last]
+	h = h[:last]
+
+	i := 0
+	for {
+		smallest := i
+		left, right := 2*i+1, 2*i+2
+
+		if left < len(h) && h[left].before(&h[smallest]) {
+			smallest = left
+		}
+		if right < len(h) && h[right].before(&h[smallest]) {
+			smallest = right
+		}
+		if smallest == i {
+			break
+		}
+
+		h[i], h[smallest] = h[smallest], h[i]
+		i = smallest
+	}
+
+	*q = h
+
+	return top
+}
+
+// peek returns the earliest event without removing it
+func (q eventQueue) peek() *event {
+	return &q[0]
+}
@@ -0,0 +1,472 @@
+package sim
+
+import (
+	"sort"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/csr"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+	"github.com/elecbug/p2p-broadcast-tester/internal/score"
+	"github.com/elecbug/p2p-broadcast-tester/internal/wire"
+)
+
+// unreached marks nodes that never received the message
+const unreached = ^uint64(0)
+
+// wireID is the message ID simulated transmissions are sized with, as the first message of an experiment
+const wireID p2p.MessageID = 1
+
+// Result holds the array-based per-message state of a simulated broadcast
+type Result struct {
+	Origin       int32
+	FirstReceipt []uint64 // Virtual time of each node's first receipt (unreached if never)
+	Receipts     []int32  // Receipts per node, including duplicates
+	Parent       []int32  // Sender of each node's first receipt (-1 for origin and unreached nodes)
+	Hop          []int32  // Hop counter of each node's first receipt
+	Sends        int64    // Total number of transmissions
+	Bytes        int64    // Wire bytes of all transmissions, framed as by the wire codec
+	Duration     uint64   // Virtual time of the last event, when the broadcast settled
+
+	// Per-arc records of scored broadcasts, indexed by the arc i -> j for what i received from j
+	Copies  []int32  // Valid copies received over the arc
+	Invalid []int32  // Invalid copies received over the arc
+	Arrival []uint64 // Virtual time of the first valid copy over the arc
+
+	adversary *p2p.Adversary // Roles the nodes followed (nil if all were honest)
+}
+
+// Options are the optional inputs of a simulated broadcast
+type Options struct {
+	Adversary *p2p.Adversary // Malicious roles of the nodes (nil if all are honest)
+	Scores    *score.Table   // Peer scores the nodes act on, indexed by the graph's arcs (nil treats all peers equally)
+	Payload   int            // Payload bytes of the message, counted in Result.Bytes
+}
+
+// state is the mutable simulation state of the nodes in [lo, hi)
+// The sequential engine uses a single state covering all nodes, the parallel engine one per partition
+type state struct {
+	g       *csr.Graph
+	bt      p2p.BroadcastType
+	coef    float64  // WavePublish fanout coefficient
+	seed    uint64   // Seed for per-node random choices
+	lo, hi  int32    // Range of nodes owned by this state
+	arcBase int64    // Position of the first arc owned by this state
+	seen    []uint64 // Bitset over owned arcs: arc i -> j is set once i has received from j
+	result  *Result  // Shared result arrays, only written for owned nodes
+	sends   int64    // Transmissions made by owned nodes
+	bytes   int64    // Wire bytes of the transmissions made by owned nodes
+	payload []byte   // Zeroed payload of Options.Payload bytes, only sized
+	last    uint64   // Time of the last event handled
+	buf     []int32  // Scratch space for eligible neighbors
+	adv     *p2p.Adversary
+	scores  *score.Table
+	fluffed []bool // Dandelion: owned nodes that diffuse the message or have scheduled to
+}
+
+// newResult allocates the per-message arrays for a broadcast from origin
+func newResult(g *csr.Graph, origin int32, opts Options) *Result {
+	count := g.NodeCount()
+
+	r := &Result{
+		Origin:       origin,
+		FirstReceipt: make([]uint64, count),
+		Receipts:     make([]int32, count),
+		Parent:       make([]int32, count),
+		Hop:          make([]int32, count),
+		adversary:    opts.Adversary,
+	}
+	for i := range r.FirstReceipt {
+		r.FirstReceipt[i] = unreached
+		r.Parent[i] = -1
+	}
+
+	if opts.Scores != nil {
+		r.Copies = make([]int32, g.ArcCount())
+		r.Invalid = make([]int32, g.ArcCount())
+		r.Arrival = make([]uint64, g.ArcCount())
+	}
+
+	return r
+}
+
+// newState creates the simulation state for the nodes in [lo, hi)
+func newState(g *csr.Graph, r *Result, lo, hi int32, bt p2p.BroadcastType, seed int64, opts Options) *state {
+	arcBase := g.Offsets[lo]
+	arcs := g.Offsets[hi] - arcBase
+
+	s := &state{
+		g:       g,
+		bt:      bt,
+		coef:    float64(bt.Level) / 100.0,
+		seed:    uint64(seed),
+		lo:      lo,
+		hi:      hi,
+		arcBase: arcBase,
+		seen:    make([]uint64, (arcs+63)/64),
+		result:  r,
+		adv:     opts.Adversary,
+		scores:  opts.Scores,
+		payload: make([]byte, max(opts.Payload, 0)),
+	}
+	if bt.Type == p2p.Dandelion {
+		s.fluffed = make([]bool, hi-lo)
+	}
+
+	return s
+}
+
+// Run simulates a single broadcast from origin on a CSR graph in virtual time
+// Protocol semantics match node.Broadcast: a node forwards after its processing delay,
+// skipping its first sender and every neighbor it has already received the message from
+func Run(g *csr.Graph, origin int32, broadcastType p2p.BroadcastType, seed int64) *Result {
+	return RunWith(g, origin, broadcastType, seed, Options{})
+}
+
+// RunWith simulates a broadcast in which the nodes follow the roles of an adversary and act on peer scores
+// Role semantics match node.Receive: droppers and censors never forward (censors only
+// for censored origins), delayers hold each message, spammers flood copies to every neighbor
+// and the copies of forgers are discarded as invalid. Score semantics match node.Receive too:
+// messages from graylisted peers are ignored, pruned peers are not sent to and deprioritized
+// peers are picked last by WavePublish and Dandelion relays
+func RunWith(g *csr.Graph, origin int32, broadcastType p2p.BroadcastType, seed int64, opts Options) *Result {
+	r := newResult(g, origin, opts)
+	s := newState(g, r, 0, int32(g.NodeCount()), broadcastType, seed, opts)
+	q := eventQueue{}
+
+	s.publish(&q)
+
+	for len(q) > 0 {
+		e := q.pop()
+		s.handle(e, q.push)
+	}
+
+	r.Sends = s.sends
+	r.Bytes = s.bytes
+	r.Duration = s.last
+
+	return r
+}
+
+// publish marks the origin as reached and schedules its first transmission
+func (s *state) publish(q *eventQueue) {
+	o := s.result.Origin
+	s.result.FirstReceipt[o] = 0
+	s.result.Hop[o] = -1 // The origin transmits with hop 0
+
+	q.push(event{time: uint64(s.g.NodeDelays[o]), node: o, hop: -1, kind: evProcess, stem: s.bt.Type == p2p.Dandelion})
+}
+
+// handle processes a single event, scheduling follow-up events through push
+func (s *state) handle(e event, push func(event)) {
+	// Timers of messages that were diffused in time are not part of the broadcast
+	if e.kind == evTimer && s.fluffed[e.node-s.lo] {
+		return
+	}
+
+	s.last = e.time
+
+	switch e.kind {
+	case evDeliver:
+		s.deliver(e, push)
+	case evProcess:
+		s.process(e, push)
+	case evTimer:
+		s.expire(e, push)
+	}
+}
+
+// deliver records a receipt and schedules forwarding on the first one
+func (s *state) deliver(e event, push func(event)) {
+	r := s.result
+	i := e.node
+	arc := s.g.ArcIndex(i, e.from)
+
+	if arc >= 0 && s.scores.At(arc) == score.Graylisted {
+		return // Ignored without looking at it
+	}
+	if s.adv.Role(int(e.from)) == p2p.Forger {
+		if r.Invalid != nil && arc >= 0 {
+			r.Invalid[arc]++
+		}
+		return // Discarded as invalid
+	}
+
+	r.Receipts[i]++
+	if arc >= 0 {
+		if r.Copies != nil {
+			if r.Copies[arc] == 0 {
+				r.Arrival[arc] = e.time
+			}
+			r.Copies[arc]++
+		}
+		k := arc - s.arcBase
+		s.seen[k/64] |= 1 << (k % 64)
+	}
+
+	if r.FirstReceipt[i] != unreached {
+		if s.fluffed != nil {
+			s.lateFluff(e, push)
+		}
+		return // Duplicate
+	}
+
+	r.FirstReceipt[i] = e.time
+	r.Parent[i] = e.from
+	r.Hop[i] = e.hop
+
+	hold, ok := s.forwardDelay(i)
+	if !ok {
+		return
+	}
+
+	if s.fluffed != nil && !e.stem {
+		s.fluffed[i-s.lo] = true
+	}
+
+	push(event{time: e.time + hold, node: i, hop: e.hop, kind: evProcess, stem: e.stem})
+}
+
+// forwardDelay returns how long a node holds a message before forwarding it,
+// or false if its role never forwards the message
+func (s *state) forwardDelay(i int32) (uint64, bool) {
+	hold := uint64(s.g.NodeDelays[i])
+
+	switch s.adv.Role(int(i)) {
+	case p2p.Dropper, p2p.Sybil:
+		return 0, false
+	case p2p.Censor:
+		if s.adv.Censored[p2p.NodeID(s.result.Origin)] {
+			return 0, false
+		}
+	case p2p.Delayer:
+		hold += uint64(s.adv.Hold)
+	}
+
+	return hold, true
+}
+
+// process forwards the message from a node after its processing delay
+func (s *state) process(e event, push func(event)) {
+	i := e.node
+	parent := s.result.Parent[i]
+	neighbors, delays := s.g.Row(i)
+	start := s.g.Offsets[i] - s.arcBase
+
+	// The origin sends hop 0, relays send hop+1
+	hop := e.hop + 1
+
+	// Spammers send every neighbor several copies regardless of what it already has
+	if s.adv.Role(int(i)) == p2p.Spammer {
+		for k, j := range neighbors {
+			for c := 0; c < s.adv.Copies; c++ {
+				push(event{time: e.time + uint64(delays[k]), node: j, from: i, hop: hop, kind: evDeliver})
+			}
+		}
+		s.sends += int64(len(neighbors) * s.adv.Copies)
+		s.bytes += int64(len(neighbors)*s.adv.Copies) * s.size(i, hop, false)
+		return
+	}
+
+	// Dandelion stem nodes forward to a single relay; diffusers fall through to flooding
+	if e.stem && s.stem(e, push) {
+		return
+	}
+
+	// Collect neighbors that are neither the first sender, known to have the message nor pruned
+	eligible := s.buf[:0]
+	for k, j := range neighbors {
+		pos := start + int64(k)
+		if j == parent || s.seen[pos/64]&(1<<(pos%64)) != 0 || s.scores.At(s.g.Offsets[i]+int64(k)) >= score.Pruned {
+			continue
+		}
+		eligible = append(eligible, int32(k))
+	}
+	s.buf = eligible
+
+	// WavePublish forwards to a random subset on odd hops
+	if s.bt.Type == p2p.WavePublish && e.hop >= 0 && e.hop%2 == 1 && len(eligible) > 0 {
+		maxSend := max(int(s.coef*float64(len(neighbors))), 1)
+		eligible = s.prefer(i, eligible, maxSend)
+	}
+
+	for _, k := range eligible {
+		push(event{time: e.time + uint64(delays[k]), node: neighbors[k], from: i, hop: hop, kind: evDeliver})
+	}
+	s.sends += int64(len(eligible))
+	s.bytes += int64(len(eligible)) * s.size(i, hop, false)
+}
+
+// size returns the framed wire size of a transmission from node i
+func (s *state) size(i, hop int32, stem bool) int64 {
+	e := wire.Envelope{
+		Kind:     wire.Relay,
+		ID:       wireID,
+		Origin:   p2p.NodeID(s.result.Origin),
+		From:     p2p.NodeID(i),
+		Hop:      int(hop),
+		Protocol: s.bt,
+		Payload:  s.payload,
+	}
+	if stem {
+		e.Kind = wire.Stem
+	}
+
+	return int64(wire.FrameSize(e))
+}
+
+// prefer selects up to n random neighbor positions of node i, taking deprioritized peers
+// only if there are not enough others
+func (s *state) prefer(i int32, entries []int32, n int) []int32 {
+	if s.scores == nil || n >= len(entries) {
+		return s.choose(i, entries, n)
+	}
+
+	// Move the deprioritized peers to the end, keeping both groups in order
+	sort.SliceStable(entries, func(a, b int) bool {
+		return s.scores.At(s.g.Offsets[i]+int64(entries[a])) < s.scores.At(s.g.Offsets[i]+int64(entries[b]))
+	})
+
+	normal := sort.Search(len(entries), func(k int) bool {
+		return s.scores.At(s.g.Offsets[i]+int64(entries[k])) != score.Normal
+	})
+	if normal >= n {
+		return s.choose(i, entries[:normal], n)
+	}
+
+	rest := s.choose(i, entries[normal:], n-normal)
+	return append(entries[:normal], rest...)
+}
+
+// choose selects up to n random entries with a generator seeded per node,
+// so the choice is independent of event interleaving
+func (s *state) choose(i int32, entries []int32, n int) []int32 {
+	if n >= len(entries) {
+		return entries
+	}
+
+	rng := splitmix(s.seed ^ (uint64(i)+1)*0x9e3779b97f4a7c15)
+	for k := 0; k < n; k++ {
+		j := k + int(rng.next()%uint64(len(entries)-k))
+		entries[k], entries[j] = entries[j], entries[k]
+	}
+
+	entries = entries[:n]
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a] < entries[b]
+	})
+
+	return entries
+}
+
+// splitmix is a small deterministic random generator (SplitMix64)
+type splitmix uint64
+
+// next returns the next pseudo-random value
+func (s *splitmix) next() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	z := uint64(*s)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+	return z ^ (z >> 31)
+}
+
+// Metric calculates broadcast performance metrics using the same formula as network.Metric
+func (r *Result) Metric(g *csr.Graph, broadcastType p2p.BroadcastType, delay int) p2p.NetworkMetric {
+	recvCount := 0
+	dontRecvCount := 0
+
+	for i, c := range r.Receipts {
+		recvCount += int(c)
+
+		// The formula expects the origin among the nodes without receipts, even if a copy came back
+		if c == 0 || int32(i) == r.Origin {
+			dontRecvCount++
+		}
+	}
+
+	metric := p2p.NewNetworkMetric(g.NodeCount(), broadcastType.String(), delay, g.AvgDegree(), recvCount, dontRecvCount)
+
+	if r.adversary != nil {
+		metric.SetHonest(r.adversary, p2p.HonestView{
+			Origin:   int(r.Origin),
+			Reached:  func(i int) bool { return r.FirstReceipt[i] != unreached },
+			Latency:  func(i int) float64 { return float64(r.FirstReceipt[i]) },
+			Receipts: func(i int) int { return int(r.Receipts[i]) },
+		})
+	}
+
+	latencies := r.Latencies()
+	ms := make([]float64, len(latencies))
+	for i, t := range latencies {
+		ms[i] = float64(t) // Virtual time is already in milliseconds
+	}
+	metric.SetLatencies(ms)
+	metric.SetBytes(r.Bytes)
+
+	return metric
+}
+
+// Observe feeds what every node saw from its peers during the broadcast to their scores and updates them
+// The broadcast must have been run with the same table in its options
+func (r *Result) Observe(g *csr.Graph, bt p2p.BroadcastType, t *score.Table) {
+	for i := range r.FirstReceipt {
+		if int32(i) == r.Origin {
+			continue // The origin published the message and expects nothing from its peers
+		}
+
+		received := r.FirstReceipt[i] != unreached
+		neighbors, _ := g.Row(int32(i))
+		for k, j := range neighbors {
+			arc := g.Offsets[i] + int64(k)
+
+			d := score.Delivery{
+				Received: received,
+				Expected: received && r.expects(g, bt, t, int32(i), j),
+				Copies:   int(r.Copies[arc]),
+				First:    received && r.Parent[i] == j,
+				Invalid:  int(r.Invalid[arc]),
+			}
+			if d.Copies > 0 {
+				d.Lag = r.Arrival[arc] - r.FirstReceipt[i]
+			}
+
+			t.Observe(arc, d)
+		}
+	}
+
+	t.Update()
+}
+
+// expects reports whether the protocol had peer j deliver node i a copy within the delivery window
+// Only flooding peers send to all their peers, and not to those they have pruned or already had
+// a copy from when they forwarded
+func (r *Result) expects(g *csr.Graph, bt p2p.BroadcastType, t *score.Table, i, j int32) bool {
+	arc := g.ArcIndex(j, i)
+	if !bt.Floods() || r.FirstReceipt[j] == unreached || arc < 0 || t.At(arc) >= score.Pruned {
+		return false
+	}
+
+	forward := r.FirstReceipt[j] + uint64(g.NodeDelays[j])
+	if r.Copies[arc] > 0 && r.Arrival[arc] <= forward {
+		return false
+	}
+
+	return forward+uint64(g.LinkDelays[arc]) <= r.FirstReceipt[i]+uint64(t.Params.DeliveryWindow)
+}
+
+// Latencies returns the first receipt time of every reached node except the origin, in ascending order
+func (r *Result) Latencies() []uint64 {
+	latencies := make([]uint64, 0, len(r.FirstReceipt))
+
+	for i, t := range r.FirstReceipt {
+		if t != unreached && int32(i) != r.Origin {
+			latencies = append(latencies, t)
+		}
+	}
+
+	sort.Slice(latencies, func(a, b int) bool {
+		return latencies[a] < latencies[b]
+	})
+
+	return latencies
+}
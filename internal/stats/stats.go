@@ -0,0 +1,152 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Mean returns the arithmetic mean (0 for no values)
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+
+	return sum / float64(len(values))
+}
+
+// StdDev returns the sample standard deviation (0 for fewer than two values)
+func StdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	mean := Mean(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+
+	return math.Sqrt(sum / float64(len(values)-1))
+}
+
+// Quantile returns the q-quantile (0 <= q <= 1) of sorted values using linear interpolation
+func Quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// Sorted returns a sorted copy of values
+func Sorted(values []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	return sorted
+}
+
+// BootstrapCI returns a percentile bootstrap confidence interval for the mean
+// level is the confidence level (e.g. 0.95) and resamples the number of bootstrap samples
+func BootstrapCI(values []float64, level float64, resamples int, rng *rand.Rand) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	if len(values) == 1 {
+		return values[0], values[0]
+	}
+
+	means := make([]float64, resamples)
+	for r := range means {
+		sum := 0.0
+		for range values {
+			sum += values[rng.Intn(len(values))]
+		}
+		means[r] = sum / float64(len(values))
+	}
+	sort.Float64s(means)
+
+	alpha := (1 - level) / 2
+
+	return Quantile(means, alpha), Quantile(means, 1-alpha)
+}
+
+// MannWhitney holds the result of a two-sided Mann-Whitney U test
+type MannWhitney struct {
+	U float64 // U statistic of the first sample
+	Z float64 // Normal approximation with tie and continuity correction
+	P float64 // Two-sided p-value
+}
+
+// MannWhitneyU compares two independent samples without assuming normality
+// The p-value uses the normal approximation, which is reasonable from about 8 values per sample
+func MannWhitneyU(a, b []float64) MannWhitney {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return MannWhitney{P: 1}
+	}
+
+	// Rank the pooled samples, averaging ranks of ties
+	type entry struct {
+		value float64
+		first bool
+	}
+	pooled := make([]entry, 0, len(a)+len(b))
+	for _, v := range a {
+		pooled = append(pooled, entry{v, true})
+	}
+	for _, v := range b {
+		pooled = append(pooled, entry{v, false})
+	}
+	sort.Slice(pooled, func(i, j int) bool {
+		return pooled[i].value < pooled[j].value
+	})
+
+	rankSum := 0.0
+	tieTerm := 0.0
+
+	for i := 0; i < len(pooled); {
+		j := i
+		for j < len(pooled) && pooled[j].value == pooled[i].value {
+			j++
+		}
+
+		rank := float64(i+j+1) / 2 // Average of ranks i+1 .. j
+		for k := i; k < j; k++ {
+			if pooled[k].first {
+				rankSum += rank
+			}
+		}
+
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+
+	u := rankSum - n1*(n1+1)/2
+	mean := n1 * n2 / 2
+	n := n1 + n2
+	variance := n1 * n2 / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+
+	if variance <= 0 {
+		return MannWhitney{U: u, P: 1} // All values tied
+	}
+
+	diff := math.Abs(u-mean) - 0.5
+	z := max(diff, 0) / math.Sqrt(variance)
+	if u < mean {
+		z = -z
+	}
+
+	return MannWhitney{U: u, Z: z, P: math.Erfc(math.Abs(z) / math.Sqrt2)}
+}
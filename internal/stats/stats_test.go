@@ -0,0 +1,112 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// near reports whether got is within tol of want
+func near(got, want, tol float64) bool {
+	return math.Abs(got-want) <= tol
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		want MannWhitney
+	}{
+		// The asymptotic example of scipy.stats.mannwhitneyu: U = 17, p = 0.11134688653314041
+		{"scipy example", []float64{19, 22, 16, 29, 24}, []float64{20, 11, 17, 12},
+			MannWhitney{U: 17, Z: 1.5921683328090657, P: 0.11134688653314041}},
+		// The samples of the wilcox.test example in R, W = 35, under the normal approximation
+		{"R example", []float64{0.80, 0.83, 1.89, 1.04, 1.45, 1.38, 1.91, 1.64, 0.73, 1.46}, []float64{1.15, 0.88, 0.90, 0.74, 1.21},
+			MannWhitney{U: 35, Z: 1.1635076278220096, P: 0.24462360512698333}},
+		// Ties: the three 3s of a tie with the 3 of b for U = 1.5, the tie term is 3*(2^3-2) + (4^3-4) = 78
+		{"ties", []float64{1, 2, 2, 3, 3, 3}, []float64{3, 4, 4, 5, 5, 6},
+			MannWhitney{U: 1.5, Z: -2.6223422501036886, P: 0.00873276851253925}},
+		// The continuity correction cannot push the statistic past the mean
+		{"identical", []float64{1, 2, 3}, []float64{1, 2, 3}, MannWhitney{U: 4.5, Z: 0, P: 1}},
+		{"all tied", []float64{5, 5, 5}, []float64{5, 5}, MannWhitney{U: 3, P: 1}},
+		{"empty", nil, []float64{1, 2}, MannWhitney{P: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MannWhitneyU(tt.a, tt.b)
+
+			if got.U != tt.want.U || !near(got.Z, tt.want.Z, 1e-9) || !near(got.P, tt.want.P, 1e-9) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMannWhitneyUSymmetry(t *testing.T) {
+	a := []float64{3, 1, 4, 1, 5, 9, 2, 6}
+	b := []float64{5, 3, 5, 8, 9, 7, 9, 3, 2}
+
+	ab, ba := MannWhitneyU(a, b), MannWhitneyU(b, a)
+
+	if ab.U+ba.U != float64(len(a)*len(b)) {
+		t.Errorf("U statistics %v and %v do not add up to %d", ab.U, ba.U, len(a)*len(b))
+	}
+	if !near(ab.Z, -ba.Z, 1e-12) || !near(ab.P, ba.P, 1e-12) {
+		t.Errorf("swapping the samples changed the test: %+v and %+v", ab, ba)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	// Linear interpolation between closest ranks, as numpy.quantile and R's type 7
+	tests := []struct {
+		sorted []float64
+		q      float64
+		want   float64
+	}{
+		{[]float64{1, 2, 3, 4}, 0, 1},
+		{[]float64{1, 2, 3, 4}, 0.25, 1.75},
+		{[]float64{1, 2, 3, 4}, 0.5, 2.5},
+		{[]float64{1, 2, 3, 4}, 1, 4},
+		{[]float64{10, 20, 30}, 0.9, 28},
+		{[]float64{1, 2, 4, 8, 16}, 0.99, 15.68},
+		{[]float64{7}, 0.3, 7},
+		{nil, 0.5, 0},
+	}
+
+	for _, tt := range tests {
+		if got := Quantile(tt.sorted, tt.q); !near(got, tt.want, 1e-12) {
+			t.Errorf("Quantile(%v, %v) = %v, want %v", tt.sorted, tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestBootstrapCI(t *testing.T) {
+	values := make([]float64, 100)
+	for i := range values {
+		values[i] = float64(i)
+	}
+
+	// The bootstrap distribution of the mean of 0..99 is close to normal with mean 49.5 and
+	// standard error sqrt((100^2-1)/12)/10 = 2.8866, so the 95% interval is about 49.5 -+ 5.658
+	lo, hi := BootstrapCI(values, 0.95, 20000, rand.New(rand.NewSource(1)))
+	if !near(lo, 43.842, 0.3) || !near(hi, 55.158, 0.3) {
+		t.Errorf("got [%.3f, %.3f], want about [43.842, 55.158]", lo, hi)
+	}
+
+	narrow, wide := BootstrapCI(values, 0.5, 20000, rand.New(rand.NewSource(1)))
+	if narrow <= lo || wide >= hi {
+		t.Errorf("50%% interval [%.3f, %.3f] is not inside the 95%% interval [%.3f, %.3f]", narrow, wide, lo, hi)
+	}
+
+	constant := []float64{3, 3, 3, 3}
+	if lo, hi := BootstrapCI(constant, 0.95, 100, rand.New(rand.NewSource(1))); lo != 3 || hi != 3 {
+		t.Errorf("constant values: got [%v, %v], want [3, 3]", lo, hi)
+	}
+	if lo, hi := BootstrapCI([]float64{8}, 0.95, 100, rand.New(rand.NewSource(1))); lo != 8 || hi != 8 {
+		t.Errorf("single value: got [%v, %v], want [8, 8]", lo, hi)
+	}
+	if lo, hi := BootstrapCI(nil, 0.95, 100, rand.New(rand.NewSource(1))); lo != 0 || hi != 0 {
+		t.Errorf("no values: got [%v, %v], want [0, 0]", lo, hi)
+	}
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// Condition compares a column or parameter with a value
+type Condition struct {
+	Field string
+	Op    string // One of =, !=, <, <=, >, >=, ~ (substring)
+	Value string
+}
+
+// operators lists the condition operators, longest first so that parsing is unambiguous
+var operators = []string{"!=", "<=", ">=", "=", "<", ">", "~"}
+
+// ParseCondition parses a condition such as "node_count>=10000" or "broadcast=WavePublish-40"
+func ParseCondition(s string) (Condition, error) {
+	for i := range s {
+		for _, op := range operators {
+			if strings.HasPrefix(s[i:], op) && i > 0 {
+				return Condition{
+					Field: strings.TrimSpace(s[:i]),
+					Op:    op,
+					Value: strings.TrimSpace(s[i+len(op):]),
+				}, nil
+			}
+		}
+	}
+
+	return Condition{}, fmt.Errorf("condition %q has no operator", s)
+}
+
+// Match reports whether a row satisfies the condition
+// Values that both parse as numbers are compared numerically, others as strings
+func (c Condition) Match(row experiment.Result) bool {
+	v, ok := row.Field(c.Field)
+	if !ok {
+		return c.Op == "!="
+	}
+
+	if c.Op == "~" {
+		return strings.Contains(v, c.Value)
+	}
+
+	cmp := compareValues(v, c.Value)
+
+	switch c.Op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// Select returns the rows matching every condition, in insertion order
+// An equality condition on an indexed column narrows the scan to the rows it indexes
+func (s *Store) Select(where []Condition) []experiment.Result {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	candidates := s.candidates(where)
+
+	var rows []experiment.Result
+	for _, i := range candidates {
+		matched := true
+		for _, c := range where {
+			if !c.Match(s.rows[i]) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			rows = append(rows, s.rows[i])
+		}
+	}
+
+	return rows
+}
+
+// candidates returns the smallest index list an equality condition selects, or every row
+func (s *Store) candidates(where []Condition) []int {
+	var best []int
+	found := false
+
+	for _, c := range where {
+		if c.Op != "=" || !isIndexed(c.Field) {
+			continue
+		}
+
+		// Integer columns are indexed in their formatted form; normalize e.g. "1e4" the same way
+		value := c.Value
+		if c.Field == "node_count" || c.Field == "delay" {
+			if f, err := strconv.ParseFloat(value, 64); err == nil && f == float64(int(f)) {
+				value = strconv.Itoa(int(f))
+			}
+		}
+
+		list := s.by[c.Field+"="+value]
+		if !found || len(list) < len(best) {
+			best, found = list, true
+		}
+	}
+
+	if found {
+		return best
+	}
+
+	all := make([]int, len(s.rows))
+	for i := range all {
+		all[i] = i
+	}
+
+	return all
+}
+
+// isIndexed reports whether a column has an equality index
+func isIndexed(field string) bool {
+	for _, col := range indexedColumns {
+		if col == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Group is the summary of rows sharing the values of the grouping columns
+type Group struct {
+	Key   []string           `json:"key"` // Values of the grouping columns
+	Count int                `json:"count"`
+	Means map[string]float64 `json:"means"` // Mean of each requested metric
+}
+
+// GroupBy groups rows by the values of the given columns and averages the metrics in each group
+// Groups are sorted by key; broadcast names follow p2p.SortKey and numbers sort numerically
+func GroupBy(rows []experiment.Result, columns, metrics []string) []Group {
+	groups := make(map[string]*Group)
+	var order []*Group
+
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, col := range columns {
+			values[i], _ = row.Field(col)
+		}
+
+		k := strings.Join(values, "\x00")
+		g, ok := groups[k]
+		if !ok {
+			g = &Group{Key: values, Means: make(map[string]float64, len(metrics))}
+			groups[k] = g
+			order = append(order, g)
+		}
+
+		g.Count++
+		for _, m := range metrics {
+			v, _ := row.Field(m)
+			f, _ := strconv.ParseFloat(v, 64)
+			g.Means[m] += f
+		}
+	}
+
+	result := make([]Group, len(order))
+	for i, g := range order {
+		for _, m := range metrics {
+			g.Means[m] /= float64(g.Count)
+		}
+		result[i] = *g
+	}
+
+	sort.SliceStable(result, func(a, b int) bool {
+		for i, col := range columns {
+			x, y := result[a].Key[i], result[b].Key[i]
+			if col == "broadcast" && p2p.SortKey(x) != p2p.SortKey(y) {
+				return p2p.SortKey(x) < p2p.SortKey(y)
+			}
+			if cmp := compareValues(x, y); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	return result
+}
+
+// compareValues compares numerically when both values are numbers and as strings otherwise
+func compareValues(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(a, b)
+}
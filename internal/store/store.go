@@ -0,0 +1,298 @@
+package store
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+)
+
+// Store file layout:
+//
+//	magic    [4]byte  "NWRS"
+//	version  byte     storeVersion
+//	records  repeated uvarint payload length, JSON-encoded experiment.Result, CRC-32 (IEEE) of the payload
+//
+// The file is only ever appended to. Opening a store replays every record into memory and
+// builds the indexes. A record cut off by the end of the file (from a crash during Insert) is
+// ignored, and truncated away when the store is opened for writing; any other damage is an error.
+const (
+	storeMagic   = "NWRS"
+	storeVersion = 1
+)
+
+// ErrStoreFormat is returned when a store file has an unknown magic or version
+var ErrStoreFormat = errors.New("invalid results store")
+
+// ErrCorrupt is returned when a store file holds a damaged record before its end
+var ErrCorrupt = errors.New("corrupt results store")
+
+// ErrReadOnly is returned when inserting into a store opened with OpenReadOnly
+var ErrReadOnly = errors.New("results store opened read-only")
+
+// Store is an append-only results store with in-memory indexes
+// A store must not be opened for writing by more than one process at a time
+type Store struct {
+	mu       sync.RWMutex
+	file     *os.File
+	readOnly bool
+	rows     []experiment.Result
+	keys     map[string]int   // Row key -> row index, to skip rows inserted twice
+	by       map[string][]int // "column=value" -> row indices, for the indexed columns
+}
+
+// indexedColumns are the columns equality filters can look up without a scan
+var indexedColumns = []string{"experiment", "broadcast", "node_count", "delay", "git_revision"}
+
+// Open opens or creates a store file
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), fs.ModePerm); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, fs.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(path, file, false)
+}
+
+// OpenReadOnly opens an existing store file for queries, leaving the file as it is
+func OpenReadOnly(path string) (*Store, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(path, file, true)
+}
+
+// open loads an opened store file
+func open(path string, file *os.File, readOnly bool) (*Store, error) {
+	s := &Store{file: file, readOnly: readOnly, keys: make(map[string]int), by: make(map[string][]int)}
+
+	if err := s.load(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// load replays the file into memory, writing the header of a new store
+func (s *Store) load() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() == 0 && !s.readOnly {
+		_, err := s.file.Write(append([]byte(storeMagic), storeVersion))
+		return err
+	}
+
+	r := bufio.NewReader(s.file)
+
+	header := make([]byte, len(storeMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:4]) != storeMagic || header[4] != storeVersion {
+		return ErrStoreFormat
+	}
+
+	good := int64(len(header)) // End of the last intact record
+
+	for {
+		length, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			return s.tornAt(good)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: record at offset %d: %v", ErrCorrupt, good, err)
+		}
+
+		// A length beyond the end of the file is a record cut off, rejected before anything is allocated
+		end := good + int64(uvarintLen(length)) + 4
+		if length > uint64(max(info.Size()-end, 0)) {
+			return s.tornAt(good)
+		}
+		end += int64(length)
+
+		payload := make([]byte, length)
+		sum := make([]byte, 4)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(r, sum); err != nil {
+			return err
+		}
+
+		// A bad checksum of the last record is a write the crash left unfinished
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(sum) {
+			if end == info.Size() {
+				return s.tornAt(good)
+			}
+			return fmt.Errorf("%w: record at offset %d: checksum mismatch", ErrCorrupt, good)
+		}
+
+		var row experiment.Result
+		if err := json.Unmarshal(payload, &row); err != nil {
+			return fmt.Errorf("%w: record at offset %d: %v", ErrCorrupt, good, err)
+		}
+		if err := experiment.Upgrade(&row); err != nil {
+			return fmt.Errorf("record at offset %d: %w", good, err)
+		}
+
+		s.index(row)
+		good = end
+	}
+
+	return s.seek(good)
+}
+
+// tornAt drops the record cut off at offset good, truncating it away if the store is writable,
+// so later appends start at a record boundary
+func (s *Store) tornAt(good int64) error {
+	if !s.readOnly {
+		if err := s.file.Truncate(good); err != nil {
+			return err
+		}
+	}
+
+	return s.seek(good)
+}
+
+// seek positions a writable store at the end of its last intact record
+func (s *Store) seek(good int64) error {
+	if s.readOnly {
+		return nil
+	}
+
+	_, err := s.file.Seek(good, io.SeekStart)
+	return err
+}
+
+// Insert appends a row unless a row with the same key is already stored
+// It reports whether the row was added; the record is synced before Insert returns
+func (s *Store) Insert(row experiment.Result) (bool, error) {
+	added, err := s.InsertAll([]experiment.Result{row})
+	return added == 1, err
+}
+
+// InsertAll appends every row not stored yet with a single write and sync
+// and returns the number of rows added
+func (s *Store) InsertAll(rows []experiment.Result) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var buf []byte
+	var added []experiment.Result
+	pending := make(map[string]bool)
+
+	for _, row := range rows {
+		k := key(row)
+		if _, ok := s.keys[k]; ok || pending[k] {
+			continue
+		}
+
+		payload, err := json.Marshal(row)
+		if err != nil {
+			return 0, err
+		}
+
+		buf = binary.AppendUvarint(buf, uint64(len(payload)))
+		buf = append(buf, payload...)
+		buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(payload))
+
+		pending[k] = true
+		added = append(added, row)
+	}
+
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	if _, err := s.file.Write(buf); err != nil {
+		return 0, err
+	}
+	if err := s.file.Sync(); err != nil {
+		return 0, err
+	}
+
+	for _, row := range added {
+		s.index(row)
+	}
+
+	return len(added), nil
+}
+
+// Write inserts a row, letting the store serve as an experiment.ResultWriter
+func (s *Store) Write(row experiment.Result) error {
+	_, err := s.Insert(row)
+	return err
+}
+
+// Len returns the number of stored rows
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.rows)
+}
+
+// Close closes the store file
+func (s *Store) Close() error {
+	return s.file.Close()
+}
+
+// index adds a row to the in-memory rows and indexes
+func (s *Store) index(row experiment.Result) {
+	i := len(s.rows)
+	s.rows = append(s.rows, row)
+	s.keys[key(row)] = i
+
+	for _, col := range indexedColumns {
+		v, _ := row.Field(col)
+		s.by[col+"="+v] = append(s.by[col+"="+v], i)
+	}
+}
+
+// key identifies a row: its experiment and run ID, or a content hash for rows without a run ID
+func key(row experiment.Result) string {
+	if row.RunID != "" {
+		return row.Experiment + "\x00" + row.RunID
+	}
+
+	data, _ := json.Marshal(row)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// uvarintLen returns the encoded size of v
+func uvarintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/experiment"
+)
+
+// record encodes a payload as a store record
+func record(payload []byte) []byte {
+	buf := binary.AppendUvarint(nil, uint64(len(payload)))
+	buf = append(buf, payload...)
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(payload))
+}
+
+// rowRecord encodes a row as a store record
+func rowRecord(t *testing.T, row experiment.Result) []byte {
+	t.Helper()
+
+	payload, err := json.Marshal(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return record(payload)
+}
+
+// writeStore writes a store file with the given records and returns its path
+func writeStore(t *testing.T, records ...[]byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "results.db")
+	data := append([]byte(storeMagic), storeVersion)
+	for _, r := range records {
+		data = append(data, r...)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func row(id string) experiment.Result {
+	return experiment.Result{Schema: experiment.SchemaVersion, RunID: id, Experiment: "test"}
+}
+
+func TestOpenTruncatesTornTail(t *testing.T) {
+	tails := map[string][]byte{
+		"huge length":      {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"length past end":  {0x80, 0x80, 0x04, '{'},
+		"cut in length":    {0x80},
+		"truncated record": {0x10, '{', '"'},
+		"bad checksum":     {0x02, '{', '}', 0, 0, 0, 0},
+	}
+
+	for name, tail := range tails {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results.db")
+
+			s, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Insert(row("r1")); err != nil {
+				t.Fatal(err)
+			}
+			s.Close()
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.Write(tail)
+			file.Close()
+
+			// Reading leaves the torn record in place
+			ro, err := OpenReadOnly(path)
+			if err != nil {
+				t.Fatalf("opening read-only with a torn tail: %v", err)
+			}
+			if ro.Len() != 1 {
+				t.Errorf("read-only: got %d rows, want 1", ro.Len())
+			}
+			ro.Close()
+			if after, _ := os.Stat(path); after.Size() != info.Size()+int64(len(tail)) {
+				t.Errorf("read-only open changed the file to %d bytes", after.Size())
+			}
+
+			s, err = Open(path)
+			if err != nil {
+				t.Fatalf("opening with a torn tail: %v", err)
+			}
+			defer s.Close()
+
+			if s.Len() != 1 {
+				t.Errorf("got %d rows, want 1", s.Len())
+			}
+
+			after, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Size() != info.Size() {
+				t.Errorf("file is %d bytes, want the %d bytes of the intact records", after.Size(), info.Size())
+			}
+		})
+	}
+}
+
+func TestOpenRejectsDamage(t *testing.T) {
+	first, third := rowRecord(t, row("r1")), rowRecord(t, row("r3"))
+
+	newer := row("r2")
+	newer.Schema = experiment.SchemaVersion + 1
+
+	badSum := rowRecord(t, row("r2"))
+	badSum[len(badSum)-1] ^= 0xff
+
+	tests := []struct {
+		name   string
+		middle []byte
+		want   error
+	}{
+		{"newer schema", rowRecord(t, newer), nil},
+		{"checksum mismatch", badSum, ErrCorrupt},
+		{"invalid row", record([]byte("{")), ErrCorrupt},
+		{"overflowing length", bytes.Repeat([]byte{0xff}, 11), ErrCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeStore(t, first, tt.middle, third)
+			before, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, open := range []func(string) (*Store, error){Open, OpenReadOnly} {
+				s, err := open(path)
+				if err == nil {
+					s.Close()
+					t.Fatal("damaged store opened")
+				}
+				if tt.want != nil && !errors.Is(err, tt.want) {
+					t.Errorf("got %v, want %v", err, tt.want)
+				}
+			}
+
+			after, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(after, before) {
+				t.Errorf("file changed from %d to %d bytes", len(before), len(after))
+			}
+		})
+	}
+}
+
+func TestReadOnlyRejectsInsert(t *testing.T) {
+	path := writeStore(t, rowRecord(t, row("r1")))
+
+	s, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.Insert(row("r2")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("got %v, want %v", err, ErrReadOnly)
+	}
+}
@@ -0,0 +1,68 @@
+package trace
+
+import (
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// Kind identifies the type of a broadcast event
+type Kind string
+
+// Event kinds recorded during a broadcast
+const (
+	Publish   Kind = "publish"   // Origin node starts broadcasting a message
+	Send      Kind = "send"      // Node hands a message to a link towards a peer
+	Receive   Kind = "receive"   // Node receives a message for the first time
+	Duplicate Kind = "duplicate" // Node receives a message it has already seen
+	Drop      Kind = "drop"      // Message goes no further: discarded by the receiver, kept by its role or lost on the link
+)
+
+// Version is the current trace file format version
+const Version = 1
+
+// Event is a single entry of a broadcast trace
+type Event struct {
+	Kind    Kind          `json:"kind"`
+	Time    int64         `json:"t"`   // Nanoseconds since the trace started
+	Message p2p.MessageID `json:"msg"` // Message the event refers to
+	From    p2p.NodeID    `json:"from"`
+	To      p2p.NodeID    `json:"to"`
+	Hop     int           `json:"hop"` // Hop counter carried by the message (0 for the origin's transmissions)
+}
+
+// Header describes the run a trace was recorded from and is the first line of a trace file
+type Header struct {
+	Version   int     `json:"version"`
+	NodeCount int     `json:"node_count"`
+	AvgDegree float64 `json:"avg_degree"`
+	Broadcast string  `json:"broadcast"`
+	Delay     int     `json:"delay"`
+	Seed      int64   `json:"seed"`
+	Start     string  `json:"start"` // Wall-clock start time in RFC 3339 format
+}
+
+// Recorder receives events as they happen during a broadcast
+// Implementations must be safe for concurrent use
+type Recorder interface {
+	Record(e Event)
+}
+
+// Tee returns a recorder passing every event to both recorders, either of which may be nil
+func Tee(a, b Recorder) Recorder {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+
+	return tee{a, b}
+}
+
+// tee records events with two recorders
+type tee [2]Recorder
+
+// Record passes an event to both recorders
+func (t tee) Record(e Event) {
+	t[0].Record(e)
+	t[1].Record(e)
+}
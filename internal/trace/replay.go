@@ -0,0 +1,175 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// MessageTrace aggregates all events of a single message
+type MessageTrace struct {
+	Origin       p2p.NodeID                   // Node that published the message
+	Published    time.Duration                // Time the message was published
+	FirstReceipt map[p2p.NodeID]time.Duration // First receipt time of every reached node
+	Hops         map[p2p.NodeID]int           // Hop counter of every node's first receipt
+	Receipts     map[p2p.NodeID]int           // Receipts per node, including duplicates
+	Sends        int                          // Number of transmissions
+	Duplicates   int                          // Number of duplicate receipts
+	Drops        int                          // Number of discarded messages
+}
+
+// Replay holds the header and per-message aggregates of a trace
+type Replay struct {
+	Header   Header
+	Messages map[p2p.MessageID]*MessageTrace
+}
+
+// ReplayFile reads and aggregates a trace file at the given path
+func ReplayFile(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadReplay(file)
+}
+
+// ReadReplay reads a trace written by Writer and aggregates its events per message
+func ReadReplay(r io.Reader) (*Replay, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("trace: missing header")
+	}
+
+	replay := &Replay{
+		Messages: make(map[p2p.MessageID]*MessageTrace),
+	}
+
+	if err := json.Unmarshal(scanner.Bytes(), &replay.Header); err != nil {
+		return nil, fmt.Errorf("trace: invalid header: %w", err)
+	}
+	if replay.Header.Version > Version {
+		return nil, fmt.Errorf("trace: unsupported version %d", replay.Header.Version)
+	}
+
+	line := 1
+	for scanner.Scan() {
+		line++
+
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("trace: line %d: %w", line, err)
+		}
+
+		replay.apply(e)
+	}
+
+	return replay, scanner.Err()
+}
+
+// apply folds a single event into the per-message aggregates
+func (r *Replay) apply(e Event) {
+	m, ok := r.Messages[e.Message]
+	if !ok {
+		m = &MessageTrace{
+			FirstReceipt: make(map[p2p.NodeID]time.Duration),
+			Hops:         make(map[p2p.NodeID]int),
+			Receipts:     make(map[p2p.NodeID]int),
+		}
+		r.Messages[e.Message] = m
+	}
+
+	t := time.Duration(e.Time)
+
+	switch e.Kind {
+	case Publish:
+		m.Origin = e.From
+		m.Published = t
+	case Send:
+		m.Sends++
+	case Receive:
+		m.FirstReceipt[e.To] = t
+		m.Hops[e.To] = e.Hop
+		m.Receipts[e.To]++
+	case Duplicate:
+		m.Duplicates++
+		m.Receipts[e.To]++
+	case Drop:
+		m.Drops++
+	}
+}
+
+// MessageIDs returns the IDs of all traced messages in ascending order
+func (r *Replay) MessageIDs() []p2p.MessageID {
+	ids := make([]p2p.MessageID, 0, len(r.Messages))
+	for id := range r.Messages {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(a, b int) bool {
+		return ids[a] < ids[b]
+	})
+
+	return ids
+}
+
+// Metric recomputes the network metric of a message exactly as a live run reports it
+func (r *Replay) Metric(mid p2p.MessageID) p2p.NetworkMetric {
+	m := r.Messages[mid]
+	recvCount := 0
+
+	if m != nil {
+		for _, count := range m.Receipts {
+			recvCount += count
+		}
+	}
+
+	dontRecvCount := r.Header.NodeCount
+	if m != nil {
+		dontRecvCount -= len(m.Receipts)
+
+		// As in network.Metric, the origin counts as a node without receipts
+		if _, ok := m.Receipts[m.Origin]; ok {
+			dontRecvCount++
+		}
+	}
+
+	metric := p2p.NewNetworkMetric(r.Header.NodeCount, r.Header.Broadcast, r.Header.Delay, r.Header.AvgDegree, recvCount, dontRecvCount)
+
+	if m != nil {
+		latencies := m.Latencies()
+		ms := make([]float64, len(latencies))
+		for i, t := range latencies {
+			ms[i] = float64(t) / float64(time.Millisecond)
+		}
+		metric.SetLatencies(ms)
+	}
+
+	return metric
+}
+
+// Latencies returns the first receipt latency of every reached node relative to publication, in ascending order
+func (m *MessageTrace) Latencies() []time.Duration {
+	latencies := make([]time.Duration, 0, len(m.FirstReceipt))
+	for _, t := range m.FirstReceipt {
+		latencies = append(latencies, t-m.Published)
+	}
+
+	sort.Slice(latencies, func(a, b int) bool {
+		return latencies[a] < latencies[b]
+	})
+
+	return latencies
+}
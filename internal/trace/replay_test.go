@@ -0,0 +1,134 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+func TestReplayRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{NodeCount: 5, AvgDegree: 2, Broadcast: p2p.BasicPublish, Delay: 10, Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Message 1 from node 0 reaches 1, 2 and 3, comes back to the origin and is lost on the way to 4
+	// Message 2 from node 1 is dropped by its only receiver
+	script := []Event{
+		{Kind: Publish, Message: 1, From: 0, To: 0},
+		{Kind: Send, Message: 1, From: 0, To: 1},
+		{Kind: Send, Message: 1, From: 0, To: 2},
+		{Kind: Receive, Message: 1, From: 0, To: 1},
+		{Kind: Publish, Message: 2, From: 1, To: 1},
+		{Kind: Receive, Message: 1, From: 0, To: 2},
+		{Kind: Send, Message: 1, From: 1, To: 2},
+		{Kind: Send, Message: 1, From: 1, To: 3},
+		{Kind: Send, Message: 2, From: 1, To: 0},
+		{Kind: Duplicate, Message: 1, From: 1, To: 2, Hop: 1},
+		{Kind: Receive, Message: 1, From: 1, To: 3, Hop: 1},
+		{Kind: Drop, Message: 2, From: 1, To: 0},
+		{Kind: Send, Message: 1, From: 3, To: 0, Hop: 2},
+		{Kind: Send, Message: 1, From: 2, To: 4, Hop: 1},
+		{Kind: Duplicate, Message: 1, From: 3, To: 0, Hop: 2},
+		{Kind: Drop, Message: 1, From: 2, To: 4, Hop: 1},
+	}
+	for _, e := range script {
+		w.Record(e)
+		time.Sleep(time.Millisecond)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The latencies the replay reports must be those of the timestamps written to the file
+	published := make(map[p2p.MessageID]int64)
+	want := make(map[p2p.NodeID]time.Duration)
+	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	scanner.Scan()
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case e.Kind == Publish:
+			published[e.Message] = e.Time
+		case e.Kind == Receive && e.Message == 1:
+			want[e.To] = time.Duration(e.Time - published[1])
+		}
+	}
+
+	replay, err := ReadReplay(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if replay.Header.Version != Version || replay.Header.NodeCount != 5 || replay.Header.Seed != 7 {
+		t.Errorf("header %+v does not match the one written", replay.Header)
+	}
+	if ids := replay.MessageIDs(); len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("got messages %v, want [1 2]", ids)
+	}
+
+	m := replay.Messages[1]
+	if m.Origin != 0 || m.Sends != 6 || m.Duplicates != 2 || m.Drops != 1 {
+		t.Errorf("message 1: origin %d, %d sends, %d duplicates, %d drops, want 0, 6, 2, 1", m.Origin, m.Sends, m.Duplicates, m.Drops)
+	}
+	if m.Receipts[0] != 1 || m.Receipts[2] != 2 || m.Hops[3] != 1 {
+		t.Errorf("message 1: receipts %v, hops %v", m.Receipts, m.Hops)
+	}
+	if len(m.FirstReceipt) != len(want) {
+		t.Fatalf("message 1: %d nodes reached, want %d", len(m.FirstReceipt), len(want))
+	}
+	for id, latency := range want {
+		if got := m.FirstReceipt[id] - m.Published; got != latency || got < time.Millisecond {
+			t.Errorf("node %d: latency %v, want %v", id, got, latency)
+		}
+	}
+
+	latencies := m.Latencies()
+	for i := 1; i < len(latencies); i++ {
+		if latencies[i] < latencies[i-1] {
+			t.Errorf("latencies %v are not in ascending order", latencies)
+		}
+	}
+
+	// Reached leaves out the origin even though a copy came back to it
+	metric := replay.Metric(1)
+	if metric.Reached != 3 || metric.Receptions != 5 || metric.ReceivingRate != 0.75 {
+		t.Errorf("message 1: reached %d with %d receptions and rate %v, want 3, 5 and 0.75", metric.Reached, metric.Receptions, metric.ReceivingRate)
+	}
+	if ms := float64(latencies[len(latencies)-1]) / float64(time.Millisecond); metric.LatencyMax != ms {
+		t.Errorf("message 1: maximum latency %v ms, want %v", metric.LatencyMax, ms)
+	}
+
+	if m := replay.Messages[2]; m.Origin != 1 || m.Sends != 1 || m.Drops != 1 || len(m.FirstReceipt) != 0 {
+		t.Errorf("message 2: origin %d, %d sends, %d drops, %d reached, want 1, 1, 1, 0", m.Origin, m.Sends, m.Drops, len(m.FirstReceipt))
+	}
+	if metric := replay.Metric(2); metric.Reached != 0 || metric.DuplicateRate != 0 || metric.ReceivingRate != 0 {
+		t.Errorf("message 2: reached %d with rates %v and %v, want 0", metric.Reached, metric.DuplicateRate, metric.ReceivingRate)
+	}
+}
+
+func TestReadReplayRejects(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "",
+		"invalid header": "{\n",
+		"newer version":  `{"version":99}` + "\n",
+		"invalid event":  `{"version":1}` + "\n" + `{"kind":"send","msg":"x"}` + "\n",
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ReadReplay(strings.NewReader(input)); err == nil {
+				t.Error("accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Writer streams events to a JSONL trace file
+// Events are timestamped on arrival, so the file is ordered by event time
+type Writer struct {
+	mu     sync.Mutex
+	bw     *bufio.Writer
+	enc    *json.Encoder
+	closer io.Closer
+	start  time.Time
+	err    error // First write error, reported by Close
+}
+
+// NewWriter creates a trace writer and writes the header as the first line
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	start := time.Now()
+	header.Version = Version
+	header.Start = start.Format(time.RFC3339Nano)
+
+	bw := bufio.NewWriter(w)
+	tw := &Writer{
+		bw:    bw,
+		enc:   json.NewEncoder(bw),
+		start: start,
+	}
+
+	if err := tw.enc.Encode(header); err != nil {
+		return nil, err
+	}
+
+	return tw, nil
+}
+
+// Create creates a trace file at the given path and writes the header
+func Create(path string, header Header) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tw, err := NewWriter(file, header)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	tw.closer = file
+
+	return tw, nil
+}
+
+// Record timestamps and writes a single event
+func (w *Writer) Record(e Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return
+	}
+
+	e.Time = int64(time.Since(w.start))
+	w.err = w.enc.Encode(e)
+}
+
+// Close flushes buffered events and closes the underlying file if the writer owns it
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.bw.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+
+	if w.closer != nil {
+		if err := w.closer.Close(); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+
+	return w.err
+}
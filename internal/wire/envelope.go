@@ -0,0 +1,297 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// Version is the layout version written by Append; Decode accepts it and every older one
+const Version = 1
+
+// MaxFrame bounds the size of an encoded envelope, which also fits in one UDP datagram
+const MaxFrame = 64 << 10
+
+// Decoding errors
+var (
+	ErrShort    = errors.New("envelope ends before all of its fields")
+	ErrVersion  = errors.New("envelope version not supported")
+	ErrKind     = errors.New("unknown envelope kind")
+	ErrProtocol = errors.New("unknown broadcast protocol")
+	ErrTooLarge = errors.New("envelope exceeds the maximum frame size")
+	ErrEncoding = errors.New("envelope not encoded as Append writes it")
+)
+
+// Kind is the role of a transmission in the broadcast
+type Kind uint8
+
+// Envelope kinds
+const (
+	Relay Kind = 1 // Flooding or selective relay, including the origin's own transmissions
+	Stem  Kind = 2 // Dandelion stem phase, forwarded to a single relay
+)
+
+// Protocol codes of the broadcast types, so envelopes do not carry their names
+const (
+	codeBasic     = 1
+	codeWave      = 2
+	codeDandelion = 3
+)
+
+// Field tags of protocol-specific fields, written in this order
+const (
+	tagLevel   = 1 // BroadcastType.Level
+	tagTimeout = 2 // BroadcastType.Timeout
+)
+
+// Envelope is a broadcast message as it travels over one link
+type Envelope struct {
+	Kind     Kind
+	ID       p2p.MessageID
+	Origin   p2p.NodeID
+	From     p2p.NodeID // Sender of this transmission
+	Hop      int
+	Protocol p2p.BroadcastType // Broadcast algorithm and its parameters, encoded as protocol-specific fields
+	Payload  []byte
+}
+
+// Layout of version 1, all integers as varints:
+//
+//	version u8 | kind u8 | id | origin | from | hop (signed) | protocol u8
+//	| field count | (tag u8 | value)... | payload length | payload
+
+// Append encodes an envelope at the end of buf
+func Append(buf []byte, e Envelope) ([]byte, error) {
+	code, err := protocolCode(e.Protocol.Type)
+	if err != nil {
+		return buf, err
+	}
+	if size := Size(e); size > MaxFrame {
+		return buf, fmt.Errorf("%w: %d bytes", ErrTooLarge, size)
+	}
+
+	buf = append(buf, Version, byte(e.Kind))
+	buf = binary.AppendUvarint(buf, uint64(e.ID))
+	buf = binary.AppendUvarint(buf, uint64(e.Origin))
+	buf = binary.AppendUvarint(buf, uint64(e.From))
+	buf = binary.AppendVarint(buf, int64(e.Hop))
+	buf = append(buf, code)
+
+	fields := e.fields()
+	buf = binary.AppendUvarint(buf, uint64(len(fields)))
+	for _, f := range fields {
+		buf = append(buf, f.tag)
+		buf = binary.AppendUvarint(buf, f.value)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(e.Payload)))
+	return append(buf, e.Payload...), nil
+}
+
+// Size returns the encoded size of an envelope without encoding it
+func Size(e Envelope) int {
+	size := 3 // Version, kind and protocol code
+	size += uvarintLen(uint64(e.ID)) + uvarintLen(uint64(e.Origin)) + uvarintLen(uint64(e.From))
+	size += varintLen(int64(e.Hop))
+
+	fields := e.fields()
+	size += uvarintLen(uint64(len(fields)))
+	for _, f := range fields {
+		size += 1 + uvarintLen(f.value)
+	}
+
+	return size + uvarintLen(uint64(len(e.Payload))) + len(e.Payload)
+}
+
+// Decode parses an envelope written by Append of this or an older version
+// Only the exact bytes Append writes are accepted: non-minimal varints, fields the protocol does
+// not have and trailing bytes are rejected, so a decoded envelope encodes back to the same bytes.
+// The payload aliases buf
+func Decode(buf []byte) (Envelope, error) {
+	if len(buf) > MaxFrame {
+		return Envelope{}, ErrTooLarge
+	}
+
+	r := reader{buf: buf}
+
+	if v := r.byte(); r.err == nil && (v == 0 || v > Version) {
+		return Envelope{}, fmt.Errorf("%w: %d", ErrVersion, v)
+	}
+
+	e := Envelope{Kind: Kind(r.byte())}
+	e.ID = p2p.MessageID(r.uvarint())
+	e.Origin = p2p.NodeID(r.uvarint())
+	e.From = p2p.NodeID(r.uvarint())
+	e.Hop = int(r.varint())
+	code := r.byte()
+
+	var fields []field
+	for n := r.uvarint(); n > 0 && r.err == nil; n-- {
+		f := field{tag: r.byte(), value: r.uvarint()}
+		switch f.tag {
+		case tagLevel:
+			e.Protocol.Level = int(f.value)
+		case tagTimeout:
+			e.Protocol.Timeout = p2p.Delay(f.value)
+		}
+		fields = append(fields, f)
+	}
+
+	e.Payload = r.bytes(r.uvarint())
+
+	if r.err != nil {
+		return Envelope{}, r.err
+	}
+	if e.Kind != Relay && e.Kind != Stem {
+		return Envelope{}, fmt.Errorf("%w: %d", ErrKind, e.Kind)
+	}
+
+	switch code {
+	case codeBasic:
+		e.Protocol.Type = p2p.BasicPublish
+	case codeWave:
+		e.Protocol.Type = p2p.WavePublish
+	case codeDandelion:
+		e.Protocol.Type = p2p.Dandelion
+	default:
+		return Envelope{}, fmt.Errorf("%w: code %d", ErrProtocol, code)
+	}
+
+	if !slices.Equal(fields, e.fields()) {
+		return Envelope{}, fmt.Errorf("%w: fields do not match %s", ErrEncoding, e.Protocol.Type)
+	}
+	if len(r.buf) > 0 {
+		return Envelope{}, fmt.Errorf("%w: %d trailing bytes", ErrEncoding, len(r.buf))
+	}
+
+	return e, nil
+}
+
+// field is a protocol-specific field
+type field struct {
+	tag   byte
+	value uint64
+}
+
+// fields returns the protocol-specific fields of an envelope
+func (e Envelope) fields() []field {
+	switch e.Protocol.Type {
+	case p2p.WavePublish:
+		return []field{{tagLevel, uint64(e.Protocol.Level)}}
+	case p2p.Dandelion:
+		return []field{{tagLevel, uint64(e.Protocol.Level)}, {tagTimeout, uint64(e.Protocol.Timeout)}}
+	default:
+		return nil
+	}
+}
+
+// protocolCode returns the code of a broadcast type name
+func protocolCode(name string) (byte, error) {
+	switch name {
+	case p2p.BasicPublish:
+		return codeBasic, nil
+	case p2p.WavePublish:
+		return codeWave, nil
+	case p2p.Dandelion:
+		return codeDandelion, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrProtocol, name)
+	}
+}
+
+// reader reads consecutive fields, remembering the first error
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) byte() byte {
+	if len(r.buf) == 0 {
+		r.fail()
+		return 0
+	}
+
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+
+	return b
+}
+
+func (r *reader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	if n == 0 {
+		r.fail()
+		return 0
+	}
+	if n < 0 || n != uvarintLen(v) {
+		r.overlong()
+		return 0
+	}
+	r.buf = r.buf[n:]
+
+	return v
+}
+
+func (r *reader) varint() int64 {
+	v, n := binary.Varint(r.buf)
+	if n == 0 {
+		r.fail()
+		return 0
+	}
+	if n < 0 || n != varintLen(v) {
+		r.overlong()
+		return 0
+	}
+	r.buf = r.buf[n:]
+
+	return v
+}
+
+func (r *reader) bytes(n uint64) []byte {
+	if n > uint64(len(r.buf)) {
+		r.fail()
+		return nil
+	}
+
+	b := r.buf[:n:n]
+	r.buf = r.buf[n:]
+
+	return b
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = ErrShort
+	}
+	r.buf = nil
+}
+
+func (r *reader) overlong() {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: overlong varint", ErrEncoding)
+	}
+	r.buf = nil
+}
+
+// uvarintLen returns the encoded length of an unsigned varint
+func uvarintLen(v uint64) int {
+	n := 1
+	for ; v >= 0x80; v >>= 7 {
+		n++
+	}
+
+	return n
+}
+
+// varintLen returns the encoded length of a signed (zig-zag) varint
+func varintLen(v int64) int {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+
+	return uvarintLen(u)
+}
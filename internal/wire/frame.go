@@ -0,0 +1,87 @@
+package wire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// FrameSize returns the size of an envelope in a stream frame, its length prefix included
+func FrameSize(e Envelope) int {
+	size := Size(e)
+	return uvarintLen(uint64(size)) + size
+}
+
+// AppendFrame encodes an envelope prefixed with its length as a uvarint, for stream transports
+func AppendFrame(buf []byte, e Envelope) ([]byte, error) {
+	buf = binary.AppendUvarint(buf, uint64(Size(e)))
+	return Append(buf, e)
+}
+
+// WriteFrame writes a length-prefixed envelope and returns the number of bytes written
+func WriteFrame(w io.Writer, e Envelope) (int, error) {
+	buf, err := AppendFrame(nil, e)
+	if err != nil {
+		return 0, err
+	}
+
+	return w.Write(buf)
+}
+
+// ReadFrame reads a length-prefixed envelope and returns it with the number of bytes consumed
+// A length above MaxFrame is rejected before anything is allocated. A stream ending
+// between frames returns io.EOF, and one ending within a frame io.ErrUnexpectedEOF
+func ReadFrame(r *bufio.Reader) (Envelope, int, error) {
+	size, err := readLength(r)
+	if err != nil {
+		return Envelope{}, 0, err
+	}
+	if size > MaxFrame {
+		return Envelope{}, 0, fmt.Errorf("%w: %d bytes", ErrTooLarge, size)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Envelope{}, 0, err
+	}
+
+	e, err := Decode(buf)
+	if err != nil {
+		return Envelope{}, 0, err
+	}
+
+	return e, uvarintLen(size) + int(size), nil
+}
+
+// readLength reads the uvarint length prefix of a frame, which must be minimally encoded
+func readLength(r *bufio.Reader) (uint64, error) {
+	var prefix []byte
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF && len(prefix) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		prefix = append(prefix, b)
+		if b < 0x80 {
+			break
+		}
+		if len(prefix) == binary.MaxVarintLen64 {
+			return 0, fmt.Errorf("%w: overlong length", ErrEncoding)
+		}
+	}
+
+	size, n := binary.Uvarint(prefix)
+	if n != len(prefix) || n != uvarintLen(size) {
+		return 0, fmt.Errorf("%w: overlong length", ErrEncoding)
+	}
+
+	return size, nil
+}
@@ -0,0 +1,218 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/elecbug/p2p-broadcast-tester/internal/p2p"
+)
+
+// seeds returns valid envelopes of every kind and protocol
+func seeds() []Envelope {
+	protocols := []p2p.BroadcastType{
+		{Type: p2p.BasicPublish},
+		{Type: p2p.WavePublish, Level: 30},
+		{Type: p2p.Dandelion, Level: 10, Timeout: 500},
+	}
+
+	var envelopes []Envelope
+	for _, kind := range []Kind{Relay, Stem} {
+		for _, protocol := range protocols {
+			envelopes = append(envelopes,
+				Envelope{Kind: kind, ID: 1, Protocol: protocol},
+				Envelope{Kind: kind, ID: 1 << 40, Origin: 7, From: 12345, Hop: 3, Protocol: protocol, Payload: []byte("payload")},
+				Envelope{Kind: kind, ID: 2, Origin: 1, From: 2, Hop: -1, Protocol: protocol, Payload: make([]byte, 300)},
+			)
+		}
+	}
+
+	return envelopes
+}
+
+func encode(t testing.TB, e Envelope) []byte {
+	t.Helper()
+
+	buf, err := Append(nil, e)
+	if err != nil {
+		t.Fatalf("encoding %+v: %v", e, err)
+	}
+
+	return buf
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, e := range seeds() {
+		got, err := Decode(encode(t, e))
+		if err != nil {
+			t.Fatalf("decoding %+v: %v", e, err)
+		}
+
+		if len(e.Payload) == 0 {
+			e.Payload = []byte{}
+		}
+		if !reflect.DeepEqual(got, e) {
+			t.Errorf("decoded %+v, want %+v", got, e)
+		}
+		if size := Size(e); size != len(encode(t, e)) {
+			t.Errorf("Size %d, encoded %d bytes", size, len(encode(t, e)))
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid := encode(t, Envelope{Kind: Relay, ID: 1, Protocol: p2p.BroadcastType{Type: p2p.WavePublish, Level: 30}})
+
+	// The header is version, kind, then single-byte id, origin, from and hop, then the protocol code
+	with := func(i int, b byte) []byte {
+		buf := bytes.Clone(valid)
+		buf[i] = b
+		return buf
+	}
+
+	tests := []struct {
+		name string
+		buf  []byte
+		want error
+	}{
+		{"empty", nil, ErrShort},
+		{"truncated", valid[:len(valid)-1], ErrShort},
+		{"payload beyond end", append(bytes.Clone(valid[:len(valid)-1]), 5, 'a'), ErrShort},
+		{"version 0", with(0, 0), ErrVersion},
+		{"newer version", with(0, Version+1), ErrVersion},
+		{"kind 0", with(1, 0), ErrKind},
+		{"unknown kind", with(1, 9), ErrKind},
+		{"unknown protocol", with(6, 9), ErrProtocol},
+		{"too large", make([]byte, MaxFrame+1), ErrTooLarge},
+		{"trailing bytes", append(bytes.Clone(valid), 0), ErrEncoding},
+		{"overlong varint", append([]byte{Version, byte(Relay), 0x81, 0x00}, valid[3:]...), ErrEncoding},
+		{"missing field", append(bytes.Clone(valid[:7]), 0, 0), ErrEncoding},
+		{"field of another protocol", with(6, codeBasic), ErrEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.buf); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendErrors(t *testing.T) {
+	if _, err := Append(nil, Envelope{Kind: Relay, Protocol: p2p.BroadcastType{Type: "Gossip"}}); !errors.Is(err, ErrProtocol) {
+		t.Errorf("unknown protocol: got %v, want %v", err, ErrProtocol)
+	}
+
+	big := Envelope{Kind: Relay, Protocol: p2p.BroadcastType{Type: p2p.BasicPublish}, Payload: make([]byte, MaxFrame)}
+	if _, err := Append(nil, big); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("oversized payload: got %v, want %v", err, ErrTooLarge)
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	frame, err := AppendFrame(nil, seeds()[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		buf  []byte
+		want error
+	}{
+		{"end of stream", nil, io.EOF},
+		{"end within length", []byte{0x80}, io.ErrUnexpectedEOF},
+		{"end within envelope", frame[:len(frame)-1], io.ErrUnexpectedEOF},
+		{"end after length", frame[:1], io.ErrUnexpectedEOF},
+		{"length too large", []byte{0x81, 0x80, 0x08}, ErrTooLarge},
+		{"overlong length", append([]byte{frame[0] | 0x80, 0x00}, frame[1:]...), ErrEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ReadFrame(bufio.NewReader(bytes.NewReader(tt.buf))); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFrameStream(t *testing.T) {
+	var stream bytes.Buffer
+	for _, e := range seeds() {
+		if _, err := WriteFrame(&stream, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := bufio.NewReader(&stream)
+	for _, e := range seeds() {
+		got, n, err := ReadFrame(r)
+		if err != nil {
+			t.Fatalf("reading %+v: %v", e, err)
+		}
+		if got.ID != e.ID || got.Kind != e.Kind || got.Protocol != e.Protocol || n != FrameSize(e) {
+			t.Errorf("read %+v (%d bytes), want %+v (%d bytes)", got, n, e, FrameSize(e))
+		}
+	}
+
+	if _, _, err := ReadFrame(r); err != io.EOF {
+		t.Errorf("after the last frame: got %v, want %v", err, io.EOF)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, e := range seeds() {
+		f.Add(encode(f, e))
+	}
+
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		e, err := Decode(buf)
+		if err != nil {
+			return
+		}
+
+		again, err := Append(nil, e)
+		if err != nil {
+			t.Fatalf("re-encoding %+v: %v", e, err)
+		}
+		if !bytes.Equal(again, buf) {
+			t.Fatalf("re-encoded %x, decoded from %x", again, buf)
+		}
+	})
+}
+
+func FuzzReadFrame(f *testing.F) {
+	for _, e := range seeds() {
+		frame, err := AppendFrame(nil, e)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(frame)
+	}
+
+	f.Fuzz(func(t *testing.T, stream []byte) {
+		r := bufio.NewReader(bytes.NewReader(stream))
+		consumed := 0
+
+		for {
+			e, n, err := ReadFrame(r)
+			if err != nil {
+				return
+			}
+
+			again, err := AppendFrame(nil, e)
+			if err != nil {
+				t.Fatalf("re-encoding %+v: %v", e, err)
+			}
+			if n != len(again) || !bytes.Equal(again, stream[consumed:consumed+n]) {
+				t.Fatalf("re-encoded %x, read %d bytes from %x", again, n, stream[consumed:])
+			}
+			consumed += n
+		}
+	})
+}